@@ -0,0 +1,116 @@
+package soajsgo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// aclRestricted is the apisPermission value that denies the APIs not listed in the ACL.
+const aclRestricted = "restricted"
+
+var (
+	// ErrACLDenied is returned when the tenant ACL does not grant access to the API.
+	ErrACLDenied = errors.New("access denied by ACL")
+	// ErrLoginRequired is returned when the API is restricted to logged in users.
+	ErrLoginRequired = errors.New("access restricted to logged in users")
+)
+
+// ACL returns the access control list that applies to the request: the application ACL when set, otherwise
+// the ACL of the package.
+func (c ContextData) ACL() map[string]interface{} {
+	if acl, ok := c.Tenant.Application.ACL.(map[string]interface{}); ok && len(acl) > 0 {
+		return acl
+	}
+	return c.Tenant.Application.PackageACL
+}
+
+// Allowed checks the ACL of the request for the API identified by service, version, method and route, the way
+// the SOAJS gateway does. An API whose access is true requires a logged in user and an API whose access is a list
+// requires the user to be in one of the listed groups.
+func (c ContextData) Allowed(service, version, method, route string) error {
+	svc, ok := c.ACL()[service].(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("%w: service %s is not in the ACL", ErrACLDenied, service)
+	}
+	// ACL per version, from SOAJS 3, falls back to the flat structure of older versions
+	if v, ok := svc[version].(map[string]interface{}); ok && version != "" {
+		svc = v
+	}
+	access := svc["access"]
+	api, found := aclAPI(svc, method, route)
+	if found {
+		if a, ok := api["access"]; ok {
+			access = a
+		}
+	} else if svc["apisPermission"] == aclRestricted {
+		return fmt.Errorf("%w: %s %s is not permitted", ErrACLDenied, strings.ToUpper(method), route)
+	}
+	return c.checkAccess(access)
+}
+
+func (c ContextData) checkAccess(access interface{}) error {
+	switch a := access.(type) {
+	case bool:
+		if a && c.Urac.ID == "" {
+			return ErrLoginRequired
+		}
+	case []interface{}:
+		if c.Urac.ID == "" {
+			return ErrLoginRequired
+		}
+		for _, group := range a {
+			for _, g := range c.Urac.Groups {
+				if group == g {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("%w: user is not in an allowed group", ErrACLDenied)
+	}
+	return nil
+}
+
+// aclAPI finds the API entry of the route, either listed per method (groups of apis) or in the flat apis map. The
+// entries of the method win over the flat ones and, among the matching patterns, the most specific one wins.
+func aclAPI(svc map[string]interface{}, method, route string) (map[string]interface{}, bool) {
+	var methodSources []interface{}
+	switch m := svc[strings.ToLower(method)].(type) {
+	case []interface{}:
+		for _, group := range m {
+			if g, ok := group.(map[string]interface{}); ok {
+				methodSources = append(methodSources, g["apis"])
+			}
+		}
+	case map[string]interface{}:
+		methodSources = append(methodSources, m["apis"])
+	}
+	for _, sources := range [][]interface{}{methodSources, {svc["apis"]}} {
+		var (
+			best        map[string]interface{}
+			bestPattern string
+			bestScore   = -1
+		)
+		for _, source := range sources {
+			apis, ok := source.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for pattern, api := range apis {
+				params, ok := matchRoute(pattern, route)
+				if !ok {
+					continue
+				}
+				score := routeScore(pattern, params)
+				if score > bestScore || (score == bestScore && pattern < bestPattern) {
+					best, _ = api.(map[string]interface{})
+					bestPattern, bestScore = pattern, score
+				}
+			}
+		}
+		if bestScore >= 0 {
+			return best, true
+		}
+	}
+	return nil, false
+}
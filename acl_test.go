@@ -0,0 +1,114 @@
+package soajsgo
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestContextData_Allowed(t *testing.T) {
+	acl := map[string]interface{}{
+		"urac": map[string]interface{}{
+			"1": map[string]interface{}{
+				"access":         false,
+				"apisPermission": "restricted",
+				"get": []interface{}{
+					map[string]interface{}{
+						"group": "User",
+						"apis": map[string]interface{}{
+							"/user/:id":   map[string]interface{}{"access": true},
+							"/user/me":    map[string]interface{}{},
+							"/public":     map[string]interface{}{},
+							"/admin/list": map[string]interface{}{"access": []interface{}{"admin"}},
+						},
+					},
+				},
+			},
+		},
+		"legacy": map[string]interface{}{
+			"access": true,
+			"apis": map[string]interface{}{
+				"/open": map[string]interface{}{"access": false},
+			},
+		},
+	}
+	guest := ContextData{Tenant: Tenant{Application: Application{ACL: acl}}}
+	user := ContextData{Tenant: Tenant{Application: Application{PackageACL: acl}}, Urac: Urac{ID: "1", Groups: []string{"user"}}}
+	admin := ContextData{Tenant: Tenant{Application: Application{PackageACL: acl}}, Urac: Urac{ID: "2", Groups: []string{"admin"}}}
+
+	tt := []struct {
+		name        string
+		c           ContextData
+		service     string
+		version     string
+		method      string
+		route       string
+		expectedErr error
+	}{
+		{name: "no acl", c: ContextData{}, service: "urac", version: "1", method: "GET", route: "/public", expectedErr: ErrACLDenied},
+		{name: "unknown service", c: guest, service: "other", version: "1", method: "GET", route: "/public", expectedErr: ErrACLDenied},
+		{name: "public api", c: guest, service: "urac", version: "1", method: "GET", route: "/public"},
+		{name: "restricted api not listed", c: guest, service: "urac", version: "1", method: "POST", route: "/public", expectedErr: ErrACLDenied},
+		{name: "login required", c: guest, service: "urac", version: "1", method: "GET", route: "/user/42", expectedErr: ErrLoginRequired},
+		{name: "concrete route over parameterised route", c: guest, service: "urac", version: "1", method: "GET", route: "/user/me"},
+		{name: "logged in", c: user, service: "urac", version: "1", method: "GET", route: "/user/42"},
+		{name: "group not allowed", c: user, service: "urac", version: "1", method: "GET", route: "/admin/list", expectedErr: ErrACLDenied},
+		{name: "group allowed", c: admin, service: "urac", version: "1", method: "GET", route: "/admin/list"},
+		{name: "legacy service access", c: guest, service: "legacy", method: "GET", route: "/other", expectedErr: ErrLoginRequired},
+		{name: "legacy api access", c: guest, service: "legacy", method: "GET", route: "/open"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.c.Allowed(tc.service, tc.version, tc.method, tc.route)
+			if tc.expectedErr == nil {
+				assert.NoError(t, err)
+				return
+			}
+			assert.True(t, errors.Is(err, tc.expectedErr), err)
+		})
+	}
+}
+
+func TestAclAPI(t *testing.T) {
+	svc := map[string]interface{}{
+		"get": []interface{}{
+			map[string]interface{}{"apis": map[string]interface{}{
+				"/users/:id":       map[string]interface{}{"name": "user"},
+				"/users/:id/:item": map[string]interface{}{"name": "item"},
+			}},
+			map[string]interface{}{"apis": map[string]interface{}{
+				"/users/me":        map[string]interface{}{"name": "me"},
+				"/users/:id/roles": map[string]interface{}{"name": "roles"},
+			}},
+		},
+		"apis": map[string]interface{}{
+			"/users/me/roles": map[string]interface{}{"name": "flat"},
+			"/flat/:id":       map[string]interface{}{"name": "flat"},
+		},
+	}
+
+	tt := []struct {
+		route        string
+		expectedName interface{}
+		expectedOK   bool
+	}{
+		{route: "/users/me", expectedName: "me", expectedOK: true},
+		{route: "/users/42", expectedName: "user", expectedOK: true},
+		{route: "/users/42/roles", expectedName: "roles", expectedOK: true},
+		{route: "/users/42/groups", expectedName: "item", expectedOK: true},
+		{route: "/users/me/roles", expectedName: "roles", expectedOK: true},
+		{route: "/flat/1", expectedName: "flat", expectedOK: true},
+		{route: "/other"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.route, func(t *testing.T) {
+			// map iteration order is random, the most specific entry must win every time
+			for i := 0; i < 50; i++ {
+				api, ok := aclAPI(svc, "GET", tc.route)
+				assert.Equal(t, tc.expectedOK, ok)
+				assert.Equal(t, tc.expectedName, api["name"])
+			}
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package soajsgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// API is a route of the service, listed in the register payload so the controller, the console and the ACL
+// editor know the routes of the service. Method is the SOAJS method key, e.g. "get" or "del".
+type API struct {
+	Method string `json:"m"`
+	Path   string `json:"v"`
+	APIInfo
+}
+
+// apiMethods are the SOAJS method keys of the routes.
+var apiMethods = map[string]bool{"get": true, "post": true, "put": true, "del": true, "patch": true, "head": true, "options": true}
+
+// RegisterAPI adds a route to the API list of the service, replacing the route of the schema with the same method
+// and path. The method is an HTTP method or a SOAJS method key.
+func (c *Config) RegisterAPI(method, path string, info APIInfo) {
+	c.APIs = append(c.APIs, API{Method: apiMethod(method), Path: path, APIInfo: info})
+}
+
+// APIList returns the routes of the schema and the registered routes, sorted by path and method.
+func (c *Config) APIList() []API {
+	byRoute := make(map[string]API)
+	for method, routes := range c.Schema.Methods {
+		for path, route := range routes {
+			api := API{Method: apiMethod(method), Path: path, APIInfo: route.APIInfo}
+			byRoute[api.Method+" "+api.Path] = api
+		}
+	}
+	for _, api := range c.APIs {
+		api.Method = apiMethod(api.Method)
+		byRoute[api.Method+" "+api.Path] = api
+	}
+	apis := make([]API, 0, len(byRoute))
+	for _, api := range byRoute {
+		apis = append(apis, api)
+	}
+	sort.Slice(apis, func(i, j int) bool {
+		if apis[i].Path != apis[j].Path {
+			return apis[i].Path < apis[j].Path
+		}
+		return apis[i].Method < apis[j].Method
+	})
+	return apis
+}
+
+// WriteAPIList writes the API list as indented JSON, e.g. to document the routes of the service.
+func (c *Config) WriteAPIList(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(c.APIList()); err != nil {
+		return fmt.Errorf("could not write API list: %v", err)
+	}
+	return nil
+}
+
+func (a API) validate() error {
+	if !apiMethods[apiMethod(a.Method)] {
+		return fmt.Errorf("unknown method %s of route %s", a.Method, a.Path)
+	}
+	if !strings.HasPrefix(a.Path, "/") {
+		return fmt.Errorf("path %s of %s route must start with /", a.Path, a.Method)
+	}
+	if a.Label == "" {
+		return fmt.Errorf("route %s %s has no label", a.Method, a.Path)
+	}
+	return nil
+}
+
+// apiMethod returns the SOAJS key of the method.
+func apiMethod(method string) string {
+	return schemaMethod(method)[0]
+}
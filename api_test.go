@@ -0,0 +1,75 @@
+package soajsgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_APIList(t *testing.T) {
+	c := Config{Schema: loadTestSchema(t)}
+	c.RegisterAPI("DELETE", "/users/:id", APIInfo{Label: "Remove user", Group: "User", Access: true})
+	c.RegisterAPI("PUT", "/users/:id", APIInfo{Label: "Update user", Group: "User", Urac: true})
+
+	expected := []API{
+		{Method: "get", Path: "/users", APIInfo: APIInfo{Label: "List users", Group: "User", GroupMain: true}},
+		{Method: "post", Path: "/users", APIInfo: APIInfo{Label: "Add user", Group: "User"}},
+		{Method: "del", Path: "/users/:id", APIInfo: APIInfo{Label: "Remove user", Group: "User", Access: true}},
+		{Method: "get", Path: "/users/:id", APIInfo: APIInfo{Label: "Get user", Group: "User"}},
+		{Method: "put", Path: "/users/:id", APIInfo: APIInfo{Label: "Update user", Group: "User", Urac: true}},
+		{Method: "get", Path: "/users/me", APIInfo: APIInfo{Label: "Get current user", Group: "User"}},
+	}
+	assert.Equal(t, expected, c.APIList())
+	assert.Equal(t, []API{}, (&Config{}).APIList())
+}
+
+func TestAPI_validate(t *testing.T) {
+	tt := []struct {
+		name        string
+		api         API
+		expectedErr string
+	}{
+		{name: "ok", api: API{Method: "get", Path: "/users", APIInfo: APIInfo{Label: "List users"}}},
+		{name: "http method", api: API{Method: "DELETE", Path: "/users/:id", APIInfo: APIInfo{Label: "Delete user"}}},
+		{name: "unknown method", api: API{Method: "fetch", Path: "/users", APIInfo: APIInfo{Label: "List users"}}, expectedErr: "unknown method fetch of route /users"},
+		{name: "relative path", api: API{Method: "get", Path: "users", APIInfo: APIInfo{Label: "List users"}}, expectedErr: "path users of get route must start with /"},
+		{name: "no label", api: API{Method: "get", Path: "/users"}, expectedErr: "route get /users has no label"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.api.validate()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestConfig_WriteAPIList(t *testing.T) {
+	var c Config
+	c.RegisterAPI("get", "/users", APIInfo{Label: "List users", Group: "User", Access: true})
+	var buf bytes.Buffer
+	require.NoError(t, c.WriteAPIList(&buf))
+	assert.JSONEq(t, `[{"m":"get","v":"/users","l":"List users","group":"User","access":true}]`, buf.String())
+}
+
+func TestNewRegisterConf_APIList(t *testing.T) {
+	c := Config{ServiceName: "users"}
+	c.RegisterAPI("post", "/users", APIInfo{Label: "Add user", Group: "User"})
+	b, err := json.Marshal(newRegisterConf(c))
+	require.NoError(t, err)
+	var payload struct {
+		APIList []map[string]interface{} `json:"apiList"`
+	}
+	require.NoError(t, json.Unmarshal(b, &payload))
+	assert.Equal(t, []map[string]interface{}{{"m": "post", "v": "/users", "l": "Add user", "group": "User"}}, payload.APIList)
+
+	b, err = json.Marshal(newRegisterConf(Config{}))
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"apiList":[]`)
+}
@@ -0,0 +1,213 @@
+package soajsgo
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"strings"
+)
+
+// Keys of the geo information injected by the gateway.
+const (
+	GeoIP      = "ip"
+	GeoCountry = "country"
+	GeoRegion  = "region"
+	GeoCity    = "city"
+)
+
+// HeaderForwardedFor is the header carrying the addresses of the client and of the proxies a request went through.
+const HeaderForwardedFor = "X-Forwarded-For"
+
+var (
+	// osPatterns match the operating system of a user agent, in order, the first group being its version.
+	osPatterns = []struct {
+		name string
+		re   *regexp.Regexp
+	}{
+		{"iPadOS", regexp.MustCompile(`iPad.*OS (\d+(?:_\d+)*)`)},
+		{"iOS", regexp.MustCompile(`(?:iPhone|CPU) OS (\d+(?:_\d+)*)`)},
+		{"Android", regexp.MustCompile(`Android (\d+(?:\.\d+)*)`)},
+		{"Windows", regexp.MustCompile(`Windows NT (\d+\.\d+)`)},
+		{"macOS", regexp.MustCompile(`Mac OS X (\d+(?:[_.]\d+)*)`)},
+		{"ChromeOS", regexp.MustCompile(`CrOS \S+ (\d+(?:\.\d+)*)`)},
+		{"Linux", regexp.MustCompile(`Linux()`)},
+	}
+
+	// browserPatterns match the browser of a user agent, in order, the first group being its version.
+	browserPatterns = []struct {
+		name string
+		re   *regexp.Regexp
+	}{
+		{"Edge", regexp.MustCompile(`Edg(?:e|A|iOS)?/(\d+(?:\.\d+)*)`)},
+		{"Opera", regexp.MustCompile(`(?:OPR|Opera)/(\d+(?:\.\d+)*)`)},
+		{"Samsung Internet", regexp.MustCompile(`SamsungBrowser/(\d+(?:\.\d+)*)`)},
+		{"Firefox", regexp.MustCompile(`(?:Firefox|FxiOS)/(\d+(?:\.\d+)*)`)},
+		{"Chrome", regexp.MustCompile(`(?:Chrome|CriOS)/(\d+(?:\.\d+)*)`)},
+		{"Safari", regexp.MustCompile(`Version/(\d+(?:\.\d+)*).*Safari/`)},
+	}
+
+	// productPattern matches the first product of a user agent, e.g. curl/8.4.0.
+	productPattern = regexp.MustCompile(`^([A-Za-z][\w.-]*)/(\S+)`)
+
+	botPattern = regexp.MustCompile(`(?i)bot\b|crawler|spider|slurp|headless`)
+)
+
+type (
+	// Geo is the geo information of the caller injected by the gateway, e.g. its ip and country.
+	Geo map[string]string
+
+	// DeviceInfo is the device of the caller parsed from its user agent. Unknown values are empty.
+	DeviceInfo struct {
+		UserAgent      string `json:"userAgent"`
+		Browser        string `json:"browser,omitempty"`
+		BrowserVersion string `json:"browserVersion,omitempty"`
+		OS             string `json:"os,omitempty"`
+		OSVersion      string `json:"osVersion,omitempty"`
+		Mobile         bool   `json:"mobile"`
+		Tablet         bool   `json:"tablet"`
+		Bot            bool   `json:"bot"`
+	}
+
+	// clientInfo finds the address of the client of a request behind trusted proxies.
+	clientInfo struct {
+		trusted []netip.Prefix
+	}
+)
+
+// IP returns the ip of the caller.
+func (g Geo) IP() string {
+	return g[GeoIP]
+}
+
+// Country returns the country of the caller.
+func (g Geo) Country() string {
+	return g[GeoCountry]
+}
+
+// Region returns the region of the caller.
+func (g Geo) Region() string {
+	return g[GeoRegion]
+}
+
+// City returns the city of the caller.
+func (g Geo) City() string {
+	return g[GeoCity]
+}
+
+// Addr returns the ip of the caller parsed, false when it is missing or invalid.
+func (g Geo) Addr() (netip.Addr, bool) {
+	addr, err := netip.ParseAddr(g.IP())
+	return addr, err == nil
+}
+
+// ParseDevice parses a user agent, as injected by the gateway in the device of the context data. It recognizes the
+// common browsers and operating systems; other clients, e.g. curl, are reported by their product name.
+func ParseDevice(userAgent string) DeviceInfo {
+	d := DeviceInfo{UserAgent: userAgent}
+	for _, p := range osPatterns {
+		if m := p.re.FindStringSubmatch(userAgent); m != nil {
+			d.OS, d.OSVersion = p.name, strings.ReplaceAll(m[1], "_", ".")
+			break
+		}
+	}
+	for _, p := range browserPatterns {
+		if m := p.re.FindStringSubmatch(userAgent); m != nil {
+			d.Browser, d.BrowserVersion = p.name, m[1]
+			break
+		}
+	}
+	if d.Browser == "" && !strings.HasPrefix(userAgent, "Mozilla/") {
+		if m := productPattern.FindStringSubmatch(userAgent); m != nil {
+			d.Browser, d.BrowserVersion = m[1], m[2]
+		}
+	}
+	d.Tablet = strings.Contains(userAgent, "iPad") || strings.Contains(userAgent, "Tablet") ||
+		(d.OS == "Android" && !strings.Contains(userAgent, "Mobile"))
+	d.Mobile = !d.Tablet && (strings.Contains(userAgent, "Mobi") || strings.Contains(userAgent, "iPhone"))
+	d.Bot = botPattern.MatchString(userAgent)
+	return d
+}
+
+// DeviceInfo returns the device of the caller parsed from the injected device.
+func (c ContextData) DeviceInfo() DeviceInfo {
+	return ParseDevice(c.Device)
+}
+
+// WithClientInfo fills the geo ip and the device of the context data when the gateway did not inject them, e.g.
+// when the service runs without the gateway: the ip from X-Forwarded-For or the remote address, and the device from
+// the User-Agent header. Requests without injected object then get context data with only these, unless Strict
+// rejects them. X-Forwarded-For is only trusted when the request comes from one of trustedProxies, ips or CIDR
+// prefixes, the client being the last address that is not a trusted proxy. WithClientInfo panics when a trusted
+// proxy is invalid.
+func WithClientInfo(trustedProxies ...string) MiddlewareOption {
+	info := &clientInfo{}
+	for _, p := range trustedProxies {
+		if !strings.Contains(p, "/") {
+			addr := netip.MustParseAddr(p)
+			info.trusted = append(info.trusted, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		info.trusted = append(info.trusted, netip.MustParsePrefix(p))
+	}
+	return func(o *middlewareOptions) {
+		o.clientInfo = info
+	}
+}
+
+// fill sets the geo ip and the device of the context data from the request, keeping the injected ones.
+func (ci *clientInfo) fill(c *ContextData, r *http.Request) {
+	if c.Geo.IP() == "" {
+		if ip := ci.clientIP(r); ip != "" {
+			geo := make(Geo, len(c.Geo)+1)
+			for k, v := range c.Geo {
+				geo[k] = v
+			}
+			geo[GeoIP] = ip
+			c.Geo = geo
+		}
+	}
+	if c.Device == "" {
+		c.Device = r.UserAgent()
+	}
+}
+
+// clientIP returns the address of the client of the request, empty when it is unknown.
+func (ci *clientInfo) clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return ""
+	}
+	addr = addr.Unmap()
+	if !ci.isTrusted(addr) {
+		return addr.String()
+	}
+	var hops []string
+	for _, h := range r.Header.Values(HeaderForwardedFor) {
+		hops = append(hops, strings.Split(h, ",")...)
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		addr = hop.Unmap()
+		if !ci.isTrusted(addr) {
+			break
+		}
+	}
+	return addr.String()
+}
+
+func (ci *clientInfo) isTrusted(addr netip.Addr) bool {
+	for _, p := range ci.trusted {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
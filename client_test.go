@@ -0,0 +1,211 @@
+package soajsgo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGeo(t *testing.T) {
+	g := Geo{"ip": "2001:db8::1", "country": "LB", "region": "Beirut", "city": "Beirut"}
+	assert.Equal(t, "2001:db8::1", g.IP())
+	assert.Equal(t, "LB", g.Country())
+	assert.Equal(t, "Beirut", g.Region())
+	assert.Equal(t, "Beirut", g.City())
+	addr, ok := g.Addr()
+	assert.True(t, ok)
+	assert.Equal(t, netip.MustParseAddr("2001:db8::1"), addr)
+
+	var empty Geo
+	assert.Empty(t, empty.IP())
+	_, ok = empty.Addr()
+	assert.False(t, ok)
+}
+
+func TestParseDevice(t *testing.T) {
+	tt := []struct {
+		name     string
+		ua       string
+		expected DeviceInfo
+	}{
+		{
+			name:     "chrome on windows",
+			ua:       "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			expected: DeviceInfo{Browser: "Chrome", BrowserVersion: "120.0.0.0", OS: "Windows", OSVersion: "10.0"},
+		},
+		{
+			name:     "edge on windows",
+			ua:       "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91",
+			expected: DeviceInfo{Browser: "Edge", BrowserVersion: "120.0.2210.91", OS: "Windows", OSVersion: "10.0"},
+		},
+		{
+			name:     "safari on iphone",
+			ua:       "Mozilla/5.0 (iPhone; CPU iPhone OS 17_1_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1.2 Mobile/15E148 Safari/604.1",
+			expected: DeviceInfo{Browser: "Safari", BrowserVersion: "17.1.2", OS: "iOS", OSVersion: "17.1.2", Mobile: true},
+		},
+		{
+			name:     "safari on ipad",
+			ua:       "Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.6 Mobile/15E148 Safari/604.1",
+			expected: DeviceInfo{Browser: "Safari", BrowserVersion: "16.6", OS: "iPadOS", OSVersion: "16.6", Tablet: true},
+		},
+		{
+			name:     "firefox on macos",
+			ua:       "Mozilla/5.0 (Macintosh; Intel Mac OS X 10.15; rv:121.0) Gecko/20100101 Firefox/121.0",
+			expected: DeviceInfo{Browser: "Firefox", BrowserVersion: "121.0", OS: "macOS", OSVersion: "10.15"},
+		},
+		{
+			name:     "samsung on android phone",
+			ua:       "Mozilla/5.0 (Linux; Android 13; SM-S911B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/23.0 Chrome/115.0.0.0 Mobile Safari/537.36",
+			expected: DeviceInfo{Browser: "Samsung Internet", BrowserVersion: "23.0", OS: "Android", OSVersion: "13", Mobile: true},
+		},
+		{
+			name:     "chrome on android tablet",
+			ua:       "Mozilla/5.0 (Linux; Android 12; SM-X700) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			expected: DeviceInfo{Browser: "Chrome", BrowserVersion: "120.0.0.0", OS: "Android", OSVersion: "12", Tablet: true},
+		},
+		{
+			name:     "opera on linux",
+			ua:       "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 OPR/106.0.0.0",
+			expected: DeviceInfo{Browser: "Opera", BrowserVersion: "106.0.0.0", OS: "Linux"},
+		},
+		{
+			name:     "googlebot",
+			ua:       "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			expected: DeviceInfo{Bot: true},
+		},
+		{
+			name:     "curl",
+			ua:       "curl/8.4.0",
+			expected: DeviceInfo{Browser: "curl", BrowserVersion: "8.4.0"},
+		},
+		{
+			name:     "legacy device",
+			ua:       "iPhone",
+			expected: DeviceInfo{Mobile: true},
+		},
+		{name: "empty"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.expected.UserAgent = tc.ua
+			assert.Equal(t, tc.expected, ParseDevice(tc.ua))
+			assert.Equal(t, tc.expected, ContextData{Device: tc.ua}.DeviceInfo())
+		})
+	}
+}
+
+func TestClientInfo_clientIP(t *testing.T) {
+	tt := []struct {
+		name       string
+		trusted    []string
+		remoteAddr string
+		forwarded  []string
+		expectedIP string
+	}{
+		{name: "remote address", remoteAddr: "203.0.113.7:5000", expectedIP: "203.0.113.7"},
+		{name: "untrusted forwarded for", remoteAddr: "203.0.113.7:5000", forwarded: []string{"198.51.100.1"}, expectedIP: "203.0.113.7"},
+		{name: "trusted proxy", trusted: []string{"10.0.0.0/8"}, remoteAddr: "10.0.0.2:5000", forwarded: []string{"198.51.100.1"}, expectedIP: "198.51.100.1"},
+		{name: "proxy chain", trusted: []string{"10.0.0.0/8", "192.0.2.1"}, remoteAddr: "10.0.0.2:5000",
+			forwarded: []string{"6.6.6.6, 198.51.100.1, 192.0.2.1", "10.1.1.1"}, expectedIP: "198.51.100.1"},
+		{name: "all trusted", trusted: []string{"10.0.0.0/8"}, remoteAddr: "10.0.0.2:5000", forwarded: []string{"10.0.0.3"}, expectedIP: "10.0.0.3"},
+		{name: "invalid hop", trusted: []string{"10.0.0.0/8"}, remoteAddr: "10.0.0.2:5000", forwarded: []string{"198.51.100.1, unknown"}, expectedIP: "10.0.0.2"},
+		{name: "no forwarded for", trusted: []string{"10.0.0.0/8"}, remoteAddr: "10.0.0.2:5000", expectedIP: "10.0.0.2"},
+		{name: "ipv6", trusted: []string{"::1"}, remoteAddr: "[::1]:5000", forwarded: []string{"2001:db8::7"}, expectedIP: "2001:db8::7"},
+		{name: "mapped ipv4", remoteAddr: "[::ffff:203.0.113.7]:5000", expectedIP: "203.0.113.7"},
+		{name: "invalid remote address", remoteAddr: "pipe", expectedIP: ""},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var o middlewareOptions
+			WithClientInfo(tc.trusted...)(&o)
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tc.remoteAddr
+			for _, f := range tc.forwarded {
+				req.Header.Add(HeaderForwardedFor, f)
+			}
+			assert.Equal(t, tc.expectedIP, o.clientInfo.clientIP(req))
+		})
+	}
+	assert.Panics(t, func() { WithClientInfo("10.0.0.0/33") })
+	assert.Panics(t, func() { WithClientInfo("proxy") })
+}
+
+func TestRegistry_MiddlewareWith_ClientInfo(t *testing.T) {
+	tt := []struct {
+		name           string
+		opts           []MiddlewareOption
+		header         string
+		expectedStatus int
+		expectedData   *ContextData
+	}{
+		{
+			name:           "without gateway",
+			opts:           []MiddlewareOption{WithClientInfo("10.0.0.0/8")},
+			expectedStatus: http.StatusOK,
+			expectedData:   &ContextData{Geo: Geo{"ip": "198.51.100.1"}, Device: "curl/8.4.0"},
+		},
+		{
+			name:           "without gateway and with acl",
+			opts:           []MiddlewareOption{WithClientInfo("10.0.0.0/8"), WithACL("users", "1")},
+			expectedStatus: http.StatusOK,
+			expectedData:   &ContextData{Geo: Geo{"ip": "198.51.100.1"}, Device: "curl/8.4.0"},
+		},
+		{
+			name:           "without gateway and strict",
+			opts:           []MiddlewareOption{WithClientInfo("10.0.0.0/8"), Strict()},
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "injected kept",
+			opts:           []MiddlewareOption{WithClientInfo("10.0.0.0/8")},
+			header:         `{"tenant":{"code":"TNT"},"device":"iPhone","geo":{"ip":"203.0.113.7","country":"LB"}}`,
+			expectedStatus: http.StatusOK,
+			expectedData:   &ContextData{Tenant: Tenant{Code: "TNT"}, Geo: Geo{"ip": "203.0.113.7", "country": "LB"}, Device: "iPhone"},
+		},
+		{
+			name:           "injected completed",
+			opts:           []MiddlewareOption{WithClientInfo("10.0.0.0/8")},
+			header:         `{"tenant":{"code":"TNT"},"geo":{"country":"LB"}}`,
+			expectedStatus: http.StatusOK,
+			expectedData:   &ContextData{Tenant: Tenant{Code: "TNT"}, Geo: Geo{"ip": "198.51.100.1", "country": "LB"}, Device: "curl/8.4.0"},
+		},
+		{
+			name:           "without option",
+			expectedStatus: http.StatusOK,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := &Registry{}
+			var data *ContextData
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if d, ok := FromContext(r.Context()); ok {
+					data = &d
+				}
+			})
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			req.RemoteAddr = "10.0.0.2:5000"
+			req.Header.Set(HeaderForwardedFor, "198.51.100.1")
+			req.Header.Set("User-Agent", "curl/8.4.0")
+			if tc.header != "" {
+				req.Header.Set(HeaderInjectObj, tc.header)
+			}
+			rec := httptest.NewRecorder()
+			reg.MiddlewareWith(tc.opts...)(handler).ServeHTTP(rec, req)
+			require.Equal(t, tc.expectedStatus, rec.Code)
+			if tc.expectedData == nil {
+				assert.Nil(t, data)
+				return
+			}
+			require.NotNil(t, data)
+			assert.Equal(t, tc.expectedData.Tenant.Code, data.Tenant.Code)
+			assert.Equal(t, tc.expectedData.Geo, data.Geo)
+			assert.Equal(t, tc.expectedData.Device, data.Device)
+			assert.Same(t, reg, data.Reg)
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	soajsgo "github.com/soajs/soajs.golang"
+)
+
+// validate validates the config file.
+func validate(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) != 1 {
+		return usageError("expected one config file")
+	}
+	config, err := readConfig(args[0], stdin)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(stdout, "config of %s is valid\n", config.ServiceName)
+	return nil
+}
+
+// register prints the payload registering the service of the config file.
+func register(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("register", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	dryRun := flags.Bool("dry-run", false, "print the payload without calling the controller")
+	if err := flags.Parse(args); err != nil {
+		return usageError("%v", err)
+	}
+	if !*dryRun {
+		return usageError("only -dry-run is supported, services register themselves when they start")
+	}
+	if flags.NArg() != 1 {
+		return usageError("expected one config file")
+	}
+	config, err := readConfig(flags.Arg(0), stdin)
+	if err != nil {
+		return err
+	}
+	payload, err := config.RegisterPayload()
+	if err != nil {
+		return err
+	}
+	return writeJSON(stdout, json.RawMessage(payload))
+}
+
+// readConfig reads and validates the config file.
+func readConfig(path string, stdin io.Reader) (soajsgo.Config, error) {
+	b, err := readInput(path, stdin)
+	if err != nil {
+		return soajsgo.Config{}, err
+	}
+	var config soajsgo.Config
+	if err := json.Unmarshal(b, &config); err != nil {
+		return soajsgo.Config{}, fmt.Errorf("could not parse config: %v", err)
+	}
+	if err := config.Validate(); err != nil {
+		return soajsgo.Config{}, err
+	}
+	return config, nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	soajsgo "github.com/soajs/soajs.golang"
+)
+
+// redacted replaces the secrets soajsctl prints, as soajsgo does.
+const redacted = "[REDACTED]"
+
+// header decodes or encodes a soajsinjectobj header.
+func header(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return usageError("expected header decode or header encode")
+	}
+	switch args[0] {
+	case "decode":
+		return headerDecode(args[1:], stdin, stdout)
+	case "encode":
+		return headerEncode(args[1:], stdin, stdout)
+	}
+	return usageError("unknown header command %q", args[0])
+}
+
+// headerDecode prints the context data of a header value, keys redacted unless -unredacted is set.
+func headerDecode(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("header decode", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	unredacted := flags.Bool("unredacted", false, "print the tenant keys in clear text")
+	if err := flags.Parse(args); err != nil {
+		return usageError("%v", err)
+	}
+	if flags.NArg() != 1 {
+		return usageError("expected one header value, - for stdin")
+	}
+	data, err := decodeHeader(flags.Arg(0), stdin)
+	if err != nil {
+		return err
+	}
+	if *unredacted {
+		return writeJSON(stdout, data.Unredacted())
+	}
+	return writeJSON(stdout, data)
+}
+
+// headerEncode prints the header value of the context data file. It refuses context data holding redacted secrets,
+// which would be encoded as the keys of the header.
+func headerEncode(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) != 1 {
+		return usageError("expected one context data file, - for stdin")
+	}
+	b, err := readInput(args[0], stdin)
+	if err != nil {
+		return err
+	}
+	if bytes.Contains(b, []byte(redacted)) {
+		return fmt.Errorf("context data holds %s secrets, decode the header with header decode -unredacted to encode it again", redacted)
+	}
+	var data soajsgo.ContextData
+	if err := json.Unmarshal(b, &data); err != nil {
+		return fmt.Errorf("could not parse context data: %v", err)
+	}
+	value, err := data.InjectObj()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, value)
+	return nil
+}
+
+// connect prints how the context data of a header resolves a service, the key header and the keys of the injected
+// object redacted unless -unredacted is set.
+func connect(args []string, stdin io.Reader, stdout io.Writer) error {
+	flags := flag.NewFlagSet("connect", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	value := flags.String("header", "-", "soajsinjectobj header value, - for stdin")
+	unredacted := flags.Bool("unredacted", false, "print the tenant keys in clear text")
+	if err := flags.Parse(args); err != nil {
+		return usageError("%v", err)
+	}
+	if flags.NArg() < 1 || flags.NArg() > 2 {
+		return usageError("expected a service and an optional version")
+	}
+	data, err := decodeHeader(*value, stdin)
+	if err != nil {
+		return err
+	}
+	conn := data.Connect(flags.Args()...)
+	if *unredacted {
+		return writeJSON(stdout, conn)
+	}
+	conn, err = redactConnect(conn)
+	if err != nil {
+		return err
+	}
+	return writeJSON(stdout, conn)
+}
+
+// redactConnect redacts the key and access token headers of a connect response and the tenant keys of its
+// injected object, re-encoding its tenant, key and urac with the redacting types of soajsgo.
+func redactConnect(conn soajsgo.Connect) (soajsgo.Connect, error) {
+	for _, h := range []*string{&conn.Headers.Key, &conn.Headers.AccessToken} {
+		if *h != "" {
+			*h = redacted
+		}
+	}
+	if conn.Headers.SoajsInjectobj == nil {
+		return conn, nil
+	}
+	b, err := json.Marshal(conn.Headers.SoajsInjectobj)
+	if err != nil {
+		return soajsgo.Connect{}, fmt.Errorf("could not encode injected object: %v", err)
+	}
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return soajsgo.Connect{}, fmt.Errorf("could not decode injected object: %v", err)
+	}
+	for field, v := range map[string]interface{}{"tenant": &soajsgo.Tenant{}, "key": &soajsgo.Key{}, "urac": &soajsgo.Urac{}} {
+		raw, ok := obj[field]
+		if !ok {
+			continue
+		}
+		if err := json.Unmarshal(raw, v); err != nil {
+			return soajsgo.Connect{}, fmt.Errorf("could not decode %s of injected object: %v", field, err)
+		}
+		if obj[field], err = json.Marshal(v); err != nil {
+			return soajsgo.Connect{}, fmt.Errorf("could not encode %s of injected object: %v", field, err)
+		}
+	}
+	conn.Headers.SoajsInjectobj = obj
+	return conn, nil
+}
+
+// decodeHeader parses the header value, read from stdin when value is "-".
+func decodeHeader(value string, stdin io.Reader) (soajsgo.ContextData, error) {
+	if value == "-" {
+		b, err := readInput(value, stdin)
+		if err != nil {
+			return soajsgo.ContextData{}, err
+		}
+		value = strings.TrimSpace(string(b))
+	}
+	var reg *soajsgo.Registry
+	return reg.ContextDataFromHeader(value)
+}
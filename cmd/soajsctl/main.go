@@ -0,0 +1,105 @@
+// Command soajsctl inspects and validates SOAJS service configurations and what the controller returns for them.
+//
+// Usage:
+//
+//	soajsctl validate <config.json>
+//	soajsctl registry get -service <name> [-env <code>] [-type <type>] [-controller <host:port>]
+//	soajsctl register -dry-run <config.json>
+//	soajsctl header decode [-unredacted] <value|->
+//	soajsctl header encode <context.json|->
+//	soajsctl connect [-header <value|->] [-unredacted] <service> [version]
+//
+// Configurations are the JSON form of soajsgo.Config. The controller address defaults to SOAJS_REGISTRY_API and the
+// environment to SOAJS_ENV. Registries, context data and connect headers are printed with their secrets redacted.
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+)
+
+const usage = `usage: soajsctl <command> [arguments]
+
+commands:
+  validate <config.json>                 validate a service config
+  registry get -service <name> [flags]   print the registry the controller returns, secrets redacted
+  register -dry-run <config.json>        print the payload registering the service
+  header decode <value|->                decode a soajsinjectobj header into context data
+  header encode <context.json|->         encode context data into a soajsinjectobj header
+  connect [-header <value|->] [-unredacted] <service> [version]
+                                         show how ContextData.Connect resolves a service
+`
+
+// errUsage reports a command line the commands can not run.
+var errUsage = errors.New("invalid usage")
+
+// command runs a subcommand with its arguments.
+type command func(args []string, stdin io.Reader, stdout io.Writer) error
+
+var commands = map[string]command{
+	"validate": validate,
+	"registry": registry,
+	"register": register,
+	"header":   header,
+	"connect":  connect,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run runs the command line and returns the exit code: 0 on success, 1 on failure and 2 on invalid usage.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return 2
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "soajsctl: unknown command %q\n\n%s", args[0], usage)
+		return 2
+	}
+	if err := cmd(args[1:], stdin, stdout); err != nil {
+		if errors.Is(err, errUsage) {
+			fmt.Fprintf(stderr, "soajsctl %s: %v\n\n%s", args[0], err, usage)
+			return 2
+		}
+		fmt.Fprintf(stderr, "soajsctl %s: %v\n", args[0], err)
+		return 1
+	}
+	return 0
+}
+
+// usageError returns an invalid usage error.
+func usageError(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", errUsage, fmt.Sprintf(format, args...))
+}
+
+// readInput reads the file at path, or stdin when path is "-".
+func readInput(path string, stdin io.Reader) ([]byte, error) {
+	if path == "-" {
+		b, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, fmt.Errorf("could not read stdin: %v", err)
+		}
+		return b, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read %s: %v", path, err)
+	}
+	return b, nil
+}
+
+// writeJSON writes v as indented JSON.
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return fmt.Errorf("could not encode output: %v", err)
+	}
+	return nil
+}
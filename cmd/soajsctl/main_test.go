@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	soajsgo "github.com/soajs/soajs.golang"
+	"github.com/soajs/soajs.golang/soajstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testConfig = `{"name":"users","group":"g","port":4000,"type":"service","version":"1",
+"maintenance":{"port":{"type":"inherit"},"readiness":"/heartbeat"}}`
+
+const testHeader = `{"tenant":{"id":"t1","code":"TNT1"},"key":{"iKey":"ikey","eKey":"ekey"},
+"awareness":{"host":"gateway","port":4000,"interConnect":[{"name":"urac","version":"2","latest":"2","host":"urac","port":4001}]}}`
+
+func writeFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "file.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestRun(t *testing.T) {
+	config := writeFile(t, testConfig)
+	invalid := writeFile(t, `{"name":"users"}`)
+	tt := []struct {
+		name           string
+		args           []string
+		stdin          string
+		expectedCode   int
+		expectedOut    string
+		expectedErrOut string
+	}{
+		{name: "no command", expectedCode: 2, expectedErrOut: "usage: soajsctl"},
+		{name: "unknown command", args: []string{"deploy"}, expectedCode: 2, expectedErrOut: `unknown command "deploy"`},
+		{name: "validate", args: []string{"validate", config}, expectedOut: "config of users is valid\n"},
+		{name: "validate stdin", args: []string{"validate", "-"}, stdin: testConfig, expectedOut: "config of users is valid\n"},
+		{name: "validate invalid", args: []string{"validate", invalid}, expectedCode: 1, expectedErrOut: "soajsctl validate: could not find [Type] in your config, type is <required>\n"},
+		{name: "validate missing file", args: []string{"validate", "missing.json"}, expectedCode: 1, expectedErrOut: "could not read missing.json"},
+		{name: "validate bad json", args: []string{"validate", "-"}, stdin: "{", expectedCode: 1, expectedErrOut: "could not parse config"},
+		{name: "validate no file", args: []string{"validate"}, expectedCode: 2, expectedErrOut: "expected one config file"},
+		{name: "register dry run", args: []string{"register", "-dry-run", config}, expectedOut: `"name": "users"`},
+		{name: "register double dash", args: []string{"register", "--dry-run", config}, expectedOut: `"apiList": []`},
+		{name: "register without dry run", args: []string{"register", config}, expectedCode: 2, expectedErrOut: "only -dry-run is supported"},
+		{name: "register invalid", args: []string{"register", "-dry-run", invalid}, expectedCode: 1, expectedErrOut: "could not find [Type]"},
+		{name: "header decode", args: []string{"header", "decode", testHeader}, expectedOut: `"eKey": "[REDACTED]"`},
+		{name: "header decode unredacted", args: []string{"header", "decode", "-unredacted", "-"}, stdin: testHeader, expectedOut: `"eKey": "ekey"`},
+		{name: "header decode invalid", args: []string{"header", "decode", "null"}, expectedCode: 1, expectedErrOut: "SOAJS header is empty or null"},
+		{name: "header unknown", args: []string{"header", "sign"}, expectedCode: 2, expectedErrOut: `unknown header command "sign"`},
+		{name: "header encode", args: []string{"header", "encode", "-"}, stdin: `{"tenant":{"id":"t1","key":{"iKey":"ikey"}},"device":"curl"}`, expectedOut: `"iKey":"ikey"`},
+		{name: "connect mesh", args: []string{"connect", "-header", testHeader, "urac"}, expectedOut: `"host": "urac:4001"`},
+		{name: "connect mesh redacted", args: []string{"connect", "-header", testHeader, "urac"}, expectedOut: `"iKey": "[REDACTED]"`},
+		{name: "connect mesh unredacted", args: []string{"connect", "-unredacted", "-header", testHeader, "urac"}, expectedOut: `"iKey": "ikey"`},
+		{name: "connect gateway", args: []string{"connect", "urac", "1"}, stdin: testHeader, expectedOut: `"key": "[REDACTED]"`},
+		{name: "connect gateway unredacted", args: []string{"connect", "-unredacted", "urac", "1"}, stdin: testHeader, expectedOut: `"key": "ekey"`},
+		{name: "connect no service", args: []string{"connect", "-header", testHeader}, expectedCode: 2, expectedErrOut: "expected a service"},
+		{name: "registry without get", args: []string{"registry"}, expectedCode: 2, expectedErrOut: "expected registry get"},
+		{name: "registry without service", args: []string{"registry", "get", "-env", "dev"}, expectedCode: 2, expectedErrOut: "-service and -env are required"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(tc.args, strings.NewReader(tc.stdin), &stdout, &stderr)
+			assert.Equal(t, tc.expectedCode, code, stderr.String())
+			assert.Contains(t, stdout.String(), tc.expectedOut)
+			assert.Contains(t, stderr.String(), tc.expectedErrOut)
+		})
+	}
+}
+
+func TestRun_HeaderRoundTrip(t *testing.T) {
+	var decoded, encoded bytes.Buffer
+	require.Equal(t, 0, run([]string{"header", "decode", "-unredacted", testHeader}, nil, &decoded, os.Stderr))
+	require.Equal(t, 0, run([]string{"header", "encode", "-"}, &decoded, &encoded, os.Stderr))
+
+	var data soajsgo.ContextData
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimSpace(encoded.String())), &data))
+	assert.Equal(t, "t1", data.Tenant.ID)
+	assert.Equal(t, "TNT1", data.Tenant.Code)
+	assert.Equal(t, "gateway", data.Awareness.Host)
+}
+
+func TestRun_HeaderEncodeRedacted(t *testing.T) {
+	var decoded, stdout, stderr bytes.Buffer
+	require.Equal(t, 0, run([]string{"header", "decode", testHeader}, nil, &decoded, os.Stderr))
+	code := run([]string{"header", "encode", "-"}, &decoded, &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Empty(t, stdout.String())
+	assert.Contains(t, stderr.String(), "header decode -unredacted")
+}
+
+func TestRun_RegistryGet(t *testing.T) {
+	controller := soajstest.StartController()
+	defer controller.Close()
+	require.NoError(t, controller.SetRegistry("dev", &soajsgo.Registry{
+		Name:        "users",
+		Environment: "dev",
+		CoreDBs: map[string]soajsgo.Database{"main": {
+			Name:        "main",
+			Credentials: soajsgo.Credentials{Username: "admin", Password: "s3cr3t-pass"},
+		}},
+	}))
+	t.Setenv(soajsgo.EnvRegistryAPIAddress, "")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"registry", "get", "-service", "users", "-env", "DEV", "-controller", controller.Addr()}, nil, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Empty(t, os.Getenv(soajsgo.EnvRegistryAPIAddress), "the controller address must not be set in the environment")
+	assert.Contains(t, stdout.String(), `"environment": "dev"`)
+	assert.Contains(t, stdout.String(), `"password": "[REDACTED]"`)
+	assert.NotContains(t, stdout.String(), "s3cr3t-pass")
+	assert.Equal(t, "service", controller.Calls()[0].Query.Get("type"))
+
+	stdout.Reset()
+	stderr.Reset()
+	code = run([]string{"registry", "get", "-service", "users", "-env", "stg", "-controller", controller.Addr()}, nil, &stdout, &stderr)
+	assert.Equal(t, 1, code)
+	assert.Contains(t, stderr.String(), "no registry fixture for environment stg")
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"os"
+	"time"
+
+	soajsgo "github.com/soajs/soajs.golang"
+)
+
+// registry prints the registry the controller returns for a service, secrets redacted.
+func registry(args []string, _ io.Reader, stdout io.Writer) error {
+	if len(args) == 0 || args[0] != "get" {
+		return usageError("expected registry get")
+	}
+	flags := flag.NewFlagSet("registry get", flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	service := flags.String("service", "", "name of the service")
+	env := flags.String("env", os.Getenv(soajsgo.EnvSoajsEnv), "environment code, defaults to SOAJS_ENV")
+	serviceType := flags.String("type", "service", "type of the service")
+	controller := flags.String("controller", "", "host:port of the controller, defaults to SOAJS_REGISTRY_API")
+	timeout := flags.Duration("timeout", 30*time.Second, "timeout of the request to the controller")
+	if err := flags.Parse(args[1:]); err != nil {
+		return usageError("%v", err)
+	}
+	if *service == "" || *env == "" {
+		return usageError("-service and -env are required")
+	}
+	var opts []soajsgo.RegistryOption
+	if *controller != "" {
+		opts = append(opts, soajsgo.WithControllerAddress(*controller))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	reg, err := soajsgo.New(ctx, *service, *env, *serviceType, false, opts...)
+	if err != nil {
+		return err
+	}
+	return writeJSON(stdout, reg)
+}
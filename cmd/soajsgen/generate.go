@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// kind is the JSON type of a sample value.
+type kind int
+
+const (
+	kindNull kind = iota
+	kindBool
+	kindInt
+	kindFloat
+	kindString
+	kindObject
+	kindArray
+	kindAny
+)
+
+// initialisms are written upper case in Go names.
+var initialisms = map[string]bool{
+	"ACL": true, "API": true, "CPU": true, "DB": true, "DNS": true, "HTTP": true, "HTTPS": true, "ID": true,
+	"IP": true, "JSON": true, "SQL": true, "SSL": true, "TCP": true, "TLS": true, "TTL": true, "UDP": true,
+	"URI": true, "URL": true, "UUID": true,
+}
+
+type (
+	// shape is the type inferred from one or more sample values.
+	shape struct {
+		kind   kind
+		fields map[string]*shape
+		elem   *shape
+	}
+
+	// entry is a custom registry entry or a resource to generate a type and an accessor for.
+	entry struct {
+		name   string
+		shape  *shape
+		custom bool
+	}
+
+	// generator writes the Go source of the entries.
+	generator struct {
+		buf   bytes.Buffer
+		names map[string]bool
+	}
+)
+
+// shapeOf infers the shape of a value decoded with json.Decoder.UseNumber.
+func shapeOf(v interface{}) *shape {
+	switch v := v.(type) {
+	case nil:
+		return &shape{kind: kindNull}
+	case bool:
+		return &shape{kind: kindBool}
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return &shape{kind: kindInt}
+		}
+		return &shape{kind: kindFloat}
+	case string:
+		return &shape{kind: kindString}
+	case map[string]interface{}:
+		s := &shape{kind: kindObject, fields: make(map[string]*shape, len(v))}
+		for k, fv := range v {
+			s.fields[k] = shapeOf(fv)
+		}
+		return s
+	case []interface{}:
+		s := &shape{kind: kindArray}
+		for _, ev := range v {
+			s.elem = merge(s.elem, shapeOf(ev))
+		}
+		return s
+	}
+	return &shape{kind: kindAny}
+}
+
+// merge returns the shape matching the samples of a and b. nil is no sample.
+func merge(a, b *shape) *shape {
+	switch {
+	case a == nil:
+		return b
+	case b == nil:
+		return a
+	case a.kind == kindNull:
+		return b
+	case b.kind == kindNull:
+		return a
+	case a.kind == b.kind:
+	case (a.kind == kindInt || a.kind == kindFloat) && (b.kind == kindInt || b.kind == kindFloat):
+		return &shape{kind: kindFloat}
+	default:
+		return &shape{kind: kindAny}
+	}
+	switch a.kind {
+	case kindObject:
+		out := &shape{kind: kindObject, fields: make(map[string]*shape, len(a.fields))}
+		for k, f := range a.fields {
+			out.fields[k] = f
+		}
+		for k, f := range b.fields {
+			out.fields[k] = merge(out.fields[k], f)
+		}
+		return out
+	case kindArray:
+		return &shape{kind: kindArray, elem: merge(a.elem, b.elem)}
+	}
+	return a
+}
+
+// generate returns the formatted Go source of the package declaring the types and accessors of the entries.
+func generate(pkg, source string, entries []entry) ([]byte, error) {
+	g := &generator{names: make(map[string]bool)}
+	fmt.Fprintf(&g.buf, "// Code generated by soajsgen from %s. DO NOT EDIT.\n\n", source)
+	fmt.Fprintf(&g.buf, "package %s\n\n", pkg)
+	if len(entries) > 0 {
+		g.buf.WriteString("import soajsgo \"github.com/soajs/soajs.golang\"\n")
+	}
+	for _, e := range entries {
+		g.entry(e)
+	}
+	src, err := format.Source(g.buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("could not format generated code: %v", err)
+	}
+	return src, nil
+}
+
+// entry writes the type of the entry and its accessor.
+func (g *generator) entry(e entry) {
+	suffix, what, decode := "ResourceConfig", "config of the resource", "DecodeResourceConfig"
+	if e.custom {
+		suffix, what, decode = "Custom", "value of the custom registry", "DecodeCustom"
+	}
+	typeName := g.unique(goName(e.name) + suffix)
+	var nested []func()
+	var expr string
+	if e.shape != nil && e.shape.kind == kindObject && len(e.shape.fields) > 0 {
+		expr = g.structExpr(typeName, e.shape, &nested)
+	} else {
+		expr = g.fieldType(typeName, e.shape, &nested)
+	}
+	fmt.Fprintf(&g.buf, "\n// %s is the %s %s.\ntype %s %s\n", typeName, what, e.name, typeName, expr)
+	fmt.Fprintf(&g.buf, "\n// Get%s decodes the %s %s of the registry.\n", typeName, what, e.name)
+	fmt.Fprintf(&g.buf, "func Get%s(reg *soajsgo.Registry) (*%s, error) {\n", typeName, typeName)
+	fmt.Fprintf(&g.buf, "\tvar v %s\n\tif err := reg.%s(%q, &v); err != nil {\n\t\treturn nil, err\n\t}\n\treturn &v, nil\n}\n",
+		typeName, decode, e.name)
+	for len(nested) > 0 {
+		next := nested[0]
+		nested = nested[1:]
+		next()
+	}
+}
+
+// typeExpr returns the Go type of the shape. The objects nested in it are named after parent.
+func (g *generator) typeExpr(parent string, s *shape, nested *[]func()) string {
+	if s == nil {
+		return "interface{}"
+	}
+	switch s.kind {
+	case kindBool:
+		return "bool"
+	case kindInt:
+		return "int64"
+	case kindFloat:
+		return "float64"
+	case kindString:
+		return "string"
+	case kindArray:
+		return "[]" + g.typeExpr(parent+"Item", s.elem, nested)
+	case kindObject:
+		return g.fieldType(parent, s, nested)
+	}
+	return "interface{}"
+}
+
+// structExpr returns the struct type of an object shape. Its object fields become named types.
+func (g *generator) structExpr(parent string, s *shape, nested *[]func()) string {
+	keys := make([]string, 0, len(s.fields))
+	for k := range s.fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	b.WriteString("struct {\n")
+	fields := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		name := uniqueIn(fields, goName(k))
+		f := s.fields[k]
+		expr := g.fieldType(parent+name, f, nested)
+		fmt.Fprintf(&b, "%s %s `json:%q`\n", name, expr, k+",omitempty")
+	}
+	b.WriteString("}")
+	return b.String()
+}
+
+// fieldType returns the type of a field, declaring a named type for objects and arrays of objects.
+func (g *generator) fieldType(name string, s *shape, nested *[]func()) string {
+	depth, inner := "", s
+	for inner != nil && inner.kind == kindArray {
+		depth += "[]"
+		inner = inner.elem
+	}
+	if inner == nil || inner.kind != kindObject {
+		return g.typeExpr(name, s, nested)
+	}
+	if len(inner.fields) == 0 {
+		// An empty sample object tells nothing about its fields.
+		return depth + "map[string]interface{}"
+	}
+	if depth != "" {
+		name += "Item"
+	}
+	typeName := g.unique(name)
+	*nested = append(*nested, func() {
+		expr := g.structExpr(typeName, inner, nested)
+		fmt.Fprintf(&g.buf, "\n// %s is generated from a sample.\ntype %s %s\n", typeName, typeName, expr)
+	})
+	return depth + typeName
+}
+
+// unique returns name, suffixed with a number when already declared, and declares it.
+func (g *generator) unique(name string) string {
+	return uniqueIn(g.names, name)
+}
+
+func uniqueIn(names map[string]bool, name string) string {
+	out := name
+	for i := 2; names[out]; i++ {
+		out = fmt.Sprintf("%s%d", name, i)
+	}
+	names[out] = true
+	return out
+}
+
+// goName returns the exported Go name of a JSON key or registry name: words split on non alphanumeric characters
+// and case changes are capitalized, initialisms upper cased.
+func goName(s string) string {
+	var words []string
+	var word []rune
+	flush := func() {
+		if len(word) > 0 {
+			words = append(words, string(word))
+			word = nil
+		}
+	}
+	runes := []rune(s)
+	for i, r := range runes {
+		switch {
+		case !unicode.IsLetter(r) && !unicode.IsDigit(r):
+			flush()
+		case unicode.IsUpper(r) && i > 0 && (unicode.IsLower(runes[i-1]) ||
+			(i+1 < len(runes) && unicode.IsLower(runes[i+1]) && unicode.IsUpper(runes[i-1]))):
+			flush()
+			word = append(word, r)
+		default:
+			word = append(word, r)
+		}
+	}
+	flush()
+	var b strings.Builder
+	for _, w := range words {
+		if upper := strings.ToUpper(w); initialisms[upper] {
+			b.WriteString(upper)
+			continue
+		}
+		r := []rune(w)
+		b.WriteString(strings.ToUpper(string(r[0])) + string(r[1:]))
+	}
+	name := b.String()
+	if name == "" || unicode.IsDigit([]rune(name)[0]) {
+		name = "X" + name
+	}
+	return name
+}
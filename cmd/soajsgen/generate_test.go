@@ -0,0 +1,91 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGoName(t *testing.T) {
+	tt := []struct {
+		in       string
+		expected string
+	}{
+		{in: "host", expected: "Host"},
+		{in: "feature-flags", expected: "FeatureFlags"},
+		{in: "max_users", expected: "MaxUsers"},
+		{in: "maxPoolSize", expected: "MaxPoolSize"},
+		{in: "URLParam", expected: "URLParam"},
+		{in: "issuerURL", expected: "IssuerURL"},
+		{in: "client_id", expected: "ClientID"},
+		{in: "_id", expected: "ID"},
+		{in: "2fa", expected: "X2fa"},
+		{in: "", expected: "X"},
+		{in: "é-mail", expected: "ÉMail"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.in, func(t *testing.T) {
+			assert.Equal(t, tc.expected, goName(tc.in))
+		})
+	}
+}
+
+func TestShapeOf(t *testing.T) {
+	sample := func(s string) *shape {
+		var v interface{}
+		require.NoError(t, decodeNumbers([]byte(s), &v))
+		return shapeOf(v)
+	}
+	tt := []struct {
+		name     string
+		sample   string
+		expected string
+	}{
+		{name: "int", sample: `1`, expected: "int64"},
+		{name: "float", sample: `1.5`, expected: "float64"},
+		{name: "ints and floats", sample: `[1, 2.5]`, expected: "[]float64"},
+		{name: "null then string", sample: `[null, "a"]`, expected: "[]string"},
+		{name: "mixed", sample: `[1, "a"]`, expected: "[]interface{}"},
+		{name: "empty array", sample: `[]`, expected: "[]interface{}"},
+		{name: "nested arrays", sample: `[[true]]`, expected: "[][]bool"},
+		{name: "null", sample: `null`, expected: "interface{}"},
+		{name: "empty object", sample: `{}`, expected: "map[string]interface{}"},
+		{name: "objects", sample: `[{"a": 1}, {"b": "x"}]`, expected: "[]TItem"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			g := &generator{names: make(map[string]bool)}
+			var nested []func()
+			assert.Equal(t, tc.expected, g.fieldType("T", sample(tc.sample), &nested))
+		})
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	var value interface{}
+	require.NoError(t, decodeNumbers([]byte(`{"user":{"name":"a","roles":[{"id":1}]},"user_name":"b","userName":"c"}`), &value))
+	src, err := generate("conf", "sample.json", []entry{
+		{name: "accounts", shape: shapeOf(value), custom: true},
+		{name: "accounts", shape: shapeOf(json.Number("1"))},
+	})
+	require.NoError(t, err)
+	out := string(src)
+	for _, expected := range []string{
+		"// Code generated by soajsgen from sample.json. DO NOT EDIT.\n\npackage conf\n",
+		"type AccountsCustom struct {\n\tUser      AccountsCustomUser `json:\"user,omitempty\"`\n\tUserName  string             `json:\"userName,omitempty\"`\n\tUserName2 string             `json:\"user_name,omitempty\"`\n}",
+		"type AccountsCustomUser struct {\n\tName  string                        `json:\"name,omitempty\"`\n\tRoles []AccountsCustomUserRolesItem `json:\"roles,omitempty\"`\n}",
+		"type AccountsCustomUserRolesItem struct {\n\tID int64 `json:\"id,omitempty\"`\n}",
+		"func GetAccountsCustom(reg *soajsgo.Registry) (*AccountsCustom, error) {\n\tvar v AccountsCustom\n\tif err := reg.DecodeCustom(\"accounts\", &v); err != nil {",
+		"type AccountsResourceConfig int64",
+		"reg.DecodeResourceConfig(\"accounts\", &v)",
+	} {
+		assert.Contains(t, out, expected)
+	}
+
+	src, err = generate("conf", "empty.json", nil)
+	require.NoError(t, err)
+	assert.False(t, strings.Contains(string(src), "import"), "no entries must not import soajsgo")
+}
@@ -0,0 +1,5 @@
+// Package example holds the code soajsgen generates from its testdata registry. Its tests check that the generated
+// code builds and decodes the registry.
+package example
+
+//go:generate go run ../.. -registry ../../testdata/registry.json -o registry_gen.go
@@ -0,0 +1,110 @@
+// Code generated by soajsgen from registry.json. DO NOT EDIT.
+
+package example
+
+import soajsgo "github.com/soajs/soajs.golang"
+
+// FeatureFlagsCustom is the value of the custom registry feature-flags.
+type FeatureFlagsCustom []string
+
+// GetFeatureFlagsCustom decodes the value of the custom registry feature-flags of the registry.
+func GetFeatureFlagsCustom(reg *soajsgo.Registry) (*FeatureFlagsCustom, error) {
+	var v FeatureFlagsCustom
+	if err := reg.DecodeCustom("feature-flags", &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// MailerCustom is the value of the custom registry mailer.
+type MailerCustom struct {
+	From       MailerCustomFrom             `json:"from,omitempty"`
+	Host       string                       `json:"host,omitempty"`
+	Port       int64                        `json:"port,omitempty"`
+	Recipients []MailerCustomRecipientsItem `json:"recipients,omitempty"`
+	TLS        bool                         `json:"tls,omitempty"`
+}
+
+// GetMailerCustom decodes the value of the custom registry mailer of the registry.
+func GetMailerCustom(reg *soajsgo.Registry) (*MailerCustom, error) {
+	var v MailerCustom
+	if err := reg.DecodeCustom("mailer", &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// MailerCustomFrom is generated from a sample.
+type MailerCustomFrom struct {
+	Email string `json:"email,omitempty"`
+	Name  string `json:"name,omitempty"`
+}
+
+// MailerCustomRecipientsItem is generated from a sample.
+type MailerCustomRecipientsItem struct {
+	ID     string   `json:"id,omitempty"`
+	Tags   []string `json:"tags,omitempty"`
+	Weight float64  `json:"weight,omitempty"`
+}
+
+// MaxUsersCustom is the value of the custom registry max_users.
+type MaxUsersCustom int64
+
+// GetMaxUsersCustom decodes the value of the custom registry max_users of the registry.
+func GetMaxUsersCustom(reg *soajsgo.Registry) (*MaxUsersCustom, error) {
+	var v MaxUsersCustom
+	if err := reg.DecodeCustom("max_users", &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// MongoResourceConfig is the config of the resource mongo.
+type MongoResourceConfig struct {
+	URLParam    MongoResourceConfigURLParam      `json:"URLParam,omitempty"`
+	Credentials MongoResourceConfigCredentials   `json:"credentials,omitempty"`
+	Servers     []MongoResourceConfigServersItem `json:"servers,omitempty"`
+	Streaming   map[string]interface{}           `json:"streaming,omitempty"`
+}
+
+// GetMongoResourceConfig decodes the config of the resource mongo of the registry.
+func GetMongoResourceConfig(reg *soajsgo.Registry) (*MongoResourceConfig, error) {
+	var v MongoResourceConfig
+	if err := reg.DecodeResourceConfig("mongo", &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// MongoResourceConfigURLParam is generated from a sample.
+type MongoResourceConfigURLParam struct {
+	MaxPoolSize        int64 `json:"maxPoolSize,omitempty"`
+	UseUnifiedTopology bool  `json:"useUnifiedTopology,omitempty"`
+}
+
+// MongoResourceConfigCredentials is generated from a sample.
+type MongoResourceConfigCredentials struct {
+	Password string `json:"password,omitempty"`
+	Username string `json:"username,omitempty"`
+}
+
+// MongoResourceConfigServersItem is generated from a sample.
+type MongoResourceConfigServersItem struct {
+	Host string `json:"host,omitempty"`
+	Port int64  `json:"port,omitempty"`
+}
+
+// OauthResourceConfig is the config of the resource oauth.
+type OauthResourceConfig struct {
+	ClientID  string `json:"client_id,omitempty"`
+	IssuerURL string `json:"issuerURL,omitempty"`
+}
+
+// GetOauthResourceConfig decodes the config of the resource oauth of the registry.
+func GetOauthResourceConfig(reg *soajsgo.Registry) (*OauthResourceConfig, error) {
+	var v OauthResourceConfig
+	if err := reg.DecodeResourceConfig("oauth", &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
@@ -0,0 +1,63 @@
+package example
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+
+	soajsgo "github.com/soajs/soajs.golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testRegistry(t *testing.T) *soajsgo.Registry {
+	t.Helper()
+	raw, err := os.ReadFile("../../testdata/registry.json")
+	require.NoError(t, err)
+	var res struct {
+		Data soajsgo.Registry `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(raw, &res))
+	return &res.Data
+}
+
+func TestGenerated(t *testing.T) {
+	reg := testRegistry(t)
+
+	mailer, err := GetMailerCustom(reg)
+	require.NoError(t, err)
+	assert.Equal(t, "smtp.local", mailer.Host)
+	assert.Equal(t, int64(25), mailer.Port)
+	assert.True(t, mailer.TLS)
+	assert.Equal(t, "users@soajs.org", mailer.From.Email)
+	require.Len(t, mailer.Recipients, 2)
+	assert.Equal(t, 0.5, mailer.Recipients[1].Weight)
+	assert.Equal(t, []string{"ops"}, mailer.Recipients[1].Tags)
+
+	flags, err := GetFeatureFlagsCustom(reg)
+	require.NoError(t, err)
+	assert.Equal(t, FeatureFlagsCustom{"signup", "invite"}, *flags)
+
+	maxUsers, err := GetMaxUsersCustom(reg)
+	require.NoError(t, err)
+	assert.Equal(t, MaxUsersCustom(100), *maxUsers)
+
+	mongo, err := GetMongoResourceConfig(reg)
+	require.NoError(t, err)
+	assert.Equal(t, []MongoResourceConfigServersItem{{Host: "db.local", Port: 27017}}, mongo.Servers)
+	assert.Equal(t, "users", mongo.Credentials.Username)
+	assert.Equal(t, int64(5), mongo.URLParam.MaxPoolSize)
+
+	oauth, err := GetOauthResourceConfig(reg)
+	require.NoError(t, err)
+	assert.Equal(t, "https://auth.local", oauth.IssuerURL)
+	assert.Equal(t, "users", oauth.ClientID)
+}
+
+func TestGenerated_Missing(t *testing.T) {
+	reg := &soajsgo.Registry{}
+	_, err := GetMailerCustom(reg)
+	assert.Error(t, err)
+	_, err = GetMongoResourceConfig(reg)
+	assert.Error(t, err)
+}
@@ -0,0 +1,161 @@
+// Command soajsgen generates typed Go structs and accessors for the custom registry entries and the resource configs
+// of a SOAJS registry, so that a change of their shape in the console surfaces as a compile error.
+//
+// The registry is read from a file, either the registry object or a captured getRegistry response, or fetched from
+// a controller, e.g. the soajstest fake controller:
+//
+//	//go:generate go run github.com/soajs/soajs.golang/cmd/soajsgen -registry testdata/registry.json -o registry_gen.go
+//	soajsgen -controller localhost:5000 -service users -env dev -o registry_gen.go
+//
+// For each custom registry entry, say mailer, it declares MailerCustom and GetMailerCustom(reg). For each resource,
+// say mongo, MongoResourceConfig and GetMongoResourceConfig(reg). The types are inferred from the sample values:
+// objects become structs, numbers int64 or float64, and values of mixed or unknown type interface{}.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	soajsgo "github.com/soajs/soajs.golang"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+// run runs the command line and returns the exit code: 0 on success, 1 on failure and 2 on invalid usage.
+func run(args []string, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("soajsgen", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	registryFile := flags.String("registry", "", "registry JSON file, the registry object or a getRegistry response")
+	controller := flags.String("controller", "", "host:port of the controller to fetch the registry from")
+	service := flags.String("service", "", "name of the service, with -controller")
+	env := flags.String("env", os.Getenv(soajsgo.EnvSoajsEnv), "environment code, with -controller")
+	serviceType := flags.String("type", "service", "type of the service, with -controller")
+	out := flags.String("o", "", "output file, stdout when empty")
+	pkg := flags.String("package", os.Getenv("GOPACKAGE"), "package of the generated file, defaults to $GOPACKAGE")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if (*registryFile == "") == (*controller == "") || *pkg == "" {
+		fmt.Fprintln(stderr, "soajsgen: one of -registry and -controller, and -package or $GOPACKAGE, are required")
+		flags.Usage()
+		return 2
+	}
+
+	var (
+		raw    []byte
+		source string
+		err    error
+	)
+	if *registryFile != "" {
+		raw, err = os.ReadFile(*registryFile)
+		source = filepath.Base(*registryFile)
+	} else {
+		raw, err = fetchRegistry(*controller, *service, *env, *serviceType)
+		source = fmt.Sprintf("the %s registry of %s", *env, *service)
+	}
+	if err == nil {
+		err = writeSource(raw, source, *pkg, *out, stdout)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "soajsgen: %v\n", err)
+		return 1
+	}
+	return 0
+}
+
+// fetchRegistry fetches the registry from the controller and returns it unredacted.
+func fetchRegistry(controller, service, env, serviceType string) ([]byte, error) {
+	if service == "" || env == "" {
+		return nil, errors.New("-service and -env are required with -controller")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	reg, err := soajsgo.New(ctx, service, env, serviceType, false, soajsgo.WithControllerAddress(controller))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(reg.Unredacted())
+}
+
+// writeSource generates the source of the registry and writes it to the out file, or to stdout.
+func writeSource(raw []byte, source, pkg, out string, stdout io.Writer) error {
+	entries, err := registryEntries(raw)
+	if err != nil {
+		return err
+	}
+	src, err := generate(pkg, source, entries)
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		_, err = stdout.Write(src)
+		return err
+	}
+	if err := os.WriteFile(out, src, 0o644); err != nil {
+		return fmt.Errorf("could not write %s: %v", out, err)
+	}
+	return nil
+}
+
+// registryEntries returns the custom registry entries and the resources of the registry JSON, sorted by name.
+// Resources of the same name in several groups are merged.
+func registryEntries(raw []byte) ([]entry, error) {
+	var registry struct {
+		Result *bool           `json:"result"`
+		Data   json.RawMessage `json:"data"`
+		Custom map[string]struct {
+			Value interface{} `json:"value"`
+		} `json:"custom"`
+		Resources map[string]map[string]struct {
+			Config interface{} `json:"config"`
+		} `json:"resources"`
+	}
+	if err := decodeNumbers(raw, &registry); err != nil {
+		return nil, fmt.Errorf("could not parse registry: %v", err)
+	}
+	if registry.Result != nil {
+		if len(registry.Data) == 0 {
+			return nil, errors.New("could not parse registry: response has no data")
+		}
+		return registryEntries(registry.Data)
+	}
+
+	entries := make([]entry, 0, len(registry.Custom))
+	for name, c := range registry.Custom {
+		entries = append(entries, entry{name: name, shape: shapeOf(c.Value), custom: true})
+	}
+	resources := make(map[string]*shape)
+	for _, group := range registry.Resources {
+		for name, r := range group {
+			resources[name] = merge(resources[name], shapeOf(r.Config))
+		}
+	}
+	for name, s := range resources {
+		entries = append(entries, entry{name: name, shape: s})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].custom != entries[j].custom {
+			return entries[i].custom
+		}
+		return entries[i].name < entries[j].name
+	})
+	return entries, nil
+}
+
+// decodeNumbers decodes JSON keeping numbers as json.Number, to tell integers from floats.
+func decodeNumbers(raw []byte, v interface{}) error {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	return dec.Decode(v)
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	soajsgo "github.com/soajs/soajs.golang"
+	"github.com/soajs/soajs.golang/soajstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	t.Setenv("GOPACKAGE", "")
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain.json")
+	require.NoError(t, os.WriteFile(plain, []byte(`{"custom":{"limit":{"value":1.5}}}`), 0o600))
+	failed := filepath.Join(dir, "failed.json")
+	require.NoError(t, os.WriteFile(failed, []byte(`{"result":false}`), 0o600))
+
+	tt := []struct {
+		name           string
+		args           []string
+		expectedCode   int
+		expectedOut    string
+		expectedErrOut string
+	}{
+		{name: "no source", args: []string{"-package", "conf"}, expectedCode: 2, expectedErrOut: "one of -registry and -controller"},
+		{name: "both sources", args: []string{"-package", "conf", "-registry", plain, "-controller", "localhost:5000"}, expectedCode: 2, expectedErrOut: "one of -registry and -controller"},
+		{name: "no package", args: []string{"-registry", plain}, expectedCode: 2, expectedErrOut: "-package or $GOPACKAGE"},
+		{name: "unknown flag", args: []string{"-verbose"}, expectedCode: 2, expectedErrOut: "flag provided but not defined"},
+		{name: "plain registry", args: []string{"-package", "conf", "-registry", plain}, expectedOut: "type LimitCustom float64"},
+		{name: "missing file", args: []string{"-package", "conf", "-registry", filepath.Join(dir, "missing.json")}, expectedCode: 1, expectedErrOut: "missing.json"},
+		{name: "failed response", args: []string{"-package", "conf", "-registry", failed}, expectedCode: 1, expectedErrOut: "response has no data"},
+		{name: "controller without service", args: []string{"-package", "conf", "-controller", "localhost:5000", "-env", "dev"}, expectedCode: 1, expectedErrOut: "-service and -env are required"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(tc.args, &stdout, &stderr)
+			assert.Equal(t, tc.expectedCode, code, stderr.String())
+			assert.Contains(t, stdout.String(), tc.expectedOut)
+			assert.Contains(t, stderr.String(), tc.expectedErrOut)
+		})
+	}
+}
+
+func TestRun_Golden(t *testing.T) {
+	expected, err := os.ReadFile("internal/example/registry_gen.go")
+	require.NoError(t, err)
+
+	var stdout, stderr bytes.Buffer
+	require.Equal(t, 0, run([]string{"-registry", "testdata/registry.json", "-package", "example"}, &stdout, &stderr), stderr.String())
+	assert.Equal(t, string(expected), stdout.String(), "run go generate ./cmd/soajsgen/... to update the example")
+
+	out := filepath.Join(t.TempDir(), "registry_gen.go")
+	t.Setenv("GOPACKAGE", "example")
+	require.Equal(t, 0, run([]string{"-registry", "testdata/registry.json", "-o", out}, &stdout, &stderr), stderr.String())
+	written, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, string(expected), string(written))
+}
+
+func TestRun_Controller(t *testing.T) {
+	controller := soajstest.StartController()
+	defer controller.Close()
+	raw, err := os.ReadFile("testdata/registry.json")
+	require.NoError(t, err)
+	var res struct {
+		Data json.RawMessage `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(raw, &res))
+	controller.SetRegistryJSON("dev", res.Data)
+	t.Setenv(soajsgo.EnvRegistryAPIAddress, "")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-controller", controller.Addr(), "-service", "users", "-env", "dev", "-package", "conf"}, &stdout, &stderr)
+	require.Equal(t, 0, code, stderr.String())
+	assert.Contains(t, stdout.String(), "// Code generated by soajsgen from the dev registry of users. DO NOT EDIT.")
+	assert.Contains(t, stdout.String(), "func GetMongoResourceConfig(reg *soajsgo.Registry)")
+	assert.Contains(t, stdout.String(), "Password string `json:\"password,omitempty\"`")
+}
@@ -10,31 +10,36 @@ import (
 type (
 	// Config represent service configuration from json file.
 	Config struct {
-		ServiceName           string       `json:"name"`
-		ServiceGroup          string       `json:"group"`
-		ServicePort           int          `json:"port"`
-		ServiceIP             string       `json:"IP"`
-		Type                  string       `json:"type"`
-		ServiceVersion        string       `json:"version"`
-		SubType               string       `json:"subType"`
-		Description           string       `json:"description"`
-		Oauth                 bool         `json:"oauth"`
-		Urac                  bool         `json:"urac"`
-		UracProfile           bool         `json:"urac_Profile"`
-		UracACL               bool         `json:"urac_ACL"`
-		UracConfig            bool         `json:"urac_Config"`
-		UracGroupConfig       bool         `json:"urac_GroupConfig"`
-		TenantProfile         bool         `json:"tenant_Profile"`
-		ProvisionACL          bool         `json:"provision_ACL"`
-		ExtKeyRequired        bool         `json:"extKeyRequired"`
-		RequestTimeout        int          `json:"requestTimeout"`
-		RequestTimeoutRenewal int          `json:"requestTimeoutRenewal"`
-		Maintenance           maintenance  `json:"maintenance"`
-		InterConnect          interconnect `json:"interConnect"`
-		Prerequisites         struct {
-			CPU    string `json:"cpu"`
-			Memory string `json:"memory"`
-		} `json:"prerequisites"`
+		ServiceName           string        `json:"name"`
+		ServiceGroup          string        `json:"group"`
+		ServicePort           int           `json:"port"`
+		ServiceIP             string        `json:"IP"`
+		Type                  string        `json:"type"`
+		ServiceVersion        string        `json:"version"`
+		SubType               string        `json:"subType"`
+		Description           string        `json:"description"`
+		Oauth                 bool          `json:"oauth"`
+		Urac                  bool          `json:"urac"`
+		UracProfile           bool          `json:"urac_Profile"`
+		UracACL               bool          `json:"urac_ACL"`
+		UracConfig            bool          `json:"urac_Config"`
+		UracGroupConfig       bool          `json:"urac_GroupConfig"`
+		TenantProfile         bool          `json:"tenant_Profile"`
+		ProvisionACL          bool          `json:"provision_ACL"`
+		ExtKeyRequired        bool          `json:"extKeyRequired"`
+		RequestTimeout        int           `json:"requestTimeout"`
+		RequestTimeoutRenewal int           `json:"requestTimeoutRenewal"`
+		Maintenance           maintenance   `json:"maintenance"`
+		InterConnect          interconnect  `json:"interConnect"`
+		Schema                Schema        `json:"schema"`
+		APIs                  []API         `json:"apis,omitempty"`
+		Prerequisites         prerequisites `json:"prerequisites"`
+	}
+
+	// prerequisites are the resources the service needs, as Kubernetes style quantities, e.g. 500m and 256Mi.
+	prerequisites struct {
+		CPU    string `json:"cpu"`
+		Memory string `json:"memory"`
 	}
 )
 
@@ -79,5 +84,16 @@ func (c *Config) Validate() error {
 	if !validator.MatchString(c.ServiceGroup) {
 		return fmt.Errorf("error with [ServiceGroup] in your config, group syntax is [%s]", validator)
 	}
+	if _, err := c.ParsePrerequisites(); err != nil {
+		return fmt.Errorf("error with [Prerequisites] in your config: %v", err)
+	}
+	if err := c.Schema.Validate(); err != nil {
+		return fmt.Errorf("error with [Schema] in your config: %v", err)
+	}
+	for _, api := range c.APIs {
+		if err := api.validate(); err != nil {
+			return fmt.Errorf("error with [APIs] in your config: %v", err)
+		}
+	}
 	return nil
 }
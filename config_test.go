@@ -116,6 +116,62 @@ func TestConfig_Validate(t *testing.T) {
 			},
 			expectedErr: errors.New("error with [ServiceGroup] in your config, group syntax is [^(([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9])?$]"),
 		},
+		{
+			name: "bad Prerequisites",
+			conf: Config{
+				Type:           "type",
+				ServiceName:    "servicename",
+				ServicePort:    4000,
+				ServiceVersion: "1",
+				Maintenance: maintenance{
+					Port: maintenancePort{
+						Type: "inherit",
+					},
+					Readiness: "/heartbeat",
+				},
+				ServiceGroup:  "group-a",
+				Prerequisites: prerequisites{CPU: "500m", Memory: "256MB"},
+			},
+			expectedErr: errors.New(`error with [Prerequisites] in your config: could not parse prerequisites.memory: invalid quantity "256MB"`),
+		},
+		{
+			name: "bad Schema",
+			conf: Config{
+				Type:           "type",
+				ServiceName:    "servicename",
+				ServicePort:    4000,
+				ServiceVersion: "1",
+				Maintenance: maintenance{
+					Port: maintenancePort{
+						Type: "inherit",
+					},
+					Readiness: "/heartbeat",
+				},
+				ServiceGroup: "group-a",
+				Schema: Schema{Methods: map[string]map[string]Route{
+					"get": {"/": {Fields: map[string]Field{"name": {}}}},
+				}},
+			},
+			expectedErr: errors.New("error with [Schema] in your config: invalid field name of route get /: source is required"),
+		},
+		{
+			name: "bad APIs",
+			conf: Config{
+				Type:           "type",
+				ServiceName:    "servicename",
+				ServicePort:    4000,
+				ServiceVersion: "1",
+				Maintenance: maintenance{
+					Port: maintenancePort{
+						Type: "inherit",
+					},
+					Readiness: "/heartbeat",
+				},
+				ServiceGroup: "group-a",
+				APIs:         []API{{Method: "get", Path: "/users"}},
+			},
+			expectedErr: errors.New("error with [APIs] in your config: route get /users has no label"),
+		},
 		{
 			name: "all ok",
 			conf: Config{
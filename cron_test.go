@@ -0,0 +1,66 @@
+package soajsgo
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCron(t *testing.T) {
+	tt := []struct {
+		name        string
+		spec        string
+		timeZone    string
+		expectedErr string
+	}{
+		{name: "five fields", spec: "*/5 * * * *"},
+		{name: "six fields", spec: "30 0 9 * * 1-5"},
+		{name: "lists and ranges", spec: "0 8-18/2 1,15 * 0,7"},
+		{name: "time zone", spec: "0 0 * * *", timeZone: "America/New_York"},
+		{name: "too few fields", spec: "* * * *", expectedErr: `invalid cron expression "* * * *": expected 5 or 6 fields, got 4`},
+		{name: "out of range", spec: "60 * * * *", expectedErr: `invalid cron expression "60 * * * *": invalid minute "60", expected a value between 0 and 59`},
+		{name: "bad step", spec: "*/0 * * * *", expectedErr: `invalid cron expression "*/0 * * * *": invalid step in minute "*/0"`},
+		{name: "reversed range", spec: "* 5-1 * * *", expectedErr: `invalid cron expression "* 5-1 * * *": invalid range in hour "5-1"`},
+		{name: "bad time zone", spec: "* * * * *", timeZone: "Mars/Olympus", expectedErr: `invalid cron time zone "Mars/Olympus": unknown time zone Mars/Olympus`},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := parseCron(tc.spec, tc.timeZone)
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestCronScheduleNext(t *testing.T) {
+	from := time.Date(2024, time.January, 31, 10, 7, 30, 0, time.UTC) // a Wednesday
+	tt := []struct {
+		name     string
+		spec     string
+		timeZone string
+		expected time.Time
+	}{
+		{name: "every five minutes", spec: "*/5 * * * *", expected: time.Date(2024, time.January, 31, 10, 10, 0, 0, time.UTC)},
+		{name: "every second", spec: "* * * * * *", expected: time.Date(2024, time.January, 31, 10, 7, 31, 0, time.UTC)},
+		{name: "daily", spec: "0 9 * * *", expected: time.Date(2024, time.February, 1, 9, 0, 0, 0, time.UTC)},
+		{name: "end of month", spec: "0 0 31 * *", expected: time.Date(2024, time.March, 31, 0, 0, 0, 0, time.UTC)},
+		{name: "leap day", spec: "0 0 29 2 *", expected: time.Date(2024, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{name: "sunday as 7", spec: "0 0 * * 7", expected: time.Date(2024, time.February, 4, 0, 0, 0, 0, time.UTC)},
+		{name: "day of month or week", spec: "0 0 15 * 5", expected: time.Date(2024, time.February, 2, 0, 0, 0, 0, time.UTC)},
+		{name: "time zone", spec: "0 6 * * *", timeZone: "America/New_York", expected: time.Date(2024, time.January, 31, 11, 0, 0, 0, time.UTC)},
+		{name: "never", spec: "0 0 30 2 *"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := parseCron(tc.spec, tc.timeZone)
+			require.NoError(t, err)
+			next := s.next(from)
+			assert.True(t, tc.expected.Equal(next), "expected %s, got %s", tc.expected, next)
+		})
+	}
+}
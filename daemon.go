@@ -0,0 +1,414 @@
+package soajsgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DaemonStatusRoute is the maintenance route serving the status of a daemon.
+	DaemonStatusRoute = "/daemonStatus"
+
+	// ServiceTypeDaemon is the Config.Type of the daemons.
+	ServiceTypeDaemon = "daemon"
+
+	// daemon group config values.
+	daemonTypeCron         = "cronJob"
+	daemonProcessingSerial = "sequential"
+	daemonJobTenant        = "tenant"
+	daemonStatusActive     = 1
+)
+
+type (
+	// JobFunc runs a job of a daemon. Tenant jobs run once per tenant of the job. The context carries the
+	// ContextData of the run, see FromContext: the tenant and its keys, the service config of the job and the
+	// registry.
+	JobFunc func(ctx context.Context) error
+
+	// DaemonGroupConfig is the daemon group configuration, named by SOAJS_DAEMON_GRP_CONF, the controller returns
+	// for a daemon.
+	//
+	// Solo only keeps the runs of the group from overlapping within this process. It does not make a single instance
+	// of the daemon run the group across the deployment, as the solo flag of SOAJS daemon groups is meant to: deploy
+	// a single replica of a solo daemon, or guard its jobs with a lock of your own.
+	DaemonGroupConfig struct {
+		Group      string               `json:"daemonConfigGroup"`
+		Daemon     string               `json:"daemon"`
+		Status     int                  `json:"status"`
+		Type       string               `json:"type"`
+		Interval   int64                `json:"interval"`
+		CronConfig DaemonCronConfig     `json:"cronConfig"`
+		Solo       bool                 `json:"solo"`
+		Processing string               `json:"processing"`
+		Order      []string             `json:"order"`
+		Jobs       map[string]DaemonJob `json:"jobs"`
+	}
+
+	// DaemonCronConfig is the schedule of a daemon group of type cronJob.
+	DaemonCronConfig struct {
+		CronTime string `json:"cronTime"`
+		TimeZone string `json:"timeZone"`
+	}
+
+	// DaemonJob is a job of a daemon group. A tenant job runs for each of its tenants, listed by TenantsInfo or,
+	// when empty, by TenantExtKeys.
+	DaemonJob struct {
+		Type          string                 `json:"type"`
+		ServiceConfig map[string]interface{} `json:"serviceConfig"`
+		TenantExtKeys []string               `json:"tenantExtKeys"`
+		TenantsInfo   []DaemonTenant         `json:"tenantsInfo"`
+	}
+
+	// DaemonTenant is a tenant a job runs for.
+	DaemonTenant struct {
+		ID     string `json:"id"`
+		Code   string `json:"code"`
+		IKey   string `json:"iKey"`
+		ExtKey string `json:"extKey"`
+	}
+
+	// Daemon runs the jobs of a daemon group on the schedule of its configuration.
+	Daemon struct {
+		reg   *Registry
+		group string
+		jobs  map[string]JobFunc
+
+		mu      sync.Mutex
+		running int
+		status  DaemonStatus
+	}
+
+	// DaemonStatus is the status of a daemon, served on DaemonStatusRoute. Times are in milliseconds.
+	DaemonStatus struct {
+		Group   string               `json:"group"`
+		Active  bool                 `json:"active"`
+		Running bool                 `json:"running"`
+		Runs    int                  `json:"runs"`
+		LastRun int64                `json:"lastRun,omitempty"`
+		NextRun int64                `json:"nextRun,omitempty"`
+		Jobs    map[string]JobStatus `json:"jobs"`
+	}
+
+	// JobStatus is the status of a job of a daemon.
+	JobStatus struct {
+		Runs         int    `json:"runs"`
+		Failures     int    `json:"failures"`
+		LastRun      int64  `json:"lastRun,omitempty"`
+		LastDuration int64  `json:"lastDuration"`
+		LastError    string `json:"lastError,omitempty"`
+	}
+)
+
+// NewDaemon creates a daemon running the jobs of the group, by job name, with the registry.
+func NewDaemon(reg *Registry, group string, jobs map[string]JobFunc) *Daemon {
+	d := &Daemon{
+		reg:   reg,
+		group: group,
+		jobs:  make(map[string]JobFunc, len(jobs)),
+	}
+	for name, fn := range jobs {
+		d.jobs[name] = fn
+	}
+	d.status = DaemonStatus{Group: group, Jobs: make(map[string]JobStatus)}
+	return d
+}
+
+// Run fetches the daemon group configuration from the controller and runs its jobs until ctx is done. Interval
+// groups run at start then every interval, cronJob groups on their cron schedule. A solo group skips a run while
+// the previous one of this process is still running, see DaemonGroupConfig. A sequential group runs its jobs in
+// order, a parallel one concurrently. An inactive group runs no job. The configuration is fetched again after each
+// reload of the registry, a changed one applies from the next run. Run returns once ctx is done and the running
+// jobs returned.
+func (d *Daemon) Run(ctx context.Context) error {
+	conf, err := d.fetchConfig(ctx)
+	if err != nil {
+		return err
+	}
+	next, err := conf.schedule()
+	if err != nil {
+		return fmt.Errorf("invalid schedule of daemon group %s: %v", d.group, err)
+	}
+	at := time.Now()
+	if conf.Type == daemonTypeCron {
+		at = next(at)
+	}
+	at = d.apply(conf, at)
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	for {
+		var fire <-chan time.Time
+		if !at.IsZero() {
+			resetTimer(timer, time.Until(at))
+			fire = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-d.reg.reloaded():
+			c, n, err := d.refetchConfig(ctx, conf)
+			if err != nil {
+				if ctx.Err() == nil {
+					d.reg.Logger().Error("could not refetch daemon group config, keeping the current one", "group", d.group, "error", err)
+				}
+				continue
+			}
+			if n == nil {
+				continue
+			}
+			d.reg.Logger().Info("daemon group config changed", "group", d.group)
+			conf, next = c, n
+			at = d.apply(conf, next(time.Now()))
+		case <-fire:
+			if !d.start(conf.Solo) {
+				d.reg.Logger().Info("daemon run skipped, previous run still running", "group", d.group)
+			} else {
+				wg.Add(1)
+				go func(conf DaemonGroupConfig) {
+					defer wg.Done()
+					d.runJobs(ctx, conf)
+				}(conf)
+			}
+			at = next(time.Now())
+			d.setNextRun(at)
+		}
+	}
+}
+
+// apply sets the status of the daemon for the configuration and returns the next run, at or the zero time when the
+// group is inactive.
+func (d *Daemon) apply(conf DaemonGroupConfig, at time.Time) time.Time {
+	active := conf.Status == daemonStatusActive
+	if !active {
+		d.reg.Logger().Warn("daemon group is not active", "group", d.group)
+		at = time.Time{}
+	}
+	d.mu.Lock()
+	d.status.Active = active
+	d.mu.Unlock()
+	d.setNextRun(at)
+	return at
+}
+
+func (d *Daemon) setNextRun(at time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.status.NextRun = 0
+	if !at.IsZero() {
+		d.status.NextRun = at.UnixMilli()
+	}
+}
+
+// refetchConfig fetches the configuration again. It returns a nil schedule when the configuration did not change.
+func (d *Daemon) refetchConfig(ctx context.Context, current DaemonGroupConfig) (DaemonGroupConfig, func(time.Time) time.Time, error) {
+	conf, err := d.fetchConfig(ctx)
+	if err != nil {
+		return current, nil, err
+	}
+	if reflect.DeepEqual(conf, current) {
+		return current, nil, nil
+	}
+	next, err := conf.schedule()
+	if err != nil {
+		return current, nil, fmt.Errorf("invalid schedule of daemon group %s: %v", d.group, err)
+	}
+	return conf, next, nil
+}
+
+// Status returns the status of the daemon.
+func (d *Daemon) Status() DaemonStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	out := d.status
+	out.Jobs = make(map[string]JobStatus, len(d.status.Jobs))
+	for name, s := range d.status.Jobs {
+		out.Jobs[name] = s
+	}
+	return out
+}
+
+func (d *Daemon) fetchConfig(ctx context.Context) (_ DaemonGroupConfig, err error) {
+	addr, err := d.reg.controllerAddress()
+	if err != nil {
+		return DaemonGroupConfig{}, fmt.Errorf("could not init registry api path: %v", err)
+	}
+	d.reg.mu.RLock()
+	name, env := d.reg.Name, d.reg.Environment
+	d.reg.mu.RUnlock()
+	ctx, span := d.reg.startSpan(ctx, SpanDaemonGroupConfig)
+	defer func() { endSpan(span, err) }()
+	req, err := newControllerRequest(ctx, http.MethodGet, addr.daemonGroupConf(d.group, name, env), nil, span)
+	if err != nil {
+		return DaemonGroupConfig{}, fmt.Errorf("could not fetch daemon group config: %v", err)
+	}
+	res, err := d.reg.controllerClient().Do(req)
+	if err != nil {
+		return DaemonGroupConfig{}, fmt.Errorf("could not fetch daemon group config: %v", err)
+	}
+	defer res.Body.Close()
+	var conf DaemonGroupConfig
+	if err := DecodeResponse(res, &conf); err != nil {
+		return DaemonGroupConfig{}, fmt.Errorf("could not fetch daemon group config: %v", err)
+	}
+	return conf, nil
+}
+
+// schedule returns the function computing the next run of the group after a time, the zero time for none.
+func (c DaemonGroupConfig) schedule() (func(time.Time) time.Time, error) {
+	if c.Type == daemonTypeCron {
+		s, err := parseCron(c.CronConfig.CronTime, c.CronConfig.TimeZone)
+		if err != nil {
+			return nil, err
+		}
+		return s.next, nil
+	}
+	if c.Interval <= 0 {
+		return nil, fmt.Errorf("interval must be positive, got %d", c.Interval)
+	}
+	interval := time.Duration(c.Interval) * time.Millisecond
+	return func(t time.Time) time.Time { return t.Add(interval) }, nil
+}
+
+// jobOrder returns the jobs of the group in order: the ones listed by Order first, then the others by name.
+func (c DaemonGroupConfig) jobOrder() []string {
+	seen := make(map[string]bool, len(c.Jobs))
+	var names []string
+	for _, name := range c.Order {
+		if _, ok := c.Jobs[name]; ok && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	var rest []string
+	for name := range c.Jobs {
+		if !seen[name] {
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+	return append(names, rest...)
+}
+
+// start starts a run, false when the group is solo and a run is still running.
+func (d *Daemon) start(solo bool) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if solo && d.running > 0 {
+		return false
+	}
+	d.running++
+	d.status.Running = true
+	d.status.Runs++
+	d.status.LastRun = time.Now().UnixMilli()
+	return true
+}
+
+func (d *Daemon) runJobs(ctx context.Context, conf DaemonGroupConfig) {
+	defer func() {
+		d.mu.Lock()
+		d.running--
+		d.status.Running = d.running > 0
+		d.mu.Unlock()
+	}()
+	var wg sync.WaitGroup
+	for _, name := range conf.jobOrder() {
+		fn, ok := d.jobs[name]
+		if !ok {
+			d.reg.Logger().Warn("daemon job has no handler", "group", d.group, "job", name)
+			continue
+		}
+		if conf.Processing == daemonProcessingSerial {
+			d.runJob(ctx, name, conf.Jobs[name], fn)
+			continue
+		}
+		wg.Add(1)
+		go func(name string, job DaemonJob) {
+			defer wg.Done()
+			d.runJob(ctx, name, job, fn)
+		}(name, conf.Jobs[name])
+	}
+	wg.Wait()
+}
+
+// runJob runs the job once, or once per tenant for tenant jobs, and records its status.
+func (d *Daemon) runJob(ctx context.Context, name string, job DaemonJob, fn JobFunc) {
+	start := time.Now()
+	var errs []error
+	for _, data := range d.jobContexts(job) {
+		if err := callJob(context.WithValue(ctx, SoajsKey, data), fn); err != nil {
+			data.Logger().Error("daemon job failed", "group", d.group, "job", name, "error", err)
+			errs = append(errs, err)
+		}
+	}
+	err := errors.Join(errs...)
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	s := d.status.Jobs[name]
+	s.Runs++
+	s.LastRun = start.UnixMilli()
+	s.LastDuration = time.Since(start).Milliseconds()
+	s.LastError = ""
+	if err != nil {
+		s.Failures++
+		s.LastError = err.Error()
+	}
+	d.status.Jobs[name] = s
+}
+
+// jobContexts returns the context data of the runs of the job: one per tenant of a tenant job, one without tenant
+// otherwise.
+func (d *Daemon) jobContexts(job DaemonJob) []ContextData {
+	base := ContextData{ServicesConfig: job.ServiceConfig, Reg: d.reg}
+	if job.Type != daemonJobTenant {
+		return []ContextData{base}
+	}
+	tenants := job.TenantsInfo
+	if len(tenants) == 0 {
+		for _, extKey := range job.TenantExtKeys {
+			info, err := d.reg.DecryptExtKey(extKey)
+			if err != nil {
+				d.reg.Logger().Error("could not decrypt tenant key of daemon job", "group", d.group, "error", err)
+				continue
+			}
+			tenants = append(tenants, DaemonTenant{ID: info.TenantID, IKey: info.Key, ExtKey: extKey})
+		}
+	}
+	out := make([]ContextData, 0, len(tenants))
+	for _, t := range tenants {
+		data := base
+		data.Tenant.ID = t.ID
+		data.Tenant.Code = t.Code
+		data.Tenant.Key.IKey = t.IKey
+		data.Tenant.Key.EKey = t.ExtKey
+		out = append(out, data)
+	}
+	return out
+}
+
+// callJob calls the job, turning a panic into an error.
+func callJob(ctx context.Context, fn JobFunc) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("job panicked: %v", r)
+		}
+	}()
+	return fn(ctx)
+}
+
+// daemonGroup returns the daemon group of the environment.
+func daemonGroup() (string, error) {
+	group := os.Getenv(EnvDaemonGroupConf)
+	if group == "" {
+		return "", fmt.Errorf("could not find environment variable %s", EnvDaemonGroupConf)
+	}
+	return group, nil
+}
@@ -0,0 +1,271 @@
+package soajsgo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newDaemonController serves the daemon group config conf, or the error envelope of code when conf is nil.
+func newDaemonController(t *testing.T, conf *DaemonGroupConfig, code int) {
+	t.Helper()
+	controller := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/daemonGroupConf", r.URL.Path)
+		assert.Equal(t, "grp", r.URL.Query().Get("grp"))
+		assert.Equal(t, "cleaner", r.URL.Query().Get("daemon"))
+		assert.Equal(t, "dev", r.URL.Query().Get("env"))
+		if conf == nil {
+			DefaultErrorCatalog.WriteError(w, code)
+			return
+		}
+		WriteData(w, conf)
+	}))
+	t.Cleanup(controller.Close)
+	t.Setenv(EnvRegistryAPIAddress, strings.TrimPrefix(controller.URL, "http://"))
+}
+
+func newDaemonRegistry() *Registry {
+	reg := &Registry{Name: "cleaner", Environment: "dev"}
+	reg.ServiceConfig.Key = testServiceKey
+	WithLogOutput(io.Discard)(reg)
+	return reg
+}
+
+func TestDaemonRun(t *testing.T) {
+	reg := newDaemonRegistry()
+	extKey, err := reg.GenerateExtKey("t2", "ikey2")
+	require.NoError(t, err)
+	newDaemonController(t, &DaemonGroupConfig{
+		Group:    "grp",
+		Status:   1,
+		Type:     "interval",
+		Interval: 10,
+		Jobs: map[string]DaemonJob{
+			"purge":     {Type: "global", ServiceConfig: map[string]interface{}{"days": 30.0}},
+			"report":    {Type: "tenant", TenantsInfo: []DaemonTenant{{ID: "t1", Code: "TNT1", ExtKey: "ekey1"}}},
+			"audit":     {Type: "tenant", TenantExtKeys: []string{extKey, "bad"}},
+			"broken":    {Type: "global"},
+			"unhandled": {Type: "global"},
+		},
+	}, 0)
+
+	var (
+		mu      sync.Mutex
+		tenants = make(map[string]bool)
+		config  map[string]interface{}
+	)
+	tenantJob := func(ctx context.Context) error {
+		data, _ := FromContext(ctx)
+		assert.Equal(t, reg, data.Reg)
+		mu.Lock()
+		tenants[data.Tenant.ID+":"+data.Tenant.Key.IKey+":"+data.Tenant.Key.EKey] = true
+		mu.Unlock()
+		return nil
+	}
+	d := NewDaemon(reg, "grp", map[string]JobFunc{
+		"purge": func(ctx context.Context) error {
+			data, ok := FromContext(ctx)
+			require.True(t, ok)
+			mu.Lock()
+			config = data.ServicesConfig
+			mu.Unlock()
+			return nil
+		},
+		"report": tenantJob,
+		"audit":  tenantJob,
+		"broken": func(ctx context.Context) error {
+			panic("boom")
+		},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- d.Run(ctx) }()
+	require.Eventually(t, func() bool { return d.Status().Jobs["report"].Runs >= 2 }, 5*time.Second, 5*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	status := d.Status()
+	assert.True(t, status.Active)
+	assert.False(t, status.Running)
+	assert.GreaterOrEqual(t, status.Runs, 2)
+	assert.NotContains(t, status.Jobs, "unhandled")
+	assert.Equal(t, status.Jobs["broken"].Runs, status.Jobs["broken"].Failures)
+	assert.Equal(t, "job panicked: boom", status.Jobs["broken"].LastError)
+	assert.Empty(t, status.Jobs["purge"].LastError)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, map[string]interface{}{"days": 30.0}, config)
+	assert.Equal(t, map[string]bool{"t1::ekey1": true, "t2:ikey2:" + extKey: true}, tenants)
+}
+
+func TestDaemonRunSequential(t *testing.T) {
+	newDaemonController(t, &DaemonGroupConfig{
+		Status:     1,
+		Type:       "interval",
+		Interval:   int64(time.Hour / time.Millisecond),
+		Processing: "sequential",
+		Order:      []string{"second", "first"},
+		Jobs:       map[string]DaemonJob{"first": {}, "second": {}, "third": {}},
+	}, 0)
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	job := func(name string) JobFunc {
+		return func(context.Context) error {
+			mu.Lock()
+			defer mu.Unlock()
+			order = append(order, name)
+			return nil
+		}
+	}
+	d := NewDaemon(newDaemonRegistry(), "grp", map[string]JobFunc{"first": job("first"), "second": job("second"), "third": job("third")})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- d.Run(ctx) }()
+	require.Eventually(t, func() bool { return d.Status().Jobs["third"].Runs == 1 }, 5*time.Second, 5*time.Millisecond)
+	require.Eventually(t, func() bool { return d.Status().NextRun > time.Now().UnixMilli() }, 5*time.Second, 5*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"second", "first", "third"}, order)
+}
+
+func TestDaemonRunSolo(t *testing.T) {
+	newDaemonController(t, &DaemonGroupConfig{
+		Status:   1,
+		Type:     "interval",
+		Interval: 5,
+		Solo:     true,
+		Jobs:     map[string]DaemonJob{"slow": {}},
+	}, 0)
+	release := make(chan struct{})
+	d := NewDaemon(newDaemonRegistry(), "grp", map[string]JobFunc{"slow": func(ctx context.Context) error {
+		<-release
+		return errors.New("failed")
+	}})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- d.Run(ctx) }()
+	require.Eventually(t, func() bool { return d.Status().Running }, 5*time.Second, time.Millisecond)
+	time.Sleep(30 * time.Millisecond)
+	assert.Equal(t, 1, d.Status().Runs, "solo runs must not overlap")
+	close(release)
+	require.Eventually(t, func() bool { return d.Status().Jobs["slow"].Failures >= 1 }, 5*time.Second, time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+	assert.Equal(t, "failed", d.Status().Jobs["slow"].LastError)
+}
+
+func TestDaemonRunInactive(t *testing.T) {
+	newDaemonController(t, &DaemonGroupConfig{Status: 0, Type: "interval", Interval: 1, Jobs: map[string]DaemonJob{"job": {}}}, 0)
+	d := NewDaemon(newDaemonRegistry(), "grp", map[string]JobFunc{"job": func(context.Context) error {
+		t.Error("inactive group must not run")
+		return nil
+	}})
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	require.NoError(t, d.Run(ctx))
+	assert.False(t, d.Status().Active)
+	assert.Zero(t, d.Status().Runs)
+}
+
+func TestDaemonRunReload(t *testing.T) {
+	var conf atomic.Value
+	conf.Store(DaemonGroupConfig{Status: 0, Type: "interval", Interval: 5, Jobs: map[string]DaemonJob{"job": {}}})
+	controller := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/getRegistry" {
+			_, _ = w.Write([]byte(`{"result":true,"data":{"name":"cleaner","environment":"dev"}}`))
+			return
+		}
+		WriteData(w, conf.Load())
+	}))
+	defer controller.Close()
+	t.Setenv(EnvRegistryAPIAddress, strings.TrimPrefix(controller.URL, "http://"))
+
+	reg := newDaemonRegistry()
+	var runs atomic.Int32
+	d := NewDaemon(reg, "grp", map[string]JobFunc{"job": func(context.Context) error {
+		runs.Add(1)
+		return nil
+	}})
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- d.Run(ctx) }()
+	require.Eventually(t, func() bool { return !d.Status().Active && d.Status().NextRun == 0 }, 5*time.Second, time.Millisecond)
+
+	conf.Store(DaemonGroupConfig{Status: 1, Type: "interval", Interval: 5, Jobs: map[string]DaemonJob{"job": {}}})
+	require.NoError(t, reg.Reload())
+	require.Eventually(t, func() bool { return runs.Load() >= 2 }, 5*time.Second, time.Millisecond, "the activated group must run")
+	assert.True(t, d.Status().Active)
+
+	conf.Store(DaemonGroupConfig{Status: 0, Type: "interval", Interval: 5, Jobs: map[string]DaemonJob{"job": {}}})
+	require.NoError(t, reg.Reload())
+	require.Eventually(t, func() bool { return !d.Status().Active && !d.Status().Running }, 5*time.Second, time.Millisecond)
+	stopped := runs.Load()
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, stopped, runs.Load(), "the deactivated group must not run")
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+func TestDaemonRunErrors(t *testing.T) {
+	tt := []struct {
+		name        string
+		conf        *DaemonGroupConfig
+		code        int
+		expectedErr string
+	}{
+		{name: "controller error", code: 404, expectedErr: "could not fetch daemon group config: call failed with status 500: [404] error 404"},
+		{name: "no interval", conf: &DaemonGroupConfig{Status: 1, Type: "interval"}, expectedErr: "invalid schedule of daemon group grp: interval must be positive, got 0"},
+		{name: "bad cron", conf: &DaemonGroupConfig{Status: 1, Type: "cronJob", CronConfig: DaemonCronConfig{CronTime: "* *"}}, expectedErr: `invalid schedule of daemon group grp: invalid cron expression "* *": expected 5 or 6 fields, got 2`},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			newDaemonController(t, tc.conf, tc.code)
+			err := NewDaemon(newDaemonRegistry(), "grp", nil).Run(context.Background())
+			assert.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestDaemonGroupConfigJSON(t *testing.T) {
+	var conf DaemonGroupConfig
+	require.NoError(t, json.Unmarshal([]byte(`{
+		"daemonConfigGroup": "grp",
+		"daemon": "cleaner",
+		"status": 1,
+		"type": "cronJob",
+		"cronConfig": {"cronTime": "0 0 * * *", "timeZone": "Europe/Paris"},
+		"solo": true,
+		"processing": "sequential",
+		"order": ["purge"],
+		"jobs": {"purge": {"type": "tenant", "serviceConfig": {"days": 30}, "tenantExtKeys": ["ekey"]}}
+	}`), &conf))
+	assert.Equal(t, DaemonGroupConfig{
+		Group:      "grp",
+		Daemon:     "cleaner",
+		Status:     1,
+		Type:       "cronJob",
+		CronConfig: DaemonCronConfig{CronTime: "0 0 * * *", TimeZone: "Europe/Paris"},
+		Solo:       true,
+		Processing: "sequential",
+		Order:      []string{"purge"},
+		Jobs:       map[string]DaemonJob{"purge": {Type: "tenant", ServiceConfig: map[string]interface{}{"days": 30.0}, TenantExtKeys: []string{"ekey"}}},
+	}, conf)
+}
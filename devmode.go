@@ -0,0 +1,242 @@
+package soajsgo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// HeaderKey is the header carrying the external key of the tenant of a request sent to the gateway.
+	HeaderKey = "key"
+	// HeaderAccessToken is the header carrying the oauth access token of a request sent to the gateway. It may be
+	// sent as a query parameter too.
+	HeaderAccessToken = "access_token"
+)
+
+var (
+	// ErrDevModeInProduction is returned when the dev mode is enabled in a production environment, see
+	// WithDevRefusedEnvs.
+	ErrDevModeInProduction = errors.New("dev mode cannot be enabled in a production environment")
+
+	// defaultRefusedEnvs are the environment codes the dev mode is refused in by default.
+	defaultRefusedEnvs = []string{"prod", "production", "prd", "live"}
+)
+
+type (
+	// DevStore is the fake tenant store of the dev mode. It resolves the key and access_token of the requests that
+	// do not carry an injected object, the way the gateway would, to the context data it holds.
+	DevStore struct {
+		mu      sync.RWMutex
+		context *headerInfo
+		keys    map[string]headerInfo
+		tokens  map[string]Urac
+	}
+
+	// devFixture is the JSON fixture of a dev store. The context and keys are injected objects, as sent by the
+	// gateway in the soajsinjectobj header, the tokens uracs.
+	devFixture struct {
+		Context json.RawMessage            `json:"context"`
+		Keys    map[string]json.RawMessage `json:"keys"`
+		Tokens  map[string]Urac            `json:"tokens"`
+	}
+)
+
+// NewDevStore creates an empty DevStore.
+func NewDevStore() *DevStore {
+	return &DevStore{
+		keys:   make(map[string]headerInfo),
+		tokens: make(map[string]Urac),
+	}
+}
+
+// LoadDevStore creates a DevStore from a JSON fixture file:
+//
+//	{
+//	  "context": {"tenant": {"code": "DBTN"}, ...},
+//	  "keys": {"<external key>": {"tenant": {"code": "TNT1"}, ...}},
+//	  "tokens": {"<access token>": {"_id": "u1", "username": "owner"}}
+//	}
+//
+// The context and keys are injected objects, e.g. built with soajstest.NewHeader or soajsctl header decode.
+func LoadDevStore(path string) (*DevStore, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read dev fixture: %v", err)
+	}
+	var fixture devFixture
+	if err := json.Unmarshal(b, &fixture); err != nil {
+		return nil, fmt.Errorf("could not parse dev fixture %s: %v", path, err)
+	}
+	s := NewDevStore()
+	if len(fixture.Context) > 0 {
+		d, err := parseHeaderData(string(fixture.Context))
+		if err != nil {
+			return nil, fmt.Errorf("could not parse context of dev fixture %s: %v", path, err)
+		}
+		s.context = d
+	}
+	for key, raw := range fixture.Keys {
+		d, err := parseHeaderData(string(raw))
+		if err != nil {
+			return nil, fmt.Errorf("could not parse key %s of dev fixture %s: %v", key, path, err)
+		}
+		s.addKey(key, *d)
+	}
+	for token, urac := range fixture.Tokens {
+		s.tokens[token] = urac
+	}
+	return s, nil
+}
+
+// SetContext sets the context data of the requests without key.
+func (s *DevStore) SetContext(c ContextData) {
+	d := c.headerInfo()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.context = &d
+}
+
+// AddKey adds the context data of the requests with the external key.
+func (s *DevStore) AddKey(key string, c ContextData) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.addKey(key, c.headerInfo())
+}
+
+// AddToken adds the urac of the requests with the access token.
+func (s *DevStore) AddToken(token string, urac Urac) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[token] = urac
+}
+
+func (s *DevStore) addKey(key string, d headerInfo) {
+	if d.Key.EKey == "" {
+		d.Key.EKey = key
+	}
+	s.keys[key] = d
+}
+
+// resolve returns the injected object of the request, nil when the store has none for it.
+func (s *DevStore) resolve(r *http.Request) (*headerInfo, error) {
+	key := r.Header.Get(HeaderKey)
+	token := r.Header.Get(HeaderAccessToken)
+	if token == "" {
+		token = r.URL.Query().Get(HeaderAccessToken)
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	var d headerInfo
+	switch {
+	case key != "":
+		k, ok := s.keys[key]
+		if !ok {
+			return nil, errors.New("dev mode: unknown key")
+		}
+		d = k
+	case s.context != nil:
+		d = *s.context
+	case token == "":
+		return nil, nil
+	}
+	if token != "" {
+		urac, ok := s.tokens[token]
+		if !ok {
+			return nil, errors.New("dev mode: unknown access token")
+		}
+		d.Urac = urac
+	}
+	return &d, nil
+}
+
+// WithDevStore sets the tenant store of the dev mode instead of the SOAJS_DEV_FIXTURE file. It has no effect unless
+// SOAJS_DEV_MODE enables the dev mode.
+func WithDevStore(s *DevStore) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.devStore = s
+	}
+}
+
+// WithDevRefusedEnvs sets the environment codes the dev mode is refused in, matched case insensitively against
+// SOAJS_ENV and the environment of the registry. It replaces the ones of SOAJS_DEV_REFUSED_ENVS and the default prod,
+// production, prd and live: list every production environment, e.g. eu-prod. Without codes, no environment is refused.
+func WithDevRefusedEnvs(envs ...string) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.devRefusedEnvs = append([]string{}, envs...)
+	}
+}
+
+// devMode returns the store of the dev mode when SOAJS_DEV_MODE enables it, nil when it is off. The store is the
+// one of the options, or loaded from SOAJS_DEV_FIXTURE. It fails when SOAJS_ENV or one of envs is refused, see
+// refusedEnvs.
+func devMode(store *DevStore, refused []string, envs ...string) (*DevStore, error) {
+	on, err := devModeEnabled(refused, envs...)
+	if err != nil || !on {
+		return nil, err
+	}
+	if store != nil {
+		return store, nil
+	}
+	if path := os.Getenv(EnvDevFixture); path != "" {
+		return LoadDevStore(path)
+	}
+	return NewDevStore(), nil
+}
+
+// devModeEnabled reports whether SOAJS_DEV_MODE enables the dev mode. It fails when SOAJS_ENV or one of envs, e.g.
+// the environment of the registry, is refused, see refusedEnvs.
+func devModeEnabled(refused []string, envs ...string) (bool, error) {
+	value := os.Getenv(EnvDevMode)
+	if value == "" {
+		return false, nil
+	}
+	on, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("could not parse %s environment variable: %v", EnvDevMode, err)
+	}
+	if !on {
+		return false, nil
+	}
+	refused = refusedEnvs(refused)
+	if env := os.Getenv(EnvSoajsEnv); isRefusedEnv(refused, env) {
+		return false, fmt.Errorf("%w: %s is %s", ErrDevModeInProduction, EnvSoajsEnv, env)
+	}
+	for _, env := range envs {
+		if isRefusedEnv(refused, env) {
+			return false, fmt.Errorf("%w: environment is %s", ErrDevModeInProduction, env)
+		}
+	}
+	return true, nil
+}
+
+// refusedEnvs returns the environment codes the dev mode is refused in: the ones of WithDevRefusedEnvs when set,
+// else the comma separated ones of SOAJS_DEV_REFUSED_ENVS, else the default ones.
+func refusedEnvs(option []string) []string {
+	if option != nil {
+		return option
+	}
+	if value := os.Getenv(EnvDevRefusedEnvs); value != "" {
+		return strings.Split(value, ",")
+	}
+	return defaultRefusedEnvs
+}
+
+// isRefusedEnv reports whether the environment code is one of the refused ones, ignoring case and spaces.
+func isRefusedEnv(refused []string, env string) bool {
+	env = strings.TrimSpace(env)
+	if env == "" {
+		return false
+	}
+	for _, r := range refused {
+		if strings.EqualFold(strings.TrimSpace(r), env) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,294 @@
+package soajsgo
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDevFixture = `{
+  "context": {"tenant": {"id": "t0", "code": "DBTN"}},
+  "keys": {
+    "ekey1": {"tenant": {"id": "t1", "code": "TNT1"}, "key": {"iKey": "ikey1"}, "package": {"acl": {"users": {}}}}
+  },
+  "tokens": {"token1": {"_id": "u1", "username": "owner"}}
+}`
+
+func writeDevFixture(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "dev.json")
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestIsRefusedEnv(t *testing.T) {
+	for env, expected := range map[string]bool{
+		"prod": true, "PRODUCTION": true, "prd": true, "live": true, " Prod ": true, "preprod": false, "eu-prod": false,
+		"stg-live": false, "dev": false, "stg": false, "qa": false, "": false,
+	} {
+		assert.Equal(t, expected, isRefusedEnv(defaultRefusedEnvs, env), env)
+	}
+	refused := []string{"eu-prod", " STG "}
+	assert.True(t, isRefusedEnv(refused, "EU-PROD"))
+	assert.True(t, isRefusedEnv(refused, "stg"))
+	assert.False(t, isRefusedEnv(refused, "prod"))
+}
+
+func TestRefusedEnvs(t *testing.T) {
+	t.Setenv(EnvDevRefusedEnvs, "")
+	assert.Equal(t, defaultRefusedEnvs, refusedEnvs(nil))
+	t.Setenv(EnvDevRefusedEnvs, "eu-prod,us-prod")
+	assert.Equal(t, []string{"eu-prod", "us-prod"}, refusedEnvs(nil))
+	assert.Equal(t, []string{"qa"}, refusedEnvs([]string{"qa"}))
+	assert.Empty(t, refusedEnvs([]string{}))
+}
+
+func TestDevModeEnabled(t *testing.T) {
+	tt := []struct {
+		name        string
+		devMode     string
+		env         string
+		envs        []string
+		refused     []string
+		refusedEnvs string
+		expected    bool
+		expectedErr string
+	}{
+		{name: "not set", env: "prod"},
+		{name: "off", devMode: "false", env: "prod"},
+		{name: "on", devMode: "true", env: "dev", expected: true},
+		{name: "production", devMode: "1", env: "PROD", expectedErr: "dev mode cannot be enabled in a production environment: SOAJS_ENV is PROD"},
+		{name: "invalid", devMode: "yes", env: "dev", expectedErr: "could not parse SOAJS_DEV_MODE environment variable"},
+		{name: "production registry", devMode: "true", env: "dev", envs: []string{"live"}, expectedErr: "dev mode cannot be enabled in a production environment: environment is live"},
+		{name: "off in production registry", devMode: "false", env: "dev", envs: []string{"prod"}},
+		{name: "not refused by default", devMode: "true", env: "eu-prod", expected: true},
+		{name: "refused by env var", devMode: "true", env: "dev", envs: []string{"eu-prod"}, refusedEnvs: "us-prod, eu-prod", expectedErr: "environment is eu-prod"},
+		{name: "env var replaces default", devMode: "true", env: "prod", refusedEnvs: "eu-prod", expected: true},
+		{name: "refused by option", devMode: "true", env: "eu-prod", refused: []string{"eu-prod"}, refusedEnvs: "us-prod", expectedErr: "SOAJS_ENV is eu-prod"},
+		{name: "option refuses none", devMode: "true", env: "prod", refused: []string{}, expected: true},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(EnvDevMode, tc.devMode)
+			t.Setenv(EnvSoajsEnv, tc.env)
+			t.Setenv(EnvDevRefusedEnvs, tc.refusedEnvs)
+			on, err := devModeEnabled(tc.refused, tc.envs...)
+			if tc.expectedErr != "" {
+				assert.ErrorContains(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, on)
+		})
+	}
+
+	t.Setenv(EnvDevMode, "true")
+	t.Setenv(EnvSoajsEnv, "production")
+	_, err := devModeEnabled(nil)
+	assert.ErrorIs(t, err, ErrDevModeInProduction)
+}
+
+func TestLoadDevStore(t *testing.T) {
+	s, err := LoadDevStore(writeDevFixture(t, testDevFixture))
+	require.NoError(t, err)
+	assert.Equal(t, "DBTN", s.context.Tenant.Code)
+	assert.Equal(t, "TNT1", s.keys["ekey1"].Tenant.Code)
+	assert.Equal(t, "ekey1", s.keys["ekey1"].Key.EKey)
+	assert.Equal(t, "owner", s.tokens["token1"].Username)
+
+	tt := []struct {
+		name        string
+		content     string
+		expectedErr string
+	}{
+		{name: "bad json", content: "{", expectedErr: "could not parse dev fixture"},
+		{name: "null context", content: `{"context": null}`, expectedErr: "could not parse context of dev fixture"},
+		{name: "bad key", content: `{"keys": {"k": "tenant"}}`, expectedErr: "could not parse key k of dev fixture"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := LoadDevStore(writeDevFixture(t, tc.content))
+			assert.ErrorContains(t, err, tc.expectedErr)
+		})
+	}
+	_, err = LoadDevStore(filepath.Join(t.TempDir(), "missing.json"))
+	assert.ErrorContains(t, err, "could not read dev fixture")
+}
+
+func TestDevStore_resolve(t *testing.T) {
+	s := NewDevStore()
+	s.AddKey("ekey1", ContextData{Tenant: Tenant{ID: "t1", Code: "TNT1"}})
+	s.AddToken("token1", Urac{ID: "u1", Username: "owner"})
+	withContext := NewDevStore()
+	withContext.SetContext(ContextData{Tenant: Tenant{Code: "DBTN"}})
+
+	tt := []struct {
+		name           string
+		store          *DevStore
+		target         string
+		headers        map[string]string
+		expectedTenant string
+		expectedUrac   string
+		expectedNil    bool
+		expectedErr    string
+	}{
+		{name: "nothing", store: s, expectedNil: true},
+		{name: "key", store: s, headers: map[string]string{HeaderKey: "ekey1"}, expectedTenant: "TNT1"},
+		{name: "key and token", store: s, headers: map[string]string{HeaderKey: "ekey1", HeaderAccessToken: "token1"}, expectedTenant: "TNT1", expectedUrac: "owner"},
+		{name: "token in query", store: s, target: "/?access_token=token1", headers: map[string]string{HeaderKey: "ekey1"}, expectedTenant: "TNT1", expectedUrac: "owner"},
+		{name: "token only", store: s, headers: map[string]string{HeaderAccessToken: "token1"}, expectedUrac: "owner"},
+		{name: "unknown key", store: s, headers: map[string]string{HeaderKey: "other"}, expectedErr: "dev mode: unknown key"},
+		{name: "unknown token", store: s, headers: map[string]string{HeaderKey: "ekey1", HeaderAccessToken: "other"}, expectedErr: "dev mode: unknown access token"},
+		{name: "context", store: withContext, expectedTenant: "DBTN"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			target := tc.target
+			if target == "" {
+				target = "/"
+			}
+			req := httptest.NewRequest(http.MethodGet, target, nil)
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			d, err := tc.store.resolve(req)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			if tc.expectedNil {
+				assert.Nil(t, d)
+				return
+			}
+			require.NotNil(t, d)
+			assert.Equal(t, tc.expectedTenant, d.Tenant.Code)
+			assert.Equal(t, tc.expectedUrac, d.Urac.Username)
+		})
+	}
+}
+
+func TestRegistry_MiddlewareWith_DevMode(t *testing.T) {
+	t.Setenv(EnvDevMode, "true")
+	t.Setenv(EnvSoajsEnv, "dev")
+	t.Setenv(EnvDevFixture, writeDevFixture(t, testDevFixture))
+
+	tt := []struct {
+		name           string
+		opts           []MiddlewareOption
+		headers        map[string]string
+		expectedStatus int
+		expectedTenant string
+		expectedUrac   string
+	}{
+		{name: "fixture context", expectedStatus: http.StatusOK, expectedTenant: "DBTN"},
+		{name: "fixture key", headers: map[string]string{HeaderKey: "ekey1", HeaderAccessToken: "token1"},
+			expectedStatus: http.StatusOK, expectedTenant: "TNT1", expectedUrac: "owner"},
+		{name: "unknown key", headers: map[string]string{HeaderKey: "other"}, expectedStatus: http.StatusUnauthorized},
+		{name: "injected object wins", headers: map[string]string{HeaderKey: "ekey1", HeaderInjectObj: `{"tenant":{"code":"GW"}}`},
+			expectedStatus: http.StatusOK, expectedTenant: "GW"},
+		{name: "malformed injected object", opts: []MiddlewareOption{Strict()}, headers: map[string]string{HeaderKey: "ekey1", HeaderInjectObj: `{"tenant"`},
+			expectedStatus: http.StatusUnauthorized},
+		{name: "acl applies", opts: []MiddlewareOption{WithACL("orders", "1")}, headers: map[string]string{HeaderKey: "ekey1"},
+			expectedStatus: http.StatusForbidden},
+		{name: "store option", opts: []MiddlewareOption{WithDevStore(func() *DevStore {
+			s := NewDevStore()
+			s.SetContext(ContextData{Tenant: Tenant{Code: "CODE"}})
+			return s
+		}())}, expectedStatus: http.StatusOK, expectedTenant: "CODE"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var logs bytes.Buffer
+			reg := &Registry{logOutput: &logs}
+			var data *ContextData
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if d, ok := FromContext(r.Context()); ok {
+					data = &d
+				}
+			})
+			req := httptest.NewRequest(http.MethodGet, "/users", nil)
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			reg.MiddlewareWith(tc.opts...)(handler).ServeHTTP(rec, req)
+			assert.Contains(t, logs.String(), "SOAJS dev mode enabled")
+			require.Equal(t, tc.expectedStatus, rec.Code, rec.Body.String())
+			if rec.Code != http.StatusOK {
+				return
+			}
+			require.NotNil(t, data)
+			assert.Equal(t, tc.expectedTenant, data.Tenant.Code)
+			assert.Equal(t, tc.expectedUrac, data.Urac.Username)
+		})
+	}
+}
+
+func TestRegistry_MiddlewareWith_DevModeInProduction(t *testing.T) {
+	t.Setenv(EnvDevMode, "true")
+	t.Setenv(EnvSoajsEnv, "prod")
+	t.Setenv(EnvDevFixture, writeDevFixture(t, testDevFixture))
+
+	called := false
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, called = FromContext(r.Context())
+	})
+	tt := []struct {
+		name     string
+		soajsEnv string
+		regEnv   string
+		opts     []MiddlewareOption
+	}{
+		{name: "SOAJS_ENV", soajsEnv: "prod", regEnv: "dev"},
+		{name: "registry environment", soajsEnv: "dev", regEnv: "production"},
+		{name: "refused environment option", soajsEnv: "dev", regEnv: "eu-prod", opts: []MiddlewareOption{WithDevRefusedEnvs("eu-prod")}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv(EnvSoajsEnv, tc.soajsEnv)
+			var logs bytes.Buffer
+			reg := &Registry{Environment: tc.regEnv, logOutput: &logs}
+			rec := httptest.NewRecorder()
+			opts := append([]MiddlewareOption{Strict()}, tc.opts...)
+			reg.MiddlewareWith(opts...)(handler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users", nil))
+			assert.Equal(t, http.StatusUnauthorized, rec.Code)
+			assert.False(t, called)
+			assert.Contains(t, logs.String(), "SOAJS dev mode refused")
+			assert.NotContains(t, logs.String(), "SOAJS dev mode enabled")
+		})
+	}
+
+	err := Run(context.Background(), Config{
+		ServiceName:    "test",
+		ServiceGroup:   "group",
+		ServicePort:    4000,
+		Type:           "service",
+		ServiceVersion: "1",
+		Maintenance:    maintenance{Port: maintenancePort{Type: maintenancePortInherit}, Readiness: "/ready"},
+	}, handler)
+	assert.ErrorIs(t, err, ErrDevModeInProduction)
+}
+
+func TestRun_DevFixtureError(t *testing.T) {
+	t.Setenv(EnvDevMode, "true")
+	t.Setenv(EnvSoajsEnv, "dev")
+	t.Setenv(EnvDevFixture, filepath.Join(t.TempDir(), "missing.json"))
+
+	err := Run(context.Background(), Config{
+		ServiceName:    "test",
+		ServiceGroup:   "group",
+		ServicePort:    4000,
+		Type:           "service",
+		ServiceVersion: "1",
+		Maintenance:    maintenance{Port: maintenancePort{Type: maintenancePortInherit}, Readiness: "/ready"},
+	}, http.NotFoundHandler())
+	assert.ErrorContains(t, err, "could not read dev fixture")
+}
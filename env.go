@@ -11,4 +11,21 @@ const (
 
 	// EnvDeployManual is the environment variable name that indicates if the service has been deployed manually or not.
 	EnvDeployManual = "SOAJS_DEPLOY_MANUAL"
+
+	// EnvDaemonGroupConf is the environment variable name that contains the name of the daemon group configuration
+	// a daemon runs.
+	EnvDaemonGroupConf = "SOAJS_DAEMON_GRP_CONF"
+
+	// EnvDevMode is the environment variable name that enables the dev mode, where the middleware synthesizes the
+	// context data of the requests sent without the gateway. It is refused in production environments, see
+	// EnvDevRefusedEnvs.
+	EnvDevMode = "SOAJS_DEV_MODE"
+
+	// EnvDevRefusedEnvs is the environment variable name that contains the comma separated environment codes the dev
+	// mode is refused in, prod, production, prd and live by default.
+	EnvDevRefusedEnvs = "SOAJS_DEV_REFUSED_ENVS"
+
+	// EnvDevFixture is the environment variable name that contains the path of the fixture file of the dev mode,
+	// see LoadDevStore.
+	EnvDevFixture = "SOAJS_DEV_FIXTURE"
 )
@@ -2,13 +2,8 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 	"log"
 	"net/http"
-	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
 	soajsgo "github.com/soajs/soajs.golang"
@@ -18,11 +13,14 @@ import (
 //
 // This example demonstrates how to integrate SOAJS middleware with standard net/http.
 
-func main() {
-	ctx := context.Background()
+// Error codes of the service, answered in the SOAJS envelope
+const (
+	errCodeNoContext = 400
+	errCodeNotFound  = 401
+)
 
-	// Initialize registry manager
-	// For manual deployment (SOAJS_DEPLOY_MANUAL=true), use NewFromConfig
+func main() {
+	// For manual deployment (SOAJS_DEPLOY_MANUAL=true), Run registers the service with the controller
 	config := soajsgo.Config{
 		ServiceName:    "my-go-service",
 		ServiceGroup:   "my-group",
@@ -31,11 +29,12 @@ func main() {
 		Type:           "service",
 		ServiceVersion: "1",
 	}
+	config.Maintenance.Readiness = "/heartbeat"
+	config.Maintenance.Port.Type = "maintenance"
 
-	registry, err := soajsgo.NewFromConfig(ctx, config)
-	if err != nil {
-		log.Fatalf("Failed to initialize registry: %v", err)
-	}
+	// Register the error codes of the service with their HTTP status and message template
+	soajsgo.RegisterError(errCodeNoContext, http.StatusBadRequest, "No SOAJS context available")
+	soajsgo.RegisterError(errCodeNotFound, http.StatusNotFound, "Not found: %v")
 
 	// Create HTTP handlers
 	mux := http.NewServeMux()
@@ -47,53 +46,41 @@ func main() {
 	mux.HandleFunc("/tenant-info", tenantInfoHandler)
 
 	// Database info endpoint
-	mux.HandleFunc("/database-info", makeDatabaseInfoHandler(registry))
+	mux.HandleFunc("/database-info", databaseInfoHandler)
 
 	// Services listing endpoint
-	mux.HandleFunc("/services", makeServicesHandler(registry))
+	mux.HandleFunc("/services", servicesHandler)
 
 	// Custom config endpoint
-	mux.HandleFunc("/custom-config", makeCustomConfigHandler(registry))
+	mux.HandleFunc("/custom-config", customConfigHandler)
 
 	// Health check endpoint
 	mux.HandleFunc("/health", healthHandler)
 
-	// Wrap mux with SOAJS middleware
-	handler := registry.Middleware(mux)
-
-	// Create HTTP server
-	server := &http.Server{
-		Addr:         fmt.Sprintf(":%d", config.ServicePort),
-		Handler:      handler,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+	// Run wraps mux with SOAJS middleware, serves the maintenance routes and shuts down gracefully
+	// on SIGINT/SIGTERM
+	err := soajsgo.Run(context.Background(), config, mux,
+		soajsgo.WithShutdownTimeout(10*time.Second),
+		soajsgo.WithServer(func(srv *http.Server) {
+			srv.ReadTimeout = 15 * time.Second
+			srv.WriteTimeout = 15 * time.Second
+		}),
+	)
+	if err != nil {
+		log.Fatalf("Service failed: %v", err)
 	}
 
-	// Start server in goroutine
-	go func() {
-		log.Printf("Starting server on port %d", config.ServicePort)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server failed: %v", err)
-		}
-	}()
-
-	// Wait for interrupt signal
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-
-	log.Println("Shutting down server...")
-
-	// Graceful shutdown
-	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer cancel()
+	log.Println("Server stopped")
+}
 
-	if err := server.Shutdown(shutdownCtx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+// registryFromRequest returns the registry attached to the SOAJS context of the request
+func registryFromRequest(w http.ResponseWriter, r *http.Request) (*soajsgo.Registry, bool) {
+	soaData, ok := r.Context().Value(soajsgo.SoajsKey).(soajsgo.ContextData)
+	if !ok || soaData.Reg == nil {
+		soajsgo.WriteError(w, errCodeNoContext, "")
+		return nil, false
 	}
-
-	log.Println("Server stopped")
+	return soaData.Reg, true
 }
 
 // rootHandler handles the root endpoint
@@ -102,7 +89,7 @@ func rootHandler(w http.ResponseWriter, r *http.Request) {
 		"message": "SOAJS Go Example",
 		"version": "1.0.0",
 	}
-	writeJSON(w, http.StatusOK, response)
+	soajsgo.WriteData(w, response)
 }
 
 // tenantInfoHandler demonstrates accessing SOAJS context data
@@ -110,9 +97,7 @@ func tenantInfoHandler(w http.ResponseWriter, r *http.Request) {
 	// Get SOAJS context from request
 	soaData := r.Context().Value(soajsgo.SoajsKey)
 	if soaData == nil {
-		writeJSON(w, http.StatusBadRequest, map[string]string{
-			"error": "No SOAJS context available",
-		})
+		soajsgo.WriteError(w, errCodeNoContext, "")
 		return
 	}
 
@@ -136,92 +121,94 @@ func tenantInfoHandler(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
-	writeJSON(w, http.StatusOK, response)
+	soajsgo.WriteData(w, response)
 }
 
-// makeDatabaseInfoHandler demonstrates accessing database configuration
-func makeDatabaseInfoHandler(registry *soajsgo.Registry) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		// Get database from registry
-		db, err := registry.Database("main")
-		if err != nil {
-			writeJSON(w, http.StatusNotFound, map[string]string{
-				"error": err.Error(),
-			})
-			return
-		}
-
-		servers := make([]map[string]interface{}, 0, len(db.Server))
-		for _, server := range db.Server {
-			servers = append(servers, map[string]interface{}{
-				"host": server.Host,
-				"port": server.Port,
-			})
-		}
+// databaseInfoHandler demonstrates accessing database configuration
+func databaseInfoHandler(w http.ResponseWriter, r *http.Request) {
+	registry, ok := registryFromRequest(w, r)
+	if !ok {
+		return
+	}
+	// Get database from registry
+	db, err := registry.Database("main")
+	if err != nil {
+		soajsgo.DefaultErrorCatalog.WriteError(w, errCodeNotFound, err)
+		return
+	}
 
-		response := map[string]interface{}{
-			"database": db.Name,
-			"cluster":  db.Cluster,
-			"servers":  servers,
-		}
+	servers := make([]map[string]interface{}, 0, len(db.Server))
+	for _, server := range db.Server {
+		servers = append(servers, map[string]interface{}{
+			"host": server.Host,
+			"port": server.Port,
+		})
+	}
 
-		writeJSON(w, http.StatusOK, response)
+	response := map[string]interface{}{
+		"database": db.Name,
+		"cluster":  db.Cluster,
+		"servers":  servers,
 	}
-}
 
-// makeServicesHandler demonstrates accessing service information
-func makeServicesHandler(registry *soajsgo.Registry) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		services := make(map[string]interface{})
+	soajsgo.WriteData(w, response)
+}
 
-		for name, service := range registry.Services {
-			services[name] = map[string]interface{}{
-				"group": service.Group,
-				"port":  service.Port,
-			}
-		}
+// servicesHandler demonstrates accessing service information
+func servicesHandler(w http.ResponseWriter, r *http.Request) {
+	registry, ok := registryFromRequest(w, r)
+	if !ok {
+		return
+	}
+	services := make(map[string]interface{})
 
-		response := map[string]interface{}{
-			"services": services,
+	for name, service := range registry.Services {
+		services[name] = map[string]interface{}{
+			"group": service.Group,
+			"port":  service.Port,
 		}
+	}
 
-		writeJSON(w, http.StatusOK, response)
+	response := map[string]interface{}{
+		"services": services,
 	}
+
+	soajsgo.WriteData(w, response)
 }
 
-// makeCustomConfigHandler demonstrates accessing custom registry data
-func makeCustomConfigHandler(registry *soajsgo.Registry) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		name := r.URL.Query().Get("name")
-
-		custom, err := registry.GetCustom(name)
-		if err != nil {
-			writeJSON(w, http.StatusNotFound, map[string]string{
-				"error": err.Error(),
-			})
-			return
-		}
+// customConfigHandler demonstrates accessing custom registry data
+func customConfigHandler(w http.ResponseWriter, r *http.Request) {
+	registry, ok := registryFromRequest(w, r)
+	if !ok {
+		return
+	}
+	name := r.URL.Query().Get("name")
 
-		var response map[string]interface{}
-
-		if name != "" {
-			// Return specific custom registry
-			customReg := custom.(*soajsgo.CustomRegistry)
-			response = map[string]interface{}{
-				"name":   name,
-				"custom": customReg,
-			}
-		} else {
-			// Return all custom registries
-			customRegistries := custom.(soajsgo.CustomRegistries)
-			response = map[string]interface{}{
-				"count":   len(customRegistries),
-				"customs": customRegistries,
-			}
-		}
+	custom, err := registry.GetCustom(name)
+	if err != nil {
+		soajsgo.DefaultErrorCatalog.WriteError(w, errCodeNotFound, err)
+		return
+	}
+
+	var response map[string]interface{}
 
-		writeJSON(w, http.StatusOK, response)
+	if name != "" {
+		// Return specific custom registry
+		customReg := custom.(*soajsgo.CustomRegistry)
+		response = map[string]interface{}{
+			"name":   name,
+			"custom": customReg,
+		}
+	} else {
+		// Return all custom registries
+		customRegistries := custom.(soajsgo.CustomRegistries)
+		response = map[string]interface{}{
+			"count":   len(customRegistries),
+			"customs": customRegistries,
+		}
 	}
+
+	soajsgo.WriteData(w, response)
 }
 
 // healthHandler handles health check requests
@@ -229,14 +216,5 @@ func healthHandler(w http.ResponseWriter, r *http.Request) {
 	response := map[string]string{
 		"status": "healthy",
 	}
-	writeJSON(w, http.StatusOK, response)
-}
-
-// writeJSON writes JSON response
-func writeJSON(w http.ResponseWriter, status int, data interface{}) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(status)
-	if err := json.NewEncoder(w).Encode(data); err != nil {
-		log.Printf("Failed to encode JSON: %v", err)
-	}
+	soajsgo.WriteData(w, response)
 }
@@ -11,6 +11,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	soajsgo "github.com/soajs/soajs.golang"
+	"github.com/soajs/soajs.golang/soajsgin"
 )
 
 // Gin framework example with SOAJS middleware
@@ -53,8 +54,8 @@ func main() {
 	router.Use(gin.Logger())
 	router.Use(gin.Recovery())
 
-	// Add SOAJS middleware wrapper for Gin
-	router.Use(soajsMiddleware(registry))
+	// Add SOAJS middleware for Gin
+	router.Use(soajsgin.Middleware(registry))
 
 	// Define routes
 	router.GET("/", rootHandler)
@@ -99,22 +100,6 @@ func main() {
 	log.Println("Server stopped")
 }
 
-// soajsMiddleware wraps the SOAJS middleware for Gin
-func soajsMiddleware(registry *soajsgo.Registry) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Create a wrapped handler that calls the next middleware
-		handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			// Update the Gin context with the new request context
-			c.Request = r
-			c.Next()
-		})
-
-		// Apply SOAJS middleware
-		soajsHandler := registry.Middleware(handler)
-		soajsHandler.ServeHTTP(c.Writer, c.Request)
-	}
-}
-
 // rootHandler handles the root endpoint
 func rootHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{
@@ -125,17 +110,15 @@ func rootHandler(c *gin.Context) {
 
 // tenantInfoHandler demonstrates accessing SOAJS context data
 func tenantInfoHandler(c *gin.Context) {
-	// Get SOAJS context from request
-	soaData := c.Request.Context().Value(soajsgo.SoajsKey)
-	if soaData == nil {
+	// Get SOAJS context from gin context
+	context, ok := soajsgin.Data(c)
+	if !ok {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "No SOAJS context available",
 		})
 		return
 	}
 
-	context := soaData.(soajsgo.ContextData)
-
 	response := gin.H{
 		"tenant_id":   context.Tenant.ID,
 		"tenant_code": context.Tenant.Code,
@@ -223,7 +206,7 @@ func makeCustomConfigHandler(registry *soajsgo.Registry) gin.HandlerFunc {
 			// Return specific custom registry
 			customReg := custom.(*soajsgo.CustomRegistry)
 			c.JSON(http.StatusOK, gin.H{
-				"name":   name,
+				"name": name,
 				"custom": gin.H{
 					"id":      customReg.ID,
 					"name":    customReg.Name,
@@ -5,6 +5,7 @@ go 1.21
 require (
 	github.com/gin-gonic/gin v1.9.1
 	github.com/soajs/soajs.golang v0.0.0
+	github.com/soajs/soajs.golang/soajsgin v0.0.0
 )
 
 require (
@@ -35,3 +36,5 @@ require (
 )
 
 replace github.com/soajs/soajs.golang => ../
+
+replace github.com/soajs/soajs.golang/soajsgin => ../soajsgin
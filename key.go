@@ -0,0 +1,206 @@
+package soajsgo
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+const (
+	// defaultKeyAlgorithm is the cipher of the external keys when the service config has none.
+	defaultKeyAlgorithm = "aes256"
+	// extKeyPrefixLength is the length of the random prefix of a plain external key.
+	extKeyPrefixLength = 5
+	// extKeyLengthDigits is the number of digits of the lengths of a plain external key.
+	extKeyLengthDigits = 2
+)
+
+var (
+	// ErrInvalidExtKey is returned when an external key cannot be decrypted with the service key config.
+	ErrInvalidExtKey = errors.New("invalid external key")
+	// ErrKeyMismatch is returned when the external key of a request does not belong to its tenant or internal key.
+	ErrKeyMismatch = errors.New("external key does not match tenant key")
+)
+
+// KeyInfo is what an external key holds: the id of its tenant and its internal key.
+type KeyInfo struct {
+	TenantID string
+	Key      string
+}
+
+// DecryptExtKey decrypts an external key with the algorithm and password of the service key config. It expects the
+// plain layout GenerateExtKey produces, which has not been checked against the external keys of SOAJS core.
+func DecryptExtKey(extKey string, conf ServiceKey) (KeyInfo, error) {
+	block, iv, err := newKeyCipher(conf)
+	if err != nil {
+		return KeyInfo{}, err
+	}
+	data, err := hex.DecodeString(extKey)
+	if err != nil || len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return KeyInfo{}, ErrInvalidExtKey
+	}
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(data, data)
+	plain, ok := pkcs7Unpad(data)
+	if !ok {
+		return KeyInfo{}, ErrInvalidExtKey
+	}
+	return parsePlainExtKey(string(plain))
+}
+
+// GenerateExtKey returns an external key of the tenant for the internal key, encrypted with the algorithm and
+// password of the service key config. It is mostly useful for test fixtures.
+func GenerateExtKey(tenantID, key string, conf ServiceKey) (string, error) {
+	block, iv, err := newKeyCipher(conf)
+	if err != nil {
+		return "", err
+	}
+	prefix, err := randomLetters(extKeyPrefixLength)
+	if err != nil {
+		return "", fmt.Errorf("could not generate external key: %v", err)
+	}
+	var plain strings.Builder
+	plain.WriteString(prefix)
+	for _, part := range []string{tenantID, key} {
+		if len(part) == 0 || len(part) > 99 {
+			return "", fmt.Errorf("could not generate external key: length of %q must be between 1 and 99", part)
+		}
+		fmt.Fprintf(&plain, "%0*d%s", extKeyLengthDigits, len(part), part)
+	}
+	data := pkcs7Pad([]byte(plain.String()))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+	return hex.EncodeToString(data), nil
+}
+
+// DecryptExtKey decrypts an external key with the key config of the registry.
+func (reg *Registry) DecryptExtKey(extKey string) (KeyInfo, error) {
+	return DecryptExtKey(extKey, reg.serviceKey())
+}
+
+// GenerateExtKey generates an external key with the key config of the registry.
+func (reg *Registry) GenerateExtKey(tenantID, key string) (string, error) {
+	return GenerateExtKey(tenantID, key, reg.serviceKey())
+}
+
+// ValidateKey checks that the external key of the request decrypts, with the key config of the registry, to the
+// tenant and internal key of the request.
+func (c ContextData) ValidateKey() error {
+	if c.Reg == nil {
+		return errors.New("could not validate key: no registry")
+	}
+	info, err := c.Reg.DecryptExtKey(c.Tenant.Key.EKey)
+	if err != nil {
+		return err
+	}
+	if info.TenantID != c.Tenant.ID || (c.Tenant.Key.IKey != "" && info.Key != c.Tenant.Key.IKey) {
+		return ErrKeyMismatch
+	}
+	return nil
+}
+
+func (reg *Registry) serviceKey() ServiceKey {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return reg.ServiceConfig.Key
+}
+
+// newKeyCipher returns the block cipher and the iv of the service key config: the aes256 algorithm of Node.js
+// createCipher, AES-256 in CBC mode.
+func newKeyCipher(conf ServiceKey) (cipher.Block, []byte, error) {
+	switch strings.ToLower(conf.Algorithm) {
+	case "", defaultKeyAlgorithm, "aes-256-cbc":
+	default:
+		return nil, nil, fmt.Errorf("unsupported key algorithm %s", conf.Algorithm)
+	}
+	if conf.Password == "" {
+		return nil, nil, errors.New("could not find key password in service config")
+	}
+	key, iv := evpBytesToKey(conf.Password)
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, nil, err
+	}
+	return block, iv[:], nil
+}
+
+// evpBytesToKey derives the key and iv from the password the way OpenSSL EVP_BytesToKey does with MD5, one
+// iteration and no salt, as Node.js createCipher does.
+func evpBytesToKey(password string) ([32]byte, [aes.BlockSize]byte) {
+	var (
+		derived []byte
+		prev    []byte
+	)
+	for len(derived) < 32+aes.BlockSize {
+		sum := md5.Sum(append(prev, password...))
+		prev = sum[:]
+		derived = append(derived, prev...)
+	}
+	var (
+		key [32]byte
+		iv  [aes.BlockSize]byte
+	)
+	copy(key[:], derived[:32])
+	copy(iv[:], derived[32:])
+	return key, iv
+}
+
+// parsePlainExtKey parses a plain external key: a random prefix, then the tenant id and the internal key, each
+// preceded by its length on two digits.
+func parsePlainExtKey(plain string) (KeyInfo, error) {
+	rest := plain
+	if len(rest) < extKeyPrefixLength {
+		return KeyInfo{}, ErrInvalidExtKey
+	}
+	rest = rest[extKeyPrefixLength:]
+	var parts [2]string
+	for i := range parts {
+		if len(rest) < extKeyLengthDigits {
+			return KeyInfo{}, ErrInvalidExtKey
+		}
+		n, err := strconv.Atoi(rest[:extKeyLengthDigits])
+		rest = rest[extKeyLengthDigits:]
+		if err != nil || n <= 0 || n > len(rest) {
+			return KeyInfo{}, ErrInvalidExtKey
+		}
+		parts[i], rest = rest[:n], rest[n:]
+	}
+	return KeyInfo{TenantID: parts[0], Key: parts[1]}, nil
+}
+
+func randomLetters(n int) (string, error) {
+	const letters = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	b := make([]byte, n)
+	for i := range b {
+		idx, err := rand.Int(rand.Reader, big.NewInt(int64(len(letters))))
+		if err != nil {
+			return "", err
+		}
+		b[i] = letters[idx.Int64()]
+	}
+	return string(b), nil
+}
+
+func pkcs7Pad(b []byte) []byte {
+	n := aes.BlockSize - len(b)%aes.BlockSize
+	return append(b, bytes.Repeat([]byte{byte(n)}, n)...)
+}
+
+func pkcs7Unpad(b []byte) ([]byte, bool) {
+	n := int(b[len(b)-1])
+	if n == 0 || n > aes.BlockSize || n > len(b) {
+		return nil, false
+	}
+	for _, c := range b[len(b)-n:] {
+		if int(c) != n {
+			return nil, false
+		}
+	}
+	return b[:len(b)-n], true
+}
@@ -0,0 +1,127 @@
+package soajsgo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testServiceKey = ServiceKey{Algorithm: "aes256", Password: "soajs key lal massa"}
+
+// testExtKey is the external key of tenant 5551aca9e67c0e2f0d2d2a31 and internal key
+// d1eaa1a5f3bc66b8a7f2b1b7c3f4e5d6, in the plain layout of GenerateExtKey with the prefix abcde. It does not come from
+// SOAJS core, it is encrypted independently of this package by OpenSSL, deriving the key and iv as Node.js
+// createCipher does:
+//
+//	printf '%s' abcde245551aca9e67c0e2f0d2d2a3132d1eaa1a5f3bc66b8a7f2b1b7c3f4e5d6 |
+//		openssl enc -aes-256-cbc -md md5 -nosalt -pass 'pass:soajs key lal massa' | xxd -p -c 0
+const testExtKey = "f63e361f2c7ffa27d5d4f3f6f0778bff587f089536340f41743644d2e52d5a008fc5c9ffa4140fda5bb98585a2fffd44" +
+	"041d53512d2e43eb5d4ad58af8f7105b019381b53484db1618378519cafeb00c"
+
+func TestDecryptExtKey(t *testing.T) {
+	tt := []struct {
+		name         string
+		extKey       string
+		conf         ServiceKey
+		expectedInfo KeyInfo
+		expectedErr  string
+	}{
+		{
+			name:         "openssl key",
+			extKey:       testExtKey,
+			conf:         testServiceKey,
+			expectedInfo: KeyInfo{TenantID: "5551aca9e67c0e2f0d2d2a31", Key: "d1eaa1a5f3bc66b8a7f2b1b7c3f4e5d6"},
+		},
+		{
+			name:         "default algorithm",
+			extKey:       testExtKey,
+			conf:         ServiceKey{Password: testServiceKey.Password},
+			expectedInfo: KeyInfo{TenantID: "5551aca9e67c0e2f0d2d2a31", Key: "d1eaa1a5f3bc66b8a7f2b1b7c3f4e5d6"},
+		},
+		{name: "wrong password", extKey: testExtKey, conf: ServiceKey{Password: "other"}, expectedErr: "invalid external key"},
+		{name: "not hex", extKey: "zz", conf: testServiceKey, expectedErr: "invalid external key"},
+		{name: "partial block", extKey: testExtKey[:30], conf: testServiceKey, expectedErr: "invalid external key"},
+		{name: "unsupported algorithm", extKey: testExtKey, conf: ServiceKey{Algorithm: "des", Password: "p"}, expectedErr: "unsupported key algorithm des"},
+		{name: "no password", extKey: testExtKey, conf: ServiceKey{Algorithm: "aes256"}, expectedErr: "could not find key password in service config"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			info, err := DecryptExtKey(tc.extKey, tc.conf)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedInfo, info)
+		})
+	}
+}
+
+func TestGenerateExtKey(t *testing.T) {
+	extKey, err := GenerateExtKey("t1", "ikey", testServiceKey)
+	require.NoError(t, err)
+	other, err := GenerateExtKey("t1", "ikey", testServiceKey)
+	require.NoError(t, err)
+	assert.NotEqual(t, extKey, other, "external keys must have a random prefix")
+
+	info, err := DecryptExtKey(extKey, testServiceKey)
+	require.NoError(t, err)
+	assert.Equal(t, KeyInfo{TenantID: "t1", Key: "ikey"}, info)
+
+	_, err = GenerateExtKey("", "ikey", testServiceKey)
+	assert.EqualError(t, err, `could not generate external key: length of "" must be between 1 and 99`)
+}
+
+func TestParsePlainExtKey(t *testing.T) {
+	tt := []struct {
+		name         string
+		plain        string
+		expectedInfo KeyInfo
+		expectedErr  error
+	}{
+		{name: "ok", plain: "abcde02t104ikey", expectedInfo: KeyInfo{TenantID: "t1", Key: "ikey"}},
+		{name: "trailing data", plain: "abcde02t104ikeyextra", expectedInfo: KeyInfo{TenantID: "t1", Key: "ikey"}},
+		{name: "short", plain: "abc", expectedErr: ErrInvalidExtKey},
+		{name: "no length", plain: "abcde", expectedErr: ErrInvalidExtKey},
+		{name: "bad length", plain: "abcdexxt1", expectedErr: ErrInvalidExtKey},
+		{name: "truncated", plain: "abcde02t110ikey", expectedErr: ErrInvalidExtKey},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			info, err := parsePlainExtKey(tc.plain)
+			assert.Equal(t, tc.expectedErr, err)
+			assert.Equal(t, tc.expectedInfo, info)
+		})
+	}
+}
+
+func TestContextData_ValidateKey(t *testing.T) {
+	reg := &Registry{}
+	reg.ServiceConfig.Key = testServiceKey
+	extKey, err := reg.GenerateExtKey("t1", "ikey")
+	require.NoError(t, err)
+
+	tt := []struct {
+		name        string
+		data        ContextData
+		expectedErr string
+	}{
+		{name: "valid", data: ContextData{Tenant: Tenant{ID: "t1", Key: Key{IKey: "ikey", EKey: extKey}}, Reg: reg}},
+		{name: "no internal key", data: ContextData{Tenant: Tenant{ID: "t1", Key: Key{EKey: extKey}}, Reg: reg}},
+		{name: "other tenant", data: ContextData{Tenant: Tenant{ID: "t2", Key: Key{IKey: "ikey", EKey: extKey}}, Reg: reg}, expectedErr: ErrKeyMismatch.Error()},
+		{name: "other internal key", data: ContextData{Tenant: Tenant{ID: "t1", Key: Key{IKey: "other", EKey: extKey}}, Reg: reg}, expectedErr: ErrKeyMismatch.Error()},
+		{name: "invalid key", data: ContextData{Tenant: Tenant{ID: "t1", Key: Key{EKey: "00"}}, Reg: reg}, expectedErr: ErrInvalidExtKey.Error()},
+		{name: "no registry", data: ContextData{Tenant: Tenant{ID: "t1", Key: Key{EKey: extKey}}}, expectedErr: "could not validate key: no registry"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.data.ValidateKey()
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
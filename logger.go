@@ -0,0 +1,201 @@
+package soajsgo
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+)
+
+// HeaderRequestID is the header carrying the id of a request, logged with the request logger.
+const HeaderRequestID = "X-Request-Id"
+
+// Levels of the SOAJS logger that slog does not define.
+const (
+	LevelTrace = slog.LevelDebug - 4
+	LevelFatal = slog.LevelError + 4
+)
+
+type (
+	// registryHandler is the slog.Handler of the registry logger. It delegates to a handler built from
+	// ServiceConfig.Logger, so loggers handed out before a reload follow the reloaded configuration.
+	registryHandler struct {
+		reg *Registry
+		ops []func(slog.Handler) slog.Handler
+
+		mu      sync.Mutex
+		base    slog.Handler
+		derived slog.Handler
+	}
+)
+
+// WithLogOutput writes the registry logs to w instead of os.Stderr.
+func WithLogOutput(w io.Writer) RegistryOption {
+	return func(reg *Registry) {
+		reg.logOutput = w
+	}
+}
+
+// Logger returns the logger of the service, configured by the ServiceConfig.Logger section of the registry: the
+// level, source locations (Src), the JSON (outputMode json or bunyan) or text format, and level names instead of
+// bunyan level numbers (LevelInString). The logger follows the configuration of every reload.
+func (reg *Registry) Logger() *slog.Logger {
+	if reg == nil {
+		return slog.Default()
+	}
+	return slog.New(&registryHandler{reg: reg})
+}
+
+// Logger returns the logger of the request, with the tenant code, application id, urac id and request id.
+func (c ContextData) Logger() *slog.Logger {
+	var attrs []any
+	for _, a := range []struct{ key, value string }{
+		{"tenant", c.Tenant.Code},
+		{"appId", c.Tenant.Application.AppID},
+		{"uracId", c.Urac.ID},
+		{"requestId", c.RequestID},
+	} {
+		if a.value != "" {
+			attrs = append(attrs, slog.String(a.key, a.value))
+		}
+	}
+	return c.Reg.Logger().With(attrs...)
+}
+
+// logHandler returns the handler built from the current logger configuration.
+func (reg *Registry) logHandler() slog.Handler {
+	reg.mu.RLock()
+	h := reg.logBase
+	reg.mu.RUnlock()
+	if h != nil {
+		return h
+	}
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+	if reg.logBase == nil {
+		reg.logBase = newLogHandler(reg.ServiceConfig.Logger, reg.logOutput)
+	}
+	return reg.logBase
+}
+
+// newLogHandler builds the handler of the logger configuration, writing to w or os.Stderr when w is nil.
+func newLogHandler(conf Logger, w io.Writer) slog.Handler {
+	if w == nil {
+		w = os.Stderr
+	}
+	opts := &slog.HandlerOptions{
+		AddSource: conf.Src,
+		Level:     parseLevel(conf.Level),
+	}
+	levelInString := conf.Formatter.LevelInString
+	opts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+		if a.Key != slog.LevelKey || len(groups) > 0 {
+			return a
+		}
+		level, _ := a.Value.Any().(slog.Level)
+		if levelInString {
+			return slog.String(a.Key, levelName(level))
+		}
+		return slog.Int(a.Key, bunyanLevel(level))
+	}
+	switch strings.ToLower(conf.Formatter.OutputMode) {
+	case "json", "bunyan":
+		return slog.NewJSONHandler(w, opts)
+	default:
+		return slog.NewTextHandler(w, opts)
+	}
+}
+
+// parseLevel maps the bunyan level names SOAJS uses to slog levels, defaulting to info.
+func parseLevel(level string) slog.Level {
+	switch strings.ToLower(level) {
+	case "trace":
+		return LevelTrace
+	case "debug":
+		return slog.LevelDebug
+	case "warn":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	case "fatal":
+		return LevelFatal
+	default:
+		return slog.LevelInfo
+	}
+}
+
+func levelName(level slog.Level) string {
+	switch {
+	case level <= LevelTrace:
+		return "trace"
+	case level >= LevelFatal:
+		return "fatal"
+	default:
+		return strings.ToLower(level.String())
+	}
+}
+
+// bunyanLevel returns the bunyan level number of the level: 10 for trace to 60 for fatal.
+func bunyanLevel(level slog.Level) int {
+	switch {
+	case level <= LevelTrace:
+		return 10
+	case level < slog.LevelInfo:
+		return 20
+	case level < slog.LevelWarn:
+		return 30
+	case level < slog.LevelError:
+		return 40
+	case level < LevelFatal:
+		return 50
+	default:
+		return 60
+	}
+}
+
+// Enabled implements slog.Handler.
+func (h *registryHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.handler().Enabled(ctx, level)
+}
+
+// Handle implements slog.Handler.
+func (h *registryHandler) Handle(ctx context.Context, r slog.Record) error {
+	return h.handler().Handle(ctx, r)
+}
+
+// WithAttrs implements slog.Handler.
+func (h *registryHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler {
+		return next.WithAttrs(attrs)
+	})
+}
+
+// WithGroup implements slog.Handler.
+func (h *registryHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler {
+		return next.WithGroup(name)
+	})
+}
+
+func (h *registryHandler) with(op func(slog.Handler) slog.Handler) slog.Handler {
+	ops := make([]func(slog.Handler) slog.Handler, 0, len(h.ops)+1)
+	return &registryHandler{reg: h.reg, ops: append(append(ops, h.ops...), op)}
+}
+
+// handler returns the handler of the current configuration with the attributes and groups of h applied. The
+// result is cached until a reload changes the configuration.
+func (h *registryHandler) handler() slog.Handler {
+	base := h.reg.logHandler()
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.base != base {
+		derived := base
+		for _, op := range h.ops {
+			derived = op(derived)
+		}
+		h.base, h.derived = base, derived
+	}
+	return h.derived
+}
@@ -0,0 +1,101 @@
+package soajsgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLevel(t *testing.T) {
+	tt := []struct {
+		level         string
+		expectedLevel slog.Level
+		expectedName  string
+		expectedNum   int
+	}{
+		{level: "trace", expectedLevel: LevelTrace, expectedName: "trace", expectedNum: 10},
+		{level: "DEBUG", expectedLevel: slog.LevelDebug, expectedName: "debug", expectedNum: 20},
+		{level: "", expectedLevel: slog.LevelInfo, expectedName: "info", expectedNum: 30},
+		{level: "unknown", expectedLevel: slog.LevelInfo, expectedName: "info", expectedNum: 30},
+		{level: "warn", expectedLevel: slog.LevelWarn, expectedName: "warn", expectedNum: 40},
+		{level: "error", expectedLevel: slog.LevelError, expectedName: "error", expectedNum: 50},
+		{level: "fatal", expectedLevel: LevelFatal, expectedName: "fatal", expectedNum: 60},
+	}
+	for _, tc := range tt {
+		t.Run(tc.level, func(t *testing.T) {
+			level := parseLevel(tc.level)
+			assert.Equal(t, tc.expectedLevel, level)
+			assert.Equal(t, tc.expectedName, levelName(level))
+			assert.Equal(t, tc.expectedNum, bunyanLevel(level))
+		})
+	}
+}
+
+func TestRegistryLogger(t *testing.T) {
+	var out bytes.Buffer
+	reg := &Registry{}
+	WithLogOutput(&out)(reg)
+	logger := reg.Logger().With("component", "test")
+
+	logger.Info("before load")
+	assert.Contains(t, out.String(), `msg="before load" component=test`)
+	assert.Contains(t, out.String(), "level=30")
+
+	out.Reset()
+	reg.update(&Registry{ServiceConfig: ServiceConfig{Logger: Logger{
+		Level:     "warn",
+		Formatter: Formatter{OutputMode: "json"},
+	}}})
+	logger.Info("filtered")
+	logger.Warn("kept")
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, "kept", entry["msg"])
+	assert.Equal(t, float64(40), entry["level"])
+	assert.Equal(t, "test", entry["component"])
+
+	out.Reset()
+	reg.update(&Registry{ServiceConfig: ServiceConfig{Logger: Logger{
+		Level:     "debug",
+		Src:       true,
+		Formatter: Formatter{OutputMode: "short", LevelInString: true},
+	}}})
+	logger.Debug("reloaded")
+	assert.Contains(t, out.String(), "level=debug")
+	assert.Contains(t, out.String(), "source=")
+	assert.Contains(t, out.String(), `msg=reloaded component=test`)
+}
+
+func TestContextDataLogger(t *testing.T) {
+	var out bytes.Buffer
+	reg := &Registry{}
+	WithLogOutput(&out)(reg)
+	reg.update(&Registry{ServiceConfig: ServiceConfig{Logger: Logger{Formatter: Formatter{OutputMode: "json"}}}})
+	out.Reset()
+
+	handler := reg.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c, ok := FromContext(r.Context())
+		require.True(t, ok)
+		c.Logger().Info("handled")
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(HeaderInjectObj, `{"tenant":{"code":"TNT"},"application":{"appId":"app"},"urac":{"_id":"u1"}}`)
+	req.Header.Set(HeaderRequestID, "req-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, "TNT", entry["tenant"])
+	assert.Equal(t, "app", entry["appId"])
+	assert.Equal(t, "u1", entry["uracId"])
+	assert.Equal(t, "req-1", entry["requestId"])
+
+	// without registry the default logger is used
+	assert.NotNil(t, ContextData{}.Logger())
+}
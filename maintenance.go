@@ -0,0 +1,159 @@
+package soajsgo
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maintenance port types from the service configuration.
+	maintenancePortInherit     = "inherit"
+	maintenancePortMaintenance = "maintenance"
+	maintenancePortCustom      = "custom"
+
+	// defaultMaintenanceInc is used when the registry does not provide ports.maintenanceInc.
+	defaultMaintenanceInc = 1000
+)
+
+type (
+	// MaintenanceHandler serves the SOAJS maintenance routes of a service: the readiness route, /heartbeat,
+	// /reloadRegistry and ResourceUsageRoute. More routes can be added with Handle.
+	MaintenanceHandler struct {
+		mu     sync.RWMutex
+		reg    *Registry
+		config Config
+		routes map[string]http.Handler
+	}
+
+	// maintenanceResponse is the response of a maintenance route.
+	maintenanceResponse struct {
+		Result  bool        `json:"result"`
+		Ts      int64       `json:"ts"`
+		Service serviceInfo `json:"service"`
+		Data    interface{} `json:"data,omitempty"`
+	}
+)
+
+// NewMaintenanceHandler creates the maintenance routes of the service described by config.
+func NewMaintenanceHandler(reg *Registry, config Config) *MaintenanceHandler {
+	m := &MaintenanceHandler{
+		reg:    reg,
+		config: config,
+		routes: make(map[string]http.Handler),
+	}
+	heartbeat := http.HandlerFunc(m.heartbeat)
+	m.Handle("/heartbeat", heartbeat)
+	if config.Maintenance.Readiness != "" {
+		m.Handle(config.Maintenance.Readiness, heartbeat)
+	}
+	m.Handle("/reloadRegistry", http.HandlerFunc(m.reloadRegistry))
+	m.Handle(ResourceUsageRoute, http.HandlerFunc(m.resourceUsage))
+	return m
+}
+
+// Handle registers the handler for the maintenance route, replacing any previous one.
+func (m *MaintenanceHandler) Handle(route string, h http.Handler) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.routes[route] = h
+}
+
+// ServeHTTP implements http.Handler.
+func (m *MaintenanceHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h, ok := m.route(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	h.ServeHTTP(w, r)
+}
+
+// Wrap returns a handler serving the maintenance routes and passing any other request to next.
+// It is used when the maintenance port type is inherit.
+func (m *MaintenanceHandler) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h, ok := m.route(r.URL.Path); ok {
+			h.ServeHTTP(w, r)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// WriteResponse writes a maintenance response with data for the route of r.
+func (m *MaintenanceHandler) WriteResponse(w http.ResponseWriter, r *http.Request, result bool, data interface{}) {
+	status := http.StatusOK
+	if !result {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(maintenanceResponse{
+		Result: result,
+		Ts:     time.Now().UnixMilli(),
+		Service: serviceInfo{
+			ServiceName: strings.ToUpper(m.config.ServiceName),
+			Type:        m.serviceType(),
+			Route:       r.URL.Path,
+		},
+		Data: data,
+	})
+}
+
+// serviceType returns the type of the service in the maintenance responses, rest or daemon.
+func (m *MaintenanceHandler) serviceType() string {
+	if m.config.Type == ServiceTypeDaemon {
+		return ServiceTypeDaemon
+	}
+	return "rest"
+}
+
+func (m *MaintenanceHandler) route(path string) (http.Handler, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	h, ok := m.routes[path]
+	return h, ok
+}
+
+func (m *MaintenanceHandler) heartbeat(w http.ResponseWriter, r *http.Request) {
+	m.WriteResponse(w, r, true, nil)
+}
+
+func (m *MaintenanceHandler) reloadRegistry(w http.ResponseWriter, r *http.Request) {
+	if m.reg == nil {
+		m.WriteResponse(w, r, false, nil)
+		return
+	}
+	if err := m.reg.ReloadContext(r.Context()); err != nil {
+		m.WriteResponse(w, r, false, nil)
+		return
+	}
+	m.WriteResponse(w, r, true, m.reg)
+}
+
+func (m *MaintenanceHandler) resourceUsage(w http.ResponseWriter, r *http.Request) {
+	m.WriteResponse(w, r, true, resourceUsage(m.config))
+}
+
+// maintenancePort returns the port the maintenance routes listen on. Port type inherit shares the service port.
+func (c *Config) maintenancePort(reg *Registry) int {
+	switch c.Maintenance.Port.Type {
+	case maintenancePortCustom:
+		return c.Maintenance.Port.Value
+	case maintenancePortMaintenance:
+		inc := defaultMaintenanceInc
+		if reg != nil {
+			reg.mu.RLock()
+			if reg.ServiceConfig.Port.MaintenanceInc > 0 {
+				inc = reg.ServiceConfig.Port.MaintenanceInc
+			}
+			reg.mu.RUnlock()
+		}
+		return c.ServicePort + inc
+	default:
+		return c.ServicePort
+	}
+}
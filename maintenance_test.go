@@ -0,0 +1,98 @@
+package soajsgo
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig_maintenancePort(t *testing.T) {
+	tt := []struct {
+		name         string
+		portType     string
+		portValue    int
+		reg          *Registry
+		expectedPort int
+	}{
+		{
+			name:         "inherit",
+			portType:     maintenancePortInherit,
+			reg:          &Registry{},
+			expectedPort: 4000,
+		},
+		{
+			name:         "custom",
+			portType:     maintenancePortCustom,
+			portValue:    4321,
+			reg:          &Registry{},
+			expectedPort: 4321,
+		},
+		{
+			name:         "maintenance default increment",
+			portType:     maintenancePortMaintenance,
+			reg:          &Registry{},
+			expectedPort: 5000,
+		},
+		{
+			name:         "maintenance registry increment",
+			portType:     maintenancePortMaintenance,
+			reg:          &Registry{ServiceConfig: ServiceConfig{Port: ServicePort{MaintenanceInc: 100}}},
+			expectedPort: 4100,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			c := Config{ServicePort: 4000, Maintenance: maintenance{Port: maintenancePort{Type: tc.portType, Value: tc.portValue}}}
+			assert.Equal(t, tc.expectedPort, c.maintenancePort(tc.reg))
+		})
+	}
+}
+
+func TestMaintenanceHandler(t *testing.T) {
+	m := NewMaintenanceHandler(&Registry{}, Config{ServiceName: "test", Maintenance: maintenance{Readiness: "/ready"}})
+	m.Handle("/custom", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		m.WriteResponse(w, r, true, "custom data")
+	}))
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	tt := []struct {
+		name           string
+		handler        http.Handler
+		path           string
+		expectedStatus int
+		expectedResult bool
+		expectedData   interface{}
+	}{
+		{name: "heartbeat", handler: m, path: "/heartbeat", expectedStatus: http.StatusOK, expectedResult: true},
+		{name: "readiness", handler: m, path: "/ready", expectedStatus: http.StatusOK, expectedResult: true},
+		{name: "custom", handler: m, path: "/custom", expectedStatus: http.StatusOK, expectedResult: true, expectedData: "custom data"},
+		{name: "failed reload", handler: m, path: "/reloadRegistry", expectedStatus: http.StatusServiceUnavailable},
+		{name: "resource usage", handler: m, path: ResourceUsageRoute, expectedStatus: http.StatusOK, expectedResult: true},
+		{name: "not found", handler: m, path: "/other", expectedStatus: http.StatusNotFound},
+		{name: "wrapped maintenance route", handler: m.Wrap(next), path: "/heartbeat", expectedStatus: http.StatusOK, expectedResult: true},
+		{name: "wrapped service route", handler: m.Wrap(next), path: "/other", expectedStatus: http.StatusTeapot},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tc.handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+			require.Equal(t, tc.expectedStatus, rec.Code)
+			if rec.Header().Get("Content-Type") != "application/json" {
+				return
+			}
+			var res maintenanceResponse
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&res))
+			assert.Equal(t, tc.expectedResult, res.Result)
+			if tc.path != ResourceUsageRoute {
+				assert.Equal(t, tc.expectedData, res.Data)
+			}
+			assert.Equal(t, tc.path, res.Service.Route)
+		})
+	}
+}
@@ -0,0 +1,231 @@
+package soajsgo
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// HeaderFailureMissing is the reason of a request without injected object.
+	HeaderFailureMissing = "missing"
+	// HeaderFailureInvalid is the reason of a request with an injected object that could not be parsed.
+	HeaderFailureInvalid = "invalid"
+
+	// DefaultMaxTenants is the default number of tenants a MetricsCollector counts the requests of by tenant code.
+	DefaultMaxTenants = 100
+	// TenantOther is the tenant label of the requests of the tenants beyond the maximum of a MetricsCollector.
+	TenantOther = "other"
+)
+
+type (
+	// Metrics receives the events of the registry, the middleware and Connect. Implementations must be safe for
+	// concurrent use.
+	Metrics interface {
+		// ObserveReload is called after each registry fetch with its duration and error.
+		ObserveReload(env string, d time.Duration, err error)
+		// RegistryLoaded is called when a fetched registry replaces the current one.
+		RegistryLoaded(env string, at time.Time)
+		// HeaderFailure is called when the middleware gets a request without usable injected object.
+		HeaderFailure(reason string)
+		// TenantRequest is called when the middleware injects the context of a request.
+		TenantRequest(tenant string)
+		// ConnectResolved is called when Connect resolves a service, either in the mesh or through the gateway.
+		ConnectResolved(service string, mesh bool)
+	}
+
+	// MetricsCollector is a dependency free Metrics implementation. It serves its values in the Prometheus text
+	// exposition format, e.g. on the maintenance port. To bound the number of series, the requests are counted by
+	// tenant code for the first DefaultMaxTenants tenants only, the others under TenantOther, see SetMaxTenants.
+	MetricsCollector struct {
+		mu             sync.Mutex
+		now            func() time.Time
+		maxTenants     int
+		reloads        map[[2]string]uint64
+		reloadSeconds  map[string]float64
+		reloadCount    map[string]uint64
+		loadedAt       map[string]time.Time
+		headerFailures map[string]uint64
+		tenantRequests map[string]uint64
+		connects       map[[2]string]uint64
+	}
+
+	nopMetrics struct{}
+)
+
+// WithMetrics records the registry, middleware and Connect events of the registry into m.
+func WithMetrics(m Metrics) RegistryOption {
+	return func(reg *Registry) {
+		reg.metrics = m
+	}
+}
+
+// NewMetricsCollector creates an empty collector.
+func NewMetricsCollector() *MetricsCollector {
+	return &MetricsCollector{
+		now:            time.Now,
+		maxTenants:     DefaultMaxTenants,
+		reloads:        make(map[[2]string]uint64),
+		reloadSeconds:  make(map[string]float64),
+		reloadCount:    make(map[string]uint64),
+		loadedAt:       make(map[string]time.Time),
+		headerFailures: make(map[string]uint64),
+		tenantRequests: make(map[string]uint64),
+		connects:       make(map[[2]string]uint64),
+	}
+}
+
+// ObserveReload implements Metrics.
+func (m *MetricsCollector) ObserveReload(env string, d time.Duration, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reloads[[2]string{env, result}]++
+	m.reloadSeconds[env] += d.Seconds()
+	m.reloadCount[env]++
+}
+
+// RegistryLoaded implements Metrics.
+func (m *MetricsCollector) RegistryLoaded(env string, at time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.loadedAt[env] = at
+}
+
+// HeaderFailure implements Metrics.
+func (m *MetricsCollector) HeaderFailure(reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.headerFailures[reason]++
+}
+
+// SetMaxTenants sets the number of tenants whose requests are counted by tenant code, the requests of the other
+// tenants being counted under TenantOther. Zero counts all the requests under TenantOther.
+func (m *MetricsCollector) SetMaxTenants(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.maxTenants = n
+}
+
+// TenantRequest implements Metrics.
+func (m *MetricsCollector) TenantRequest(tenant string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.tenantRequests[tenant]; !ok {
+		tenants := len(m.tenantRequests)
+		if _, ok := m.tenantRequests[TenantOther]; ok {
+			tenants--
+		}
+		if tenants >= m.maxTenants {
+			tenant = TenantOther
+		}
+	}
+	m.tenantRequests[tenant]++
+}
+
+// ConnectResolved implements Metrics.
+func (m *MetricsCollector) ConnectResolved(service string, mesh bool) {
+	route := "gateway"
+	if mesh {
+		route = "mesh"
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.connects[[2]string{service, route}]++
+}
+
+// ServeHTTP writes the metrics in the Prometheus text exposition format.
+func (m *MetricsCollector) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_, _ = m.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text exposition format.
+func (m *MetricsCollector) WriteTo(w io.Writer) (int64, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var b strings.Builder
+
+	writeHeader(&b, "soajs_registry_reloads_total", "counter", "Registry fetches by environment and result.")
+	for _, k := range sortedPairs(m.reloads) {
+		fmt.Fprintf(&b, "soajs_registry_reloads_total{env=%s,result=%s} %d\n", label(k[0]), label(k[1]), m.reloads[k])
+	}
+	writeHeader(&b, "soajs_registry_reload_duration_seconds", "summary", "Duration of the registry fetches.")
+	for _, env := range sortedKeys(m.reloadCount) {
+		fmt.Fprintf(&b, "soajs_registry_reload_duration_seconds_sum{env=%s} %g\n", label(env), m.reloadSeconds[env])
+		fmt.Fprintf(&b, "soajs_registry_reload_duration_seconds_count{env=%s} %d\n", label(env), m.reloadCount[env])
+	}
+	writeHeader(&b, "soajs_registry_age_seconds", "gauge", "Time since the registry was last loaded.")
+	now := m.now()
+	for _, env := range sortedKeys(m.loadedAt) {
+		fmt.Fprintf(&b, "soajs_registry_age_seconds{env=%s} %g\n", label(env), now.Sub(m.loadedAt[env]).Seconds())
+	}
+	writeHeader(&b, "soajs_middleware_header_failures_total", "counter", "Requests passed through without SOAJS context.")
+	for _, reason := range sortedKeys(m.headerFailures) {
+		fmt.Fprintf(&b, "soajs_middleware_header_failures_total{reason=%s} %d\n", label(reason), m.headerFailures[reason])
+	}
+	writeHeader(&b, "soajs_middleware_tenant_requests_total", "counter", "Requests with SOAJS context by tenant.")
+	for _, tenant := range sortedKeys(m.tenantRequests) {
+		fmt.Fprintf(&b, "soajs_middleware_tenant_requests_total{tenant=%s} %d\n", label(tenant), m.tenantRequests[tenant])
+	}
+	writeHeader(&b, "soajs_connect_resolutions_total", "counter", "Connect resolutions by service and route.")
+	for _, k := range sortedPairs(m.connects) {
+		fmt.Fprintf(&b, "soajs_connect_resolutions_total{service=%s,route=%s} %d\n", label(k[0]), label(k[1]), m.connects[k])
+	}
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// labelEscaper escapes label values as the exposition format requires.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func label(v string) string {
+	return `"` + labelEscaper.Replace(v) + `"`
+}
+
+func writeHeader(b *strings.Builder, name, kind, help string) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sortedPairs(m map[[2]string]uint64) [][2]string {
+	keys := make([][2]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i][0] != keys[j][0] {
+			return keys[i][0] < keys[j][0]
+		}
+		return keys[i][1] < keys[j][1]
+	})
+	return keys
+}
+
+func (reg *Registry) metricsOrNop() Metrics {
+	if reg == nil || reg.metrics == nil {
+		return nopMetrics{}
+	}
+	return reg.metrics
+}
+
+func (nopMetrics) ObserveReload(string, time.Duration, error) {}
+func (nopMetrics) RegistryLoaded(string, time.Time)           {}
+func (nopMetrics) HeaderFailure(string)                       {}
+func (nopMetrics) TenantRequest(string)                       {}
+func (nopMetrics) ConnectResolved(string, bool)               {}
@@ -0,0 +1,92 @@
+package soajsgo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsCollector(t *testing.T) {
+	var fail atomic.Bool
+	controller := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail.Load() {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`{"result":true,"data":{"name":"test","environment":"dev"}}`))
+	}))
+	defer controller.Close()
+	t.Setenv(EnvRegistryAPIAddress, strings.TrimPrefix(controller.URL, "http://"))
+
+	m := NewMetricsCollector()
+	reg, err := New(context.Background(), "test", "dev", "service", false, WithMetrics(m))
+	require.NoError(t, err)
+	assert.False(t, reg.LoadedAt().IsZero())
+	fail.Store(true)
+	require.Error(t, reg.Reload())
+
+	handler := reg.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, ok := FromContext(r.Context()); ok {
+			c.Connect("mesh-svc", "1")
+			c.Connect("other", "1")
+		}
+	}))
+	for _, header := range []string{
+		"",
+		"nil",
+		`{"tenant":{"code":"TNT"},"awareness":{"interConnect":[{"name":"mesh-svc","version":"1","latest":"1"}]}}`,
+		`{"tenant":{"code":"TNT"}}`,
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(HeaderInjectObj, header)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	m.now = func() time.Time {
+		return reg.LoadedAt().Add(90 * time.Second)
+	}
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+	out := rec.Body.String()
+	for _, line := range []string{
+		"# TYPE soajs_registry_reloads_total counter",
+		`soajs_registry_reloads_total{env="dev",result="failure"} 1`,
+		`soajs_registry_reloads_total{env="dev",result="success"} 1`,
+		`soajs_registry_reload_duration_seconds_count{env="dev"} 2`,
+		`soajs_registry_age_seconds{env="dev"} 90`,
+		`soajs_middleware_header_failures_total{reason="invalid"} 1`,
+		`soajs_middleware_header_failures_total{reason="missing"} 1`,
+		`soajs_middleware_tenant_requests_total{tenant="TNT"} 2`,
+		`soajs_connect_resolutions_total{service="mesh-svc",route="gateway"} 1`,
+		`soajs_connect_resolutions_total{service="mesh-svc",route="mesh"} 1`,
+		`soajs_connect_resolutions_total{service="other",route="gateway"} 2`,
+	} {
+		assert.Contains(t, out, line+"\n")
+	}
+}
+
+func TestMetricsCollector_TenantRequest(t *testing.T) {
+	m := NewMetricsCollector()
+	m.SetMaxTenants(2)
+	for _, tenant := range []string{"T1", "T2", "T3", "T1", "T4", "T2"} {
+		m.TenantRequest(tenant)
+	}
+	assert.Equal(t, map[string]uint64{"T1": 2, "T2": 2, TenantOther: 2}, m.tenantRequests)
+
+	m = NewMetricsCollector()
+	m.SetMaxTenants(0)
+	m.TenantRequest("T1")
+	assert.Equal(t, map[string]uint64{TenantOther: 1}, m.tenantRequests)
+}
+
+func TestLabel(t *testing.T) {
+	assert.Equal(t, `"a\\b\"c\nd"`, label("a\\b\"c\nd"))
+}
@@ -5,8 +5,10 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type (
@@ -14,44 +16,239 @@ type (
 )
 
 const (
-	// headerDataName is the SOAJS Gateway injected object attached to the header of each request
+	// HeaderInjectObj is the SOAJS Gateway injected object attached to the header of each request
 	// between the gateway and tech service.
-	headerDataName = "soajsinjectobj"
+	HeaderInjectObj = "soajsinjectobj"
 	// SoajsKey use this key to init soajs data from context.
 	SoajsKey = key(1)
 )
 
+type (
+	// MiddlewareOption configures the middleware returned by MiddlewareWith.
+	MiddlewareOption func(*middlewareOptions)
+
+	middlewareOptions struct {
+		strict          bool
+		aclService      string
+		aclVersion      string
+		timeout         time.Duration
+		timeoutRenewals int
+		schema          Schema
+		sessionStore    SessionStore
+		clientInfo      *clientInfo
+		devStore        *DevStore
+		devRefusedEnvs  []string
+	}
+)
+
+// Strict rejects the requests that do not carry a valid SOAJS injected object instead of passing them through
+// without context.
+func Strict() MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.strict = true
+	}
+}
+
+// WithACL checks every request carrying a SOAJS injected object against the tenant ACL of the service and version,
+// rejecting the ones that are not allowed. Combine it with Strict to reject requests without context too.
+func WithACL(serviceName, version string) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.aclService = serviceName
+		o.aclVersion = version
+	}
+}
+
 // Middleware is http middleware that gets triggered per request.
 func (reg *Registry) Middleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		d, err := headerData(r)
-		if err != nil {
-			next.ServeHTTP(w, r)
-			return
+	return reg.MiddlewareWith()(next)
+}
+
+// MiddlewareWith returns the http middleware configured by the options. When SOAJS_DEV_MODE enables the dev mode,
+// the requests without injected object get the context data the dev store resolves for their key and access_token,
+// see WithDevStore and LoadDevStore. The dev mode is refused, with an error logged, when SOAJS_ENV or the
+// environment of the registry is a production one, see WithDevRefusedEnvs, or the dev store cannot be loaded.
+func (reg *Registry) MiddlewareWith(opts ...MiddlewareOption) func(http.Handler) http.Handler {
+	var o middlewareOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	var env string
+	if reg != nil {
+		env = reg.env()
+	}
+	dev, err := devMode(o.devStore, o.devRefusedEnvs, env)
+	switch {
+	case err != nil:
+		reg.Logger().Error("SOAJS dev mode refused", "error", err)
+	case dev != nil:
+		reg.Logger().Warn("SOAJS dev mode enabled: requests without injected object get context data from the "+
+			"dev store, never use it in production", "env", os.Getenv(EnvSoajsEnv), "fixture", os.Getenv(EnvDevFixture))
+	}
+	return func(next http.Handler) http.Handler {
+		if len(o.schema.Methods) > 0 {
+			next = o.schema.Middleware(next)
 		}
-		out := ContextData{
-			Tenant:         d.Tenant,
-			Urac:           d.Urac,
-			ServicesConfig: d.Key.Config,
-			Device:         d.Device,
-			Geo:            d.Geo,
-			Awareness:      d.Awareness,
-			Reg:            reg,
+		if o.sessionStore != nil {
+			next = reg.sessionMiddleware(o.sessionStore, next)
 		}
-		out.Tenant.Key.IKey = d.Key.IKey
-		out.Tenant.Key.EKey = d.Key.EKey
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := ContextWithTraceParent(r.Context(), r.Header.Get(HeaderTraceParent))
+			ctx, span := reg.startSpan(ctx, SpanMiddleware,
+				Attribute{AttrHTTPMethod, r.Method},
+				Attribute{AttrURLPath, r.URL.Path},
+			)
+			defer span.End()
+			var timeout *timeoutContext
+			if o.timeout > 0 {
+				tw := newTimeoutWriter(w)
+				method, path := r.Method, r.URL.Path
+				var stop func()
+				timeout, stop = withTimeout(ctx, o.timeout, o.timeoutRenewals, func() {
+					span.RecordError(ErrRequestTimeout)
+					span.SetAttributes(Attribute{AttrHTTPStatus, strconv.Itoa(http.StatusServiceUnavailable)})
+					reg.Logger().Warn("request timed out", "method", method, "path", path, "timeout", o.timeout)
+					tw.timeout()
+				})
+				defer stop()
+				ctx, w = timeout, tw
+			}
+			r = r.WithContext(ctx)
 
-		out.Tenant.Application = d.Application
-		out.Tenant.Application.PackageACL = d.Package.ACL
-		out.Tenant.Application.PackageACLAllEnv = d.Package.ACLAllEnv
+			d, err := headerData(r)
+			// a malformed injected object is reported as invalid, the dev store only stands in for a missing one
+			if err != nil && dev != nil && r.Header.Get(HeaderInjectObj) == "" {
+				synthesized, devErr := dev.resolve(r)
+				if devErr != nil {
+					reg.Logger().Warn("request rejected in dev mode", "error", devErr, "path", r.URL.Path)
+					span.RecordError(devErr)
+					span.SetAttributes(Attribute{AttrHTTPStatus, strconv.Itoa(http.StatusUnauthorized)})
+					WriteError(w, CodeNoContext, devErr.Error())
+					return
+				}
+				if synthesized != nil {
+					reg.Logger().Debug("SOAJS context synthesized in dev mode", "tenant", synthesized.Tenant.Code, "path", r.URL.Path)
+					d, err = synthesized, nil
+				}
+			}
+			injected := err == nil
+			if !injected {
+				reason := HeaderFailureInvalid
+				if r.Header.Get(HeaderInjectObj) == "" {
+					reason = HeaderFailureMissing
+				}
+				reg.metricsOrNop().HeaderFailure(reason)
+				reg.Logger().Debug("request without SOAJS context", "reason", reason, "error", err, "path", r.URL.Path)
+				if o.strict {
+					span.RecordError(err)
+					span.SetAttributes(Attribute{AttrHTTPStatus, strconv.Itoa(http.StatusUnauthorized)})
+					WriteError(w, CodeNoContext, err.Error())
+					return
+				}
+				if o.clientInfo == nil {
+					next.ServeHTTP(w, r)
+					return
+				}
+				// Without the gateway, the context data only holds what the request tells about the client.
+				d = &headerInfo{}
+			}
+			out := newContextData(d, reg)
+			out.RequestID = r.Header.Get(HeaderRequestID)
+			out.TraceParent = span.TraceParent()
+			out.timeout = timeout
+			if o.clientInfo != nil {
+				o.clientInfo.fill(&out, r)
+			}
+			span.SetAttributes(out.spanAttributes()...)
+			if o.aclService != "" && injected {
+				if err := out.Allowed(o.aclService, o.aclVersion, r.Method, r.URL.Path); err != nil {
+					out.Logger().Info("request denied by ACL", "method", r.Method, "path", r.URL.Path, "error", err)
+					span.RecordError(err)
+					span.SetAttributes(Attribute{AttrHTTPStatus, strconv.Itoa(http.StatusForbidden)})
+					WriteError(w, CodeACLDenied, err.Error())
+					return
+				}
+			}
+			if injected {
+				reg.metricsOrNop().TenantRequest(out.Tenant.Code)
+			}
+			soajs := context.WithValue(r.Context(), SoajsKey, out)
+			next.ServeHTTP(w, r.WithContext(soajs))
+		})
+	}
+}
 
-		soajs := context.WithValue(r.Context(), SoajsKey, out)
-		next.ServeHTTP(w, r.WithContext(soajs))
-	})
+// FromContext returns the SOAJS data the middleware injected into the context.
+func FromContext(ctx context.Context) (ContextData, bool) {
+	c, ok := ctx.Value(SoajsKey).(ContextData)
+	return c, ok
+}
+
+func newContextData(d *headerInfo, reg *Registry) ContextData {
+	out := ContextData{
+		Tenant:         d.Tenant,
+		Urac:           d.Urac,
+		ServicesConfig: d.Key.Config,
+		Device:         d.Device,
+		Geo:            d.Geo,
+		Awareness:      d.Awareness,
+		Reg:            reg,
+	}
+	out.Tenant.Key.IKey = d.Key.IKey
+	out.Tenant.Key.EKey = d.Key.EKey
+
+	out.Tenant.Application = d.Application
+	out.Tenant.Application.PackageACL = d.Package.ACL
+	out.Tenant.Application.PackageACLAllEnv = d.Package.ACLAllEnv
+	return out
+}
+
+// ContextDataFromHeader parses the value of an injected object, as sent by the gateway in the soajsinjectobj header,
+// into context data bound to the registry. It lets transports other than HTTP, e.g. gRPC metadata, carry the
+// SOAJS context.
+func (reg *Registry) ContextDataFromHeader(value string) (ContextData, error) {
+	d, err := parseHeaderData(value)
+	if err != nil {
+		return ContextData{}, err
+	}
+	return newContextData(d, reg), nil
+}
+
+// InjectObj encodes the context data as the injected object forwarded to another service, the same way Connect
+// builds it for services found in the InterConnect mesh. Unlike MarshalJSON, the keys are not redacted.
+func (c ContextData) InjectObj() (string, error) {
+	b, err := json.Marshal(c.headerInfo())
+	if err != nil {
+		return "", fmt.Errorf("unable to encode SOAJS header: %v", err)
+	}
+	return string(b), nil
+}
+
+func (c ContextData) headerInfo() headerInfo {
+	return headerInfo{
+		Tenant: c.Tenant,
+		Key: Key{
+			IKey:   c.Tenant.Key.IKey,
+			EKey:   c.Tenant.Key.EKey,
+			Config: c.ServicesConfig,
+		},
+		Application: c.Tenant.Application,
+		Package: Package{
+			ACL:       c.Tenant.Application.PackageACL,
+			ACLAllEnv: c.Tenant.Application.PackageACLAllEnv,
+		},
+		Device:    c.Device,
+		Geo:       c.Geo,
+		Urac:      c.Urac,
+		Awareness: c.Awareness,
+	}
 }
 
 func headerData(r *http.Request) (*headerInfo, error) {
-	info := strings.NewReader(r.Header.Get(headerDataName))
+	return parseHeaderData(r.Header.Get(HeaderInjectObj))
+}
+
+func parseHeaderData(value string) (*headerInfo, error) {
+	info := strings.NewReader(value)
 	var d *headerInfo
 	if err := json.NewDecoder(info).Decode(&d); err != nil {
 		return nil, fmt.Errorf("unable to parse SOAJS header: %v", err)
@@ -113,25 +310,12 @@ func (c ContextData) Connect(args ...string) Connect {
 		}
 	}
 
+	c.Reg.metricsOrNop().ConnectResolved(serviceName, foundInMesh)
+	connectResponse.Headers.TraceParent = c.TraceParent
+
 	// Service found in mesh: use direct connection with full SOAJS context
 	if foundInMesh {
-		connectResponse.Headers.SoajsInjectobj = headerInfo{
-			Tenant: c.Tenant,
-			Key: Key{
-				IKey:   c.Tenant.Key.IKey,
-				EKey:   c.Tenant.Key.EKey,
-				Config: c.ServicesConfig,
-			},
-			Application: c.Tenant.Application,
-			Package: Package{
-				ACL:       c.Tenant.Application.PackageACL,
-				ACLAllEnv: c.Tenant.Application.PackageACLAllEnv,
-			},
-			Device:    c.Device,
-			Geo:       c.Geo,
-			Urac:      c.Urac,
-			Awareness: c.Awareness,
-		}
+		connectResponse.Headers.SoajsInjectobj = c.headerInfo()
 	} else {
 		// Service not found in mesh: fallback to gateway routing via Awareness.Path
 		connectResponse.Host = c.Awareness.Path(serviceName, version)
@@ -47,7 +47,7 @@ func TestRegistry_Middleware(t *testing.T) {
 				_, _ = w.Write([]byte("ok"))
 			})
 			req := httptest.NewRequest("", "http://localhost:8080/", nil)
-			req.Header.Set(headerDataName, tc.headerInfo)
+			req.Header.Set(HeaderInjectObj, tc.headerInfo)
 			rec := httptest.NewRecorder()
 			middleware := tc.reg.Middleware(handler)
 			middleware.ServeHTTP(rec, req)
@@ -78,7 +78,7 @@ func TestHeaderData(t *testing.T) {
 	for _, tc := range tt {
 		t.Run(tc.name, func(t *testing.T) {
 			req := httptest.NewRequest("", "http://localhost:8080/", nil)
-			req.Header.Set(headerDataName, tc.data)
+			req.Header.Set(HeaderInjectObj, tc.data)
 
 			info, err := headerData(req)
 			assert.Equal(t, tc.expectedErr, err)
@@ -129,3 +129,97 @@ func TestHost_Path(t *testing.T) {
 		})
 	}
 }
+
+func TestRegistry_MiddlewareWith(t *testing.T) {
+	acl := `{"application":{"acl":{"svc":{"access":false,"apisPermission":"restricted","apis":{"/allowed":{}}}}}}`
+	tt := []struct {
+		name           string
+		opts           []MiddlewareOption
+		headerInfo     string
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "no header passes through",
+			headerInfo:     "",
+			path:           "/",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "no context",
+		},
+		{
+			name:           "strict without header",
+			opts:           []MiddlewareOption{Strict()},
+			headerInfo:     "",
+			path:           "/",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"result":false,"errors":{"codes":[132],"details":[{"code":132,"message":"unable to parse SOAJS header: EOF"}]}}` + "\n",
+		},
+		{
+			name:           "strict with header",
+			opts:           []MiddlewareOption{Strict()},
+			headerInfo:     `{"tenant":{"code":"TNT"}}`,
+			path:           "/",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "TNT",
+		},
+		{
+			name:           "acl allowed",
+			opts:           []MiddlewareOption{WithACL("svc", "1")},
+			headerInfo:     acl,
+			path:           "/allowed",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "",
+		},
+		{
+			name:           "acl denied",
+			opts:           []MiddlewareOption{WithACL("svc", "1")},
+			headerInfo:     acl,
+			path:           "/denied",
+			expectedStatus: http.StatusForbidden,
+			expectedBody:   `{"result":false,"errors":{"codes":[154],"details":[{"code":154,"message":"access denied by ACL: GET /denied is not permitted"}]}}` + "\n",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				soa, ok := FromContext(r.Context())
+				if !ok {
+					_, _ = w.Write([]byte("no context"))
+					return
+				}
+				_, _ = w.Write([]byte(soa.Tenant.Code))
+			})
+			req := httptest.NewRequest(http.MethodGet, "http://localhost:8080"+tc.path, nil)
+			req.Header.Set(HeaderInjectObj, tc.headerInfo)
+			rec := httptest.NewRecorder()
+			(&Registry{}).MiddlewareWith(tc.opts...)(handler).ServeHTTP(rec, req)
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			assert.Equal(t, tc.expectedBody, rec.Body.String())
+		})
+	}
+}
+
+func TestContextData_InjectObj(t *testing.T) {
+	c := ContextData{
+		Tenant: Tenant{
+			Code:        "TNT",
+			Key:         Key{IKey: "ikey", EKey: "ekey"},
+			Application: Application{AppID: "app", PackageACL: map[string]interface{}{"svc": map[string]interface{}{}}},
+		},
+		ServicesConfig: map[string]interface{}{"svc": "conf"},
+		Urac:           Urac{ID: "1"},
+		Device:         "iPhone",
+	}
+	obj, err := c.InjectObj()
+	assert.NoError(t, err)
+
+	reg := &Registry{Name: "ok"}
+	decoded, err := reg.ContextDataFromHeader(obj)
+	assert.NoError(t, err)
+	c.Reg = reg
+	assert.Equal(t, c, decoded)
+
+	_, err = reg.ContextDataFromHeader("null")
+	assert.EqualError(t, err, "SOAJS header is empty or null")
+}
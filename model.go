@@ -1,6 +1,9 @@
 package soajsgo
 
 import (
+	"io"
+	"log/slog"
+	"net/http"
 	"sync"
 	"time"
 )
@@ -8,28 +11,30 @@ import (
 type (
 	// registerConf represents the config object to send to soajs gateway as post data.
 	registerConf struct {
-		Maintenance           maintenance  `json:"maintenance"`
-		InterConnect          interconnect `json:"interConnect"`
-		Name                  string       `json:"name"`
-		Group                 string       `json:"group"`
-		IP                    string       `json:"ip"`
-		Type                  string       `json:"type"`
-		Version               string       `json:"version"`
-		SubType               string       `json:"subType"`
-		Description           string       `json:"description"`
-		RequestTimeout        int          `json:"requestTimeout"`
-		RequestTimeoutRenewal int          `json:"requestTimeoutRenewal"`
-		Port                  int          `json:"port"`
-		Oauth                 bool         `json:"oauth"`
-		Urac                  bool         `json:"urac"`
-		UracProfile           bool         `json:"urac_Profile"`
-		UracACL               bool         `json:"urac_ACL"`
-		UracConfig            bool         `json:"urac_Config"`
-		UracGroupConfig       bool         `json:"urac_GroupConfig"`
-		TenantProfile         bool         `json:"tenant_Profile"`
-		ProvisionACL          bool         `json:"provision_ACL"`
-		ExtKeyRequired        bool         `json:"extKeyRequired"`
-		Middleware            bool         `json:"mw"`
+		Maintenance           maintenance   `json:"maintenance"`
+		InterConnect          interconnect  `json:"interConnect"`
+		Prerequisites         prerequisites `json:"prerequisites"`
+		Name                  string        `json:"name"`
+		Group                 string        `json:"group"`
+		IP                    string        `json:"ip"`
+		Type                  string        `json:"type"`
+		Version               string        `json:"version"`
+		SubType               string        `json:"subType"`
+		Description           string        `json:"description"`
+		RequestTimeout        int           `json:"requestTimeout"`
+		RequestTimeoutRenewal int           `json:"requestTimeoutRenewal"`
+		Port                  int           `json:"port"`
+		Oauth                 bool          `json:"oauth"`
+		Urac                  bool          `json:"urac"`
+		UracProfile           bool          `json:"urac_Profile"`
+		UracACL               bool          `json:"urac_ACL"`
+		UracConfig            bool          `json:"urac_Config"`
+		UracGroupConfig       bool          `json:"urac_GroupConfig"`
+		TenantProfile         bool          `json:"tenant_Profile"`
+		ProvisionACL          bool          `json:"provision_ACL"`
+		ExtKeyRequired        bool          `json:"extKeyRequired"`
+		Middleware            bool          `json:"mw"`
+		APIList               []API         `json:"apiList"`
 	}
 	maintenance struct {
 		Port      maintenancePort `json:"port"`
@@ -56,27 +61,24 @@ type (
 			Key            string      `json:"key"`
 			AccessToken    string      `json:"access_token"`
 			SoajsInjectobj interface{} `json:"soajsinjectobj"`
+			TraceParent    string      `json:"traceparent,omitempty"`
 		}
 	}
 
 	// registryAPIResponse represents registry API response from soajs.
 	registryAPIResponse struct {
-		Result  bool  `json:"result"`
-		Ts      int64 `json:"ts"`
-		Service struct {
-			ServiceName string `json:"service"`
-			Version     string `json:"version"`
-			Type        string `json:"type"`
-			Route       string `json:"route"`
-		} `json:"service"`
-		Registry Registry `json:"data"`
-		Errors   struct {
-			Codes   []int64 `json:"codes"`
-			Details []struct {
-				Code    int64  `json:"code"`
-				Message string `json:"message"`
-			} `json:"details"`
-		} `json:"errors"`
+		Result   bool        `json:"result"`
+		Ts       int64       `json:"ts"`
+		Service  serviceInfo `json:"service"`
+		Registry Registry    `json:"data"`
+		Errors   Errors      `json:"errors"`
+	}
+	// serviceInfo identifies the service answering a maintenance or registry API call.
+	serviceInfo struct {
+		ServiceName string `json:"service"`
+		Version     string `json:"version,omitempty"`
+		Type        string `json:"type"`
+		Route       string `json:"route"`
 	}
 	// Registry represents registry structure.
 	Registry struct {
@@ -91,6 +93,33 @@ type (
 		Custom        CustomRegistries    `json:"custom"`
 		Resources     Resources           `json:"resources"`
 		Services      map[string]Service  `json:"services"`
+
+		// serviceName is the name of the service the registry was created for, Name the one of the controller.
+		serviceName string
+		resolver    *SecretResolver
+		metrics     Metrics
+		tracer      Tracer
+		client      *http.Client
+		// controllerAddr is the address of the controller set by WithControllerAddress.
+		controllerAddr string
+		loadedAt       time.Time
+		// ts is the controller timestamp of the loaded registry, etag its ETag. raw is the registry API response
+		// it was decoded from, kept with a resolver to resolve the secret references again.
+		ts   int64
+		etag string
+		raw  []byte
+		// stats counts the reloads.
+		stats ReloadStats
+		// push enables the updates pushed by the controller, pushRetry is the delay to reconnect to them.
+		push      bool
+		pushRetry time.Duration
+		// reloadErr is the error of the last reload, nil when it succeeded.
+		reloadErr error
+		// reloadedCh is closed by the next successful reload, see reloaded.
+		reloadedCh chan struct{}
+		// logOutput is where the logger writes, logBase the handler built from ServiceConfig.Logger.
+		logOutput io.Writer
+		logBase   slog.Handler
 	}
 	// Database represents a Database structure with configuration fields.
 	Database struct {
@@ -232,21 +261,25 @@ type (
 		Urac           Urac                   `json:"urac"`
 		ServicesConfig map[string]interface{} `json:"servicesConfig"`
 		Device         string                 `json:"device"`
-		Geo            map[string]string      `json:"geo"`
+		Geo            Geo                    `json:"geo"`
 		Awareness      Host                   `json:"awareness"`
 		Reg            *Registry              `json:"reg"`
+		RequestID      string                 `json:"requestId,omitempty"`
+		TraceParent    string                 `json:"traceParent,omitempty"`
+
+		timeout *timeoutContext
 	}
 	// headerInfo represents header info structure.
 	headerInfo struct {
-		Tenant      Tenant            `json:"tenant"`
-		Key         Key               `json:"key"`
-		Application Application       `json:"application"`
-		Package     Package           `json:"package"`
-		Device      string            `json:"device"`
-		Geo         map[string]string `json:"geo"`
-		Urac        Urac              `json:"urac"`
-		Awareness   Host              `json:"awareness"`
-		Param       Param             `json:"param"`
+		Tenant      Tenant      `json:"tenant"`
+		Key         Key         `json:"key"`
+		Application Application `json:"application"`
+		Package     Package     `json:"package"`
+		Device      string      `json:"device"`
+		Geo         Geo         `json:"geo"`
+		Urac        Urac        `json:"urac"`
+		Awareness   Host        `json:"awareness"`
+		Param       Param       `json:"param"`
 	}
 	// Tenant contains the tenant information.
 	Tenant struct {
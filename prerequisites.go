@@ -0,0 +1,367 @@
+package soajsgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policies of Run when the prerequisites of the service exceed the cgroup limits.
+const (
+	// PrerequisitesWarn logs a warning and runs the service. It is the default.
+	PrerequisitesWarn PrerequisitesPolicy = iota
+	// PrerequisitesFail refuses to run the service.
+	PrerequisitesFail
+	// PrerequisitesIgnore runs the service without checking the prerequisites.
+	PrerequisitesIgnore
+)
+
+// ResourceUsageRoute is the maintenance route serving the resource usage of the service.
+const ResourceUsageRoute = "/resourceUsage"
+
+// cgroupUnlimited is the threshold above which a cgroup v1 memory limit means no limit.
+const cgroupUnlimited = 1 << 62
+
+var (
+	quantityRegexp = regexp.MustCompile(`^([0-9]+(?:\.[0-9]*)?|\.[0-9]+)((?:[eE][+-]?[0-9]+)|[KMGTPE]i|[numkMGTPE])?$`)
+
+	// quantitySuffixes are the multipliers of the quantity suffixes.
+	quantitySuffixes = map[string]*big.Rat{
+		"n": big.NewRat(1, 1e9), "u": big.NewRat(1, 1e6), "m": big.NewRat(1, 1e3), "": big.NewRat(1, 1),
+		"k": big.NewRat(1e3, 1), "M": big.NewRat(1e6, 1), "G": big.NewRat(1e9, 1), "T": big.NewRat(1e12, 1),
+		"P": big.NewRat(1e15, 1), "E": big.NewRat(1e18, 1),
+		"Ki": big.NewRat(1<<10, 1), "Mi": big.NewRat(1<<20, 1), "Gi": big.NewRat(1<<30, 1),
+		"Ti": big.NewRat(1<<40, 1), "Pi": big.NewRat(1<<50, 1), "Ei": big.NewRat(1<<60, 1),
+	}
+)
+
+type (
+	// Quantity is a Kubernetes style resource quantity, e.g. 500m of CPU or 256Mi of memory. It is precise to the
+	// thousandth.
+	Quantity struct {
+		milli int64
+	}
+
+	// ResourceQuantities are the CPU and memory of the prerequisites or limits of a service. Zero is not set.
+	ResourceQuantities struct {
+		CPU    Quantity
+		Memory Quantity
+	}
+
+	// PrerequisitesPolicy is what Run does when the prerequisites of the service exceed the cgroup limits.
+	PrerequisitesPolicy int
+
+	// ResourceUsage is the resource usage of the service, served on ResourceUsageRoute. The cgroup limits and usage
+	// are only known on Linux. Times are in milliseconds.
+	ResourceUsage struct {
+		Prerequisites ResourceQuantities `json:"prerequisites"`
+		Limits        ResourceQuantities `json:"limits"`
+		MemoryUsage   uint64             `json:"memoryUsage,omitempty"`
+		CPUTime       int64              `json:"cpuTime,omitempty"`
+		NumCPU        int                `json:"numCPU"`
+		GoMaxProcs    int                `json:"goMaxProcs"`
+		Goroutines    int                `json:"goroutines"`
+		HeapAlloc     uint64             `json:"heapAlloc"`
+		Sys           uint64             `json:"sys"`
+		NumGC         uint32             `json:"numGC"`
+	}
+
+	// cgroupStats are the limits and usage of the cgroup of the process.
+	cgroupStats struct {
+		limits      ResourceQuantities
+		memoryUsage uint64
+		cpuTime     time.Duration
+	}
+)
+
+// ParseQuantity parses a Kubernetes style quantity: a decimal number with an optional decimal (m, k, M, G...),
+// binary (Ki, Mi, Gi...) or exponent (e3) suffix. Values finer than a thousandth are rounded up.
+func ParseQuantity(s string) (Quantity, error) {
+	m := quantityRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return Quantity{}, fmt.Errorf("invalid quantity %q", s)
+	}
+	v, ok := new(big.Rat).SetString(m[1])
+	if !ok {
+		return Quantity{}, fmt.Errorf("invalid quantity %q", s)
+	}
+	if mult, ok := quantitySuffixes[m[2]]; ok {
+		v.Mul(v, mult)
+	} else {
+		exp, err := strconv.Atoi(m[2][1:])
+		if err != nil || exp > 30 || exp < -30 {
+			return Quantity{}, fmt.Errorf("invalid quantity %q", s)
+		}
+		pow := int64(exp)
+		if pow < 0 {
+			pow = -pow
+		}
+		scale := new(big.Rat).SetInt(new(big.Int).Exp(big.NewInt(10), big.NewInt(pow), nil))
+		if exp < 0 {
+			scale.Inv(scale)
+		}
+		v.Mul(v, scale)
+	}
+	v.Mul(v, big.NewRat(1000, 1))
+	milli := new(big.Int).Quo(v.Num(), v.Denom())
+	if !v.IsInt() {
+		milli.Add(milli, big.NewInt(1))
+	}
+	if !milli.IsInt64() {
+		return Quantity{}, fmt.Errorf("quantity %q is too large", s)
+	}
+	return Quantity{milli: milli.Int64()}, nil
+}
+
+// MilliValue returns the quantity in thousandths, e.g. millicores of CPU.
+func (q Quantity) MilliValue() int64 {
+	return q.milli
+}
+
+// Value returns the quantity rounded up, e.g. bytes of memory.
+func (q Quantity) Value() int64 {
+	v := q.milli / 1000
+	if q.milli%1000 != 0 {
+		v++
+	}
+	return v
+}
+
+// IsZero reports whether the quantity is zero, i.e. not set.
+func (q Quantity) IsZero() bool {
+	return q.milli == 0
+}
+
+// String returns the quantity with the largest binary suffix dividing it, or in thousandths with m.
+func (q Quantity) String() string {
+	if q.milli%1000 != 0 {
+		return fmt.Sprintf("%dm", q.milli)
+	}
+	v := q.milli / 1000
+	for _, suffix := range []string{"Ei", "Pi", "Ti", "Gi", "Mi", "Ki"} {
+		unit := quantitySuffixes[suffix].Num().Int64()
+		if v != 0 && v%unit == 0 {
+			return fmt.Sprintf("%d%s", v/unit, suffix)
+		}
+	}
+	return strconv.FormatInt(v, 10)
+}
+
+// MarshalJSON implements json.Marshaler.
+func (q Quantity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(q.String())
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (q *Quantity) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := ParseQuantity(s)
+	if err != nil {
+		return err
+	}
+	*q = v
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler, omitting the quantities that are not set.
+func (r ResourceQuantities) MarshalJSON() ([]byte, error) {
+	var out struct {
+		CPU    *Quantity `json:"cpu,omitempty"`
+		Memory *Quantity `json:"memory,omitempty"`
+	}
+	if !r.CPU.IsZero() {
+		out.CPU = &r.CPU
+	}
+	if !r.Memory.IsZero() {
+		out.Memory = &r.Memory
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (r *ResourceQuantities) UnmarshalJSON(b []byte) error {
+	var in struct {
+		CPU    *Quantity `json:"cpu"`
+		Memory *Quantity `json:"memory"`
+	}
+	if err := json.Unmarshal(b, &in); err != nil {
+		return err
+	}
+	*r = ResourceQuantities{}
+	if in.CPU != nil {
+		r.CPU = *in.CPU
+	}
+	if in.Memory != nil {
+		r.Memory = *in.Memory
+	}
+	return nil
+}
+
+// ParsePrerequisites parses the CPU and memory of the prerequisites. The values that are not set are zero.
+func (c *Config) ParsePrerequisites() (ResourceQuantities, error) {
+	var out ResourceQuantities
+	for _, p := range []struct {
+		name  string
+		value string
+		q     *Quantity
+	}{
+		{"cpu", c.Prerequisites.CPU, &out.CPU},
+		{"memory", c.Prerequisites.Memory, &out.Memory},
+	} {
+		if p.value == "" {
+			continue
+		}
+		q, err := ParseQuantity(p.value)
+		if err != nil {
+			return ResourceQuantities{}, fmt.Errorf("could not parse prerequisites.%s: %v", p.name, err)
+		}
+		if q.IsZero() {
+			return ResourceQuantities{}, fmt.Errorf("prerequisites.%s must be greater than 0", p.name)
+		}
+		*p.q = q
+	}
+	return out, nil
+}
+
+// WithPrerequisitesPolicy sets what Run does when the prerequisites of the service exceed the cgroup limits.
+// Default is PrerequisitesWarn.
+func WithPrerequisitesPolicy(p PrerequisitesPolicy) RunOption {
+	return func(o *runOptions) {
+		o.prerequisites = p
+	}
+}
+
+// checkPrerequisites returns an error when the prerequisites exceed the limits. Zero limits are unlimited.
+func checkPrerequisites(prerequisites, limits ResourceQuantities) error {
+	var errs []string
+	if !limits.CPU.IsZero() && prerequisites.CPU.MilliValue() > limits.CPU.MilliValue() {
+		errs = append(errs, fmt.Sprintf("cpu %s exceeds the limit of %s", prerequisites.CPU, limits.CPU))
+	}
+	if !limits.Memory.IsZero() && prerequisites.Memory.MilliValue() > limits.Memory.MilliValue() {
+		errs = append(errs, fmt.Sprintf("memory %s exceeds the limit of %s", prerequisites.Memory, limits.Memory))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("prerequisites not met: %s", strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+// checkSystemPrerequisites compares the prerequisites of the config with the limits of the cgroup of the process.
+func checkSystemPrerequisites(config Config) error {
+	prerequisites, err := config.ParsePrerequisites()
+	if err != nil {
+		return err
+	}
+	stats, ok := readCgroup(cgroupRoot)
+	if !ok {
+		return nil
+	}
+	return checkPrerequisites(prerequisites, stats.limits)
+}
+
+// resourceUsage returns the resource usage of the process.
+func resourceUsage(config Config) ResourceUsage {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	usage := ResourceUsage{
+		NumCPU:     runtime.NumCPU(),
+		GoMaxProcs: runtime.GOMAXPROCS(0),
+		Goroutines: runtime.NumGoroutine(),
+		HeapAlloc:  mem.HeapAlloc,
+		Sys:        mem.Sys,
+		NumGC:      mem.NumGC,
+	}
+	usage.Prerequisites, _ = config.ParsePrerequisites()
+	if stats, ok := readCgroup(cgroupRoot); ok {
+		usage.Limits = stats.limits
+		usage.MemoryUsage = stats.memoryUsage
+		usage.CPUTime = stats.cpuTime.Milliseconds()
+	}
+	return usage
+}
+
+// readCgroup reads the limits and usage of the cgroup mounted at root, version 2 or version 1. It returns false
+// when root is empty or holds no cgroup.
+func readCgroup(root string) (cgroupStats, bool) {
+	if root == "" {
+		return cgroupStats{}, false
+	}
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err == nil {
+		return readCgroupV2(root), true
+	}
+	stats, found := readCgroupV1(root)
+	return stats, found
+}
+
+func readCgroupV2(root string) cgroupStats {
+	var stats cgroupStats
+	if fields := strings.Fields(readCgroupFile(root, "cpu.max")); len(fields) == 2 && fields[0] != "max" {
+		stats.limits.CPU = cpuQuota(fields[0], fields[1])
+	}
+	if n, err := strconv.ParseInt(readCgroupFile(root, "memory.max"), 10, 64); err == nil {
+		stats.limits.Memory = Quantity{milli: n * 1000}
+	}
+	stats.memoryUsage, _ = strconv.ParseUint(readCgroupFile(root, "memory.current"), 10, 64)
+	for _, line := range strings.Split(readCgroupFile(root, "cpu.stat"), "\n") {
+		if fields := strings.Fields(line); len(fields) == 2 && fields[0] == "usage_usec" {
+			usec, _ := strconv.ParseInt(fields[1], 10, 64)
+			stats.cpuTime = time.Duration(usec) * time.Microsecond
+		}
+	}
+	return stats
+}
+
+func readCgroupV1(root string) (cgroupStats, bool) {
+	var stats cgroupStats
+	found := false
+	quota, period := readCgroupFile(root, "cpu/cpu.cfs_quota_us"), readCgroupFile(root, "cpu/cpu.cfs_period_us")
+	if quota != "" || period != "" {
+		found = true
+		if !strings.HasPrefix(quota, "-") {
+			stats.limits.CPU = cpuQuota(quota, period)
+		}
+	}
+	if limit := readCgroupFile(root, "memory/memory.limit_in_bytes"); limit != "" {
+		found = true
+		if n, err := strconv.ParseInt(limit, 10, 64); err == nil && n < cgroupUnlimited {
+			stats.limits.Memory = Quantity{milli: n * 1000}
+		}
+	}
+	stats.memoryUsage, _ = strconv.ParseUint(readCgroupFile(root, "memory/memory.usage_in_bytes"), 10, 64)
+	if ns, err := strconv.ParseInt(readCgroupFile(root, "cpuacct/cpuacct.usage"), 10, 64); err == nil {
+		stats.cpuTime = time.Duration(ns)
+	}
+	return stats, found
+}
+
+// cpuQuota returns the CPU of a cgroup quota and period in microseconds, zero when they are invalid.
+func cpuQuota(quota, period string) Quantity {
+	q, err := strconv.ParseInt(quota, 10, 64)
+	if err != nil || q <= 0 {
+		return Quantity{}
+	}
+	p, err := strconv.ParseInt(period, 10, 64)
+	if err != nil || p <= 0 {
+		return Quantity{}
+	}
+	return Quantity{milli: q * 1000 / p}
+}
+
+// readCgroupFile returns the trimmed content of a cgroup file, empty when it cannot be read.
+func readCgroupFile(root, name string) string {
+	b, err := os.ReadFile(filepath.Join(root, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
@@ -0,0 +1,4 @@
+package soajsgo
+
+// cgroupRoot is where the cgroup of the process is mounted, read for the limits and usage of the service.
+var cgroupRoot = "/sys/fs/cgroup"
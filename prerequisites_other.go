@@ -0,0 +1,6 @@
+//go:build !linux
+
+package soajsgo
+
+// cgroupRoot is empty where there are no cgroups, so the limits and usage of the service are unknown.
+var cgroupRoot = ""
@@ -0,0 +1,284 @@
+package soajsgo
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseQuantity(t *testing.T) {
+	tt := []struct {
+		in            string
+		expectedMilli int64
+		expectedErr   string
+	}{
+		{in: "500m", expectedMilli: 500},
+		{in: "2", expectedMilli: 2000},
+		{in: "0.5", expectedMilli: 500},
+		{in: ".25", expectedMilli: 250},
+		{in: "1.5k", expectedMilli: 1500000},
+		{in: "256Mi", expectedMilli: 256 << 20 * 1000},
+		{in: "1Gi", expectedMilli: 1 << 30 * 1000},
+		{in: "128M", expectedMilli: 128e6 * 1000},
+		{in: "1e3", expectedMilli: 1000000},
+		{in: "1E", expectedErr: `quantity "1E" is too large`},
+		{in: "5e-4", expectedMilli: 1},
+		{in: "100u", expectedMilli: 1},
+		{in: "1n", expectedMilli: 1},
+		{in: "0", expectedMilli: 0},
+		{in: "", expectedErr: `invalid quantity ""`},
+		{in: "-1", expectedErr: `invalid quantity "-1"`},
+		{in: "256MB", expectedErr: `invalid quantity "256MB"`},
+		{in: "1e99", expectedErr: `invalid quantity "1e99"`},
+		{in: "9Ei", expectedErr: `quantity "9Ei" is too large`},
+	}
+	for _, tc := range tt {
+		t.Run(tc.in, func(t *testing.T) {
+			q, err := ParseQuantity(tc.in)
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedMilli, q.MilliValue())
+		})
+	}
+}
+
+func TestQuantity(t *testing.T) {
+	tt := []struct {
+		in            string
+		expectedValue int64
+		expectedStr   string
+	}{
+		{in: "500m", expectedValue: 1, expectedStr: "500m"},
+		{in: "1500m", expectedValue: 2, expectedStr: "1500m"},
+		{in: "2", expectedValue: 2, expectedStr: "2"},
+		{in: "256Mi", expectedValue: 256 << 20, expectedStr: "256Mi"},
+		{in: "1024Mi", expectedValue: 1 << 30, expectedStr: "1Gi"},
+		{in: "1G", expectedValue: 1e9, expectedStr: "1000000000"},
+		{in: "0", expectedValue: 0, expectedStr: "0"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.in, func(t *testing.T) {
+			q, err := ParseQuantity(tc.in)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedValue, q.Value())
+			assert.Equal(t, tc.expectedStr, q.String())
+
+			b, err := json.Marshal(q)
+			require.NoError(t, err)
+			var decoded Quantity
+			require.NoError(t, json.Unmarshal(b, &decoded))
+			assert.Equal(t, q, decoded)
+		})
+	}
+}
+
+func TestResourceQuantities_JSON(t *testing.T) {
+	r := ResourceQuantities{CPU: Quantity{milli: 250}}
+	b, err := json.Marshal(r)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"cpu":"250m"}`, string(b))
+
+	var decoded ResourceQuantities
+	require.NoError(t, json.Unmarshal([]byte(`{"cpu":"250m","memory":"64Mi"}`), &decoded))
+	assert.Equal(t, ResourceQuantities{CPU: Quantity{milli: 250}, Memory: Quantity{milli: 64 << 20 * 1000}}, decoded)
+	assert.ErrorContains(t, json.Unmarshal([]byte(`{"cpu":"lots"}`), &decoded), `invalid quantity "lots"`)
+}
+
+func TestConfig_ParsePrerequisites(t *testing.T) {
+	tt := []struct {
+		name        string
+		prereq      prerequisites
+		expected    ResourceQuantities
+		expectedErr string
+	}{
+		{name: "not set"},
+		{name: "cpu and memory", prereq: prerequisites{CPU: "500m", Memory: "256Mi"},
+			expected: ResourceQuantities{CPU: Quantity{milli: 500}, Memory: Quantity{milli: 256 << 20 * 1000}}},
+		{name: "cpu only", prereq: prerequisites{CPU: "1"}, expected: ResourceQuantities{CPU: Quantity{milli: 1000}}},
+		{name: "bad cpu", prereq: prerequisites{CPU: "one"}, expectedErr: `could not parse prerequisites.cpu: invalid quantity "one"`},
+		{name: "zero memory", prereq: prerequisites{Memory: "0Mi"}, expectedErr: "prerequisites.memory must be greater than 0"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			c := Config{Prerequisites: tc.prereq}
+			r, err := c.ParsePrerequisites()
+			if tc.expectedErr != "" {
+				assert.EqualError(t, err, tc.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, r)
+		})
+	}
+}
+
+func TestCheckPrerequisites(t *testing.T) {
+	cpu := func(milli int64) Quantity { return Quantity{milli: milli} }
+	mem := func(bytes int64) Quantity { return Quantity{milli: bytes * 1000} }
+	tt := []struct {
+		name        string
+		prereq      ResourceQuantities
+		limits      ResourceQuantities
+		expectedErr string
+	}{
+		{name: "no limits", prereq: ResourceQuantities{CPU: cpu(4000), Memory: mem(1 << 30)}},
+		{name: "within limits", prereq: ResourceQuantities{CPU: cpu(500), Memory: mem(256 << 20)},
+			limits: ResourceQuantities{CPU: cpu(500), Memory: mem(512 << 20)}},
+		{name: "no prerequisites", limits: ResourceQuantities{CPU: cpu(500), Memory: mem(512 << 20)}},
+		{name: "cpu exceeded", prereq: ResourceQuantities{CPU: cpu(2000)}, limits: ResourceQuantities{CPU: cpu(1500)},
+			expectedErr: "prerequisites not met: cpu 2 exceeds the limit of 1500m"},
+		{name: "both exceeded", prereq: ResourceQuantities{CPU: cpu(2000), Memory: mem(1 << 30)},
+			limits:      ResourceQuantities{CPU: cpu(1000), Memory: mem(512 << 20)},
+			expectedErr: "prerequisites not met: cpu 2 exceeds the limit of 1, memory 1Gi exceeds the limit of 512Mi"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			err := checkPrerequisites(tc.prereq, tc.limits)
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+			assert.EqualError(t, err, tc.expectedErr)
+		})
+	}
+}
+
+func TestRunOptions_checkPrerequisites(t *testing.T) {
+	defer func(old string) { cgroupRoot = old }(cgroupRoot)
+	cgroupRoot = writeCgroup(t, map[string]string{"cgroup.controllers": "", "cpu.max": "50000 100000"})
+	exceeding := Config{Prerequisites: prerequisites{CPU: "1"}}
+	tt := []struct {
+		name            string
+		policy          PrerequisitesPolicy
+		config          Config
+		expectedWarning string
+		expectedErr     string
+	}{
+		{name: "warn", policy: PrerequisitesWarn, config: exceeding, expectedWarning: "prerequisites not met: cpu 1 exceeds the limit of 500m"},
+		{name: "fail", policy: PrerequisitesFail, config: exceeding, expectedErr: "prerequisites not met: cpu 1 exceeds the limit of 500m"},
+		{name: "ignore", policy: PrerequisitesIgnore, config: exceeding},
+		{name: "met", policy: PrerequisitesFail, config: Config{Prerequisites: prerequisites{CPU: "500m"}}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var o runOptions
+			WithPrerequisitesPolicy(tc.policy)(&o)
+			warning, err := o.checkPrerequisites(tc.config)
+			if tc.expectedWarning == "" {
+				assert.NoError(t, warning)
+			} else {
+				assert.EqualError(t, warning, tc.expectedWarning)
+			}
+			if tc.expectedErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expectedErr)
+			}
+		})
+	}
+}
+
+// writeCgroup writes the cgroup files under a temporary root and returns it.
+func writeCgroup(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+	return root
+}
+
+func TestReadCgroup(t *testing.T) {
+	tt := []struct {
+		name          string
+		files         map[string]string
+		expected      cgroupStats
+		expectedFound bool
+	}{
+		{
+			name: "v2 limited",
+			files: map[string]string{
+				"cgroup.controllers": "cpu memory",
+				"cpu.max":            "150000 100000\n",
+				"memory.max":         "536870912\n",
+				"memory.current":     "1048576\n",
+				"cpu.stat":           "usage_usec 2500000\nuser_usec 2000000\n",
+			},
+			expected: cgroupStats{
+				limits:      ResourceQuantities{CPU: Quantity{milli: 1500}, Memory: Quantity{milli: 512 << 20 * 1000}},
+				memoryUsage: 1 << 20,
+				cpuTime:     2500 * time.Millisecond,
+			},
+			expectedFound: true,
+		},
+		{
+			name:          "v2 unlimited",
+			files:         map[string]string{"cgroup.controllers": "", "cpu.max": "max 100000", "memory.max": "max"},
+			expectedFound: true,
+		},
+		{
+			name: "v1 limited",
+			files: map[string]string{
+				"cpu/cpu.cfs_quota_us":         "50000",
+				"cpu/cpu.cfs_period_us":        "100000",
+				"memory/memory.limit_in_bytes": "268435456",
+				"memory/memory.usage_in_bytes": "4096",
+				"cpuacct/cpuacct.usage":        "3000000000",
+			},
+			expected: cgroupStats{
+				limits:      ResourceQuantities{CPU: Quantity{milli: 500}, Memory: Quantity{milli: 256 << 20 * 1000}},
+				memoryUsage: 4096,
+				cpuTime:     3 * time.Second,
+			},
+			expectedFound: true,
+		},
+		{
+			name: "v1 unlimited",
+			files: map[string]string{
+				"cpu/cpu.cfs_quota_us":         "-1",
+				"cpu/cpu.cfs_period_us":        "100000",
+				"memory/memory.limit_in_bytes": "9223372036854771712",
+			},
+			expectedFound: true,
+		},
+		{name: "no cgroup", files: map[string]string{"other": ""}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			stats, found := readCgroup(writeCgroup(t, tc.files))
+			assert.Equal(t, tc.expectedFound, found)
+			assert.Equal(t, tc.expected, stats)
+		})
+	}
+
+	_, found := readCgroup("")
+	assert.False(t, found)
+}
+
+func TestResourceUsage(t *testing.T) {
+	root := writeCgroup(t, map[string]string{
+		"cgroup.controllers": "",
+		"cpu.max":            "100000 100000",
+		"memory.current":     "2048",
+	})
+	defer func(old string) { cgroupRoot = old }(cgroupRoot)
+	cgroupRoot = root
+
+	usage := resourceUsage(Config{Prerequisites: prerequisites{CPU: "250m"}})
+	assert.Equal(t, ResourceQuantities{CPU: Quantity{milli: 250}}, usage.Prerequisites)
+	assert.Equal(t, ResourceQuantities{CPU: Quantity{milli: 1000}}, usage.Limits)
+	assert.Equal(t, uint64(2048), usage.MemoryUsage)
+	assert.Positive(t, usage.NumCPU)
+	assert.Positive(t, usage.Goroutines)
+	assert.Positive(t, usage.HeapAlloc)
+}
@@ -0,0 +1,366 @@
+package soajsgo
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strings"
+)
+
+// redacted replaces sensitive values when marshalling or logging.
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are the lower cased fragments of free form keys whose values get redacted.
+var sensitiveKeys = []string{"password", "secret", "token", "credential", "apikey", "api_key", "privatekey", "private_key"}
+
+type (
+	// types without methods, used to marshal values in clear text.
+	plainCredentials Credentials
+	plainServiceKey  ServiceKey
+	plainKey         Key
+	databaseAlias    Database
+	serviceConfAlias ServiceConfig
+	tenantAlias      Tenant
+	uracAlias        Urac
+	headerInfoAlias  headerInfo
+	contextDataAlias ContextData
+
+	plainDatabase struct {
+		databaseAlias
+		Credentials plainCredentials `json:"credentials"`
+	}
+	plainServiceConfig struct {
+		serviceConfAlias
+		Key plainServiceKey `json:"key"`
+	}
+	plainTenant struct {
+		tenantAlias
+		Key plainKey `json:"key"`
+	}
+	plainUrac struct {
+		uracAlias
+		Tenant plainTenant `json:"tenant"`
+	}
+
+	// registryJSON mirrors Registry without its lock.
+	registryJSON struct {
+		TimeLoaded    int64       `json:"timeLoaded"`
+		Name          string      `json:"name"`
+		Environment   string      `json:"environment"`
+		ServiceType   string      `json:"ServiceType"`
+		CoreDBs       interface{} `json:"coreDB"`
+		TenantMetaDBs interface{} `json:"tenantMetaDB"`
+		ServiceConfig interface{} `json:"serviceConfig"`
+		Custom        interface{} `json:"custom"`
+		Resources     interface{} `json:"resources"`
+		Services      interface{} `json:"services"`
+	}
+
+	unredactedRegistry    struct{ reg *Registry }
+	unredactedContextData struct{ c ContextData }
+)
+
+// MarshalJSON marshals the credentials with the password redacted.
+func (c Credentials) MarshalJSON() ([]byte, error) {
+	c.Password = redactString(c.Password)
+	return json.Marshal(plainCredentials(c))
+}
+
+// String returns the credentials as JSON with the password redacted.
+func (c Credentials) String() string {
+	return marshalString(c)
+}
+
+// LogValue implements slog.LogValuer.
+func (c Credentials) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("username", c.Username),
+		slog.String("password", redactString(c.Password)),
+	)
+}
+
+// MarshalJSON marshals the service key with the password redacted.
+func (k ServiceKey) MarshalJSON() ([]byte, error) {
+	k.Password = redactString(k.Password)
+	return json.Marshal(plainServiceKey(k))
+}
+
+// String returns the service key as JSON with the password redacted.
+func (k ServiceKey) String() string {
+	return marshalString(k)
+}
+
+// LogValue implements slog.LogValuer.
+func (k ServiceKey) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("algorithm", k.Algorithm),
+		slog.String("password", redactString(k.Password)),
+	)
+}
+
+// MarshalJSON marshals the key with the internal and external keys and sensitive config entries redacted.
+func (k Key) MarshalJSON() ([]byte, error) {
+	k.IKey = redactString(k.IKey)
+	k.EKey = redactString(k.EKey)
+	k.Config = redactMap(k.Config)
+	return json.Marshal(plainKey(k))
+}
+
+// String returns the key as JSON with secrets redacted.
+func (k Key) String() string {
+	return marshalString(k)
+}
+
+// LogValue implements slog.LogValuer.
+func (k Key) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("iKey", redactString(k.IKey)),
+		slog.String("eKey", redactString(k.EKey)),
+	)
+}
+
+// MarshalJSON marshals the database with the password of its credentials and the sensitive entries of its free
+// form values, e.g. a password in extraParam, redacted.
+func (db Database) MarshalJSON() ([]byte, error) {
+	db.Streaming = redactValue(db.Streaming)
+	db.URLParam = redactValue(db.URLParam)
+	db.ExtraParam = redactValue(db.ExtraParam)
+	db.Store = redactValue(db.Store)
+	return json.Marshal(databaseAlias(db))
+}
+
+// MarshalJSON marshals the urac with its tenant keys and the sensitive entries of its social login redacted.
+func (u Urac) MarshalJSON() ([]byte, error) {
+	u.SocialLogin = redactValue(u.SocialLogin)
+	return json.Marshal(uracAlias(u))
+}
+
+// MarshalJSON marshals the registry with database credentials and sensitive database entries, the service key,
+// cookie and session secrets and sensitive custom registry and resource entries redacted.
+func (reg *Registry) MarshalJSON() ([]byte, error) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	conf := reg.ServiceConfig
+	conf.Cookie.Secret = redactString(conf.Cookie.Secret)
+	conf.Session.Secret = redactString(conf.Session.Secret)
+	var custom CustomRegistries
+	if reg.Custom != nil {
+		custom = make(CustomRegistries, len(reg.Custom))
+		for name, c := range reg.Custom {
+			c.Value = redactValue(c.Value)
+			custom[name] = c
+		}
+	}
+	var resources Resources
+	if reg.Resources != nil {
+		resources = make(Resources, len(reg.Resources))
+		for category, list := range reg.Resources {
+			resources[category] = make(map[string]Resource, len(list))
+			for name, r := range list {
+				r.Config = redactValue(r.Config)
+				resources[category][name] = r
+			}
+		}
+	}
+	return json.Marshal(registryJSON{
+		TimeLoaded:    reg.TimeLoaded,
+		Name:          reg.Name,
+		Environment:   reg.Environment,
+		ServiceType:   reg.ServiceType,
+		CoreDBs:       reg.CoreDBs,
+		TenantMetaDBs: reg.TenantMetaDBs,
+		ServiceConfig: conf,
+		Custom:        custom,
+		Resources:     resources,
+		Services:      reg.Services,
+	})
+}
+
+// String returns the registry as JSON with secrets redacted.
+func (reg *Registry) String() string {
+	return marshalString(reg)
+}
+
+// LogValue implements slog.LogValuer. Only non sensitive identification data is logged.
+func (reg *Registry) LogValue() slog.Value {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return slog.GroupValue(
+		slog.String("name", reg.Name),
+		slog.String("environment", reg.Environment),
+		slog.String("serviceType", reg.ServiceType),
+		slog.Int64("timeLoaded", reg.TimeLoaded),
+	)
+}
+
+// Unredacted returns a view of the registry that marshals with all secrets in clear text.
+// Use it only when the output never leaves the service, never for logging.
+func (reg *Registry) Unredacted() json.Marshaler {
+	return unredactedRegistry{reg: reg}
+}
+
+// MarshalJSON marshals the registry with all secrets in clear text.
+func (u unredactedRegistry) MarshalJSON() ([]byte, error) {
+	reg := u.reg
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	conf := plainServiceConfig{
+		serviceConfAlias: serviceConfAlias(reg.ServiceConfig),
+		Key:              plainServiceKey(reg.ServiceConfig.Key),
+	}
+	return json.Marshal(registryJSON{
+		TimeLoaded:    reg.TimeLoaded,
+		Name:          reg.Name,
+		Environment:   reg.Environment,
+		ServiceType:   reg.ServiceType,
+		CoreDBs:       plainDatabases(reg.CoreDBs),
+		TenantMetaDBs: plainDatabases(reg.TenantMetaDBs),
+		ServiceConfig: conf,
+		Custom:        reg.Custom,
+		Resources:     reg.Resources,
+		Services:      reg.Services,
+	})
+}
+
+// MarshalJSON marshals the context data with tenant keys, urac tenant keys, sensitive services config entries
+// and the registry secrets redacted.
+func (c ContextData) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		contextDataAlias
+		ServicesConfig map[string]interface{} `json:"servicesConfig"`
+	}{
+		contextDataAlias: contextDataAlias(c),
+		ServicesConfig:   redactMap(c.ServicesConfig),
+	})
+}
+
+// String returns the context data as JSON with secrets redacted.
+func (c ContextData) String() string {
+	return marshalString(c)
+}
+
+// LogValue implements slog.LogValuer. Only non sensitive identification data is logged.
+func (c ContextData) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("tenantId", c.Tenant.ID),
+		slog.String("tenantCode", c.Tenant.Code),
+		slog.String("appId", c.Tenant.Application.AppID),
+		slog.String("uracId", c.Urac.ID),
+		slog.String("device", c.Device),
+		slog.String("requestId", c.RequestID),
+	)
+}
+
+// Unredacted returns a view of the context data that marshals with all secrets in clear text.
+// Use it only when the output never leaves the service, never for logging.
+func (c ContextData) Unredacted() json.Marshaler {
+	return unredactedContextData{c: c}
+}
+
+// MarshalJSON marshals the context data with all secrets in clear text.
+func (u unredactedContextData) MarshalJSON() ([]byte, error) {
+	var reg json.Marshaler
+	if u.c.Reg != nil {
+		reg = u.c.Reg.Unredacted()
+	}
+	return json.Marshal(struct {
+		contextDataAlias
+		Tenant plainTenant    `json:"tenant"`
+		Urac   plainUrac      `json:"urac"`
+		Reg    json.Marshaler `json:"reg"`
+	}{
+		contextDataAlias: contextDataAlias(u.c),
+		Tenant:           newPlainTenant(u.c.Tenant),
+		Urac:             newPlainUrac(u.c.Urac),
+		Reg:              reg,
+	})
+}
+
+// MarshalJSON marshals the injected object in clear text, since it is the wire format forwarded between services.
+func (h headerInfo) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		headerInfoAlias
+		Tenant plainTenant `json:"tenant"`
+		Key    plainKey    `json:"key"`
+		Urac   plainUrac   `json:"urac"`
+	}{
+		headerInfoAlias: headerInfoAlias(h),
+		Tenant:          newPlainTenant(h.Tenant),
+		Key:             plainKey(h.Key),
+		Urac:            newPlainUrac(h.Urac),
+	})
+}
+
+func newPlainTenant(t Tenant) plainTenant {
+	return plainTenant{tenantAlias: tenantAlias(t), Key: plainKey(t.Key)}
+}
+
+func newPlainUrac(u Urac) plainUrac {
+	return plainUrac{uracAlias: uracAlias(u), Tenant: newPlainTenant(u.Tenant)}
+}
+
+func plainDatabases(dbs map[string]Database) map[string]plainDatabase {
+	if dbs == nil {
+		return nil
+	}
+	out := make(map[string]plainDatabase, len(dbs))
+	for name, db := range dbs {
+		out[name] = plainDatabase{databaseAlias: databaseAlias(db), Credentials: plainCredentials(db.Credentials)}
+	}
+	return out
+}
+
+func marshalString(v json.Marshaler) string {
+	b, err := v.MarshalJSON()
+	if err != nil {
+		return redacted
+	}
+	return string(b)
+}
+
+func redactString(s string) string {
+	if s == "" {
+		return ""
+	}
+	return redacted
+}
+
+func isSensitiveKey(k string) bool {
+	k = strings.ToLower(k)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(k, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactValue returns a copy of a free form value with the entries under sensitive keys redacted.
+func redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return redactMap(val)
+	case []interface{}:
+		out := make([]interface{}, len(val))
+		for i := range val {
+			out[i] = redactValue(val[i])
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func redactMap(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if v != nil && isSensitiveKey(k) {
+			out[k] = redacted
+			continue
+		}
+		out[k] = redactValue(v)
+	}
+	return out
+}
@@ -0,0 +1,161 @@
+package soajsgo
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedaction_MarshalJSON(t *testing.T) {
+	reg := &Registry{
+		Name: "test",
+		CoreDBs: map[string]Database{"main": {
+			Credentials: Credentials{Username: "admin", Password: "db-pass"},
+			ExtraParam:  map[string]interface{}{"authSource": "admin", "auth": map[string]interface{}{"user": "admin", "password": "extra-pass"}},
+		}},
+		ServiceConfig: ServiceConfig{
+			Key:     ServiceKey{Algorithm: "aes256", Password: "key-pass"},
+			Cookie:  Cookie{Secret: "cookie-secret"},
+			Session: Session{Name: "soajsID", Secret: "session-secret"},
+		},
+		Custom: CustomRegistries{"api": {
+			Value: map[string]interface{}{"url": "http://api", "apiKey": "custom-key"},
+		}},
+		Resources: Resources{"cache": {"redis": {
+			Config: map[string]interface{}{"auth": map[string]interface{}{"password": "redis-pass"}},
+		}}},
+	}
+	c := ContextData{
+		Tenant: Tenant{Code: "TNT", Key: Key{IKey: "ikey-value", EKey: "ekey-value"}},
+		Urac: Urac{ID: "1", Tenant: Tenant{Key: Key{IKey: "urac-ikey"}},
+			SocialLogin: map[string]interface{}{"strategy": "facebook", "accessToken": "social-token"}},
+		ServicesConfig: map[string]interface{}{
+			"mail": map[string]interface{}{"token": "mail-token", "from": "me"},
+		},
+		Reg: reg,
+	}
+
+	tt := []struct {
+		name     string
+		value    interface{}
+		secrets  []string
+		expected []string
+	}{
+		{
+			name:     "credentials",
+			value:    Credentials{Username: "admin", Password: "db-pass"},
+			secrets:  []string{"db-pass"},
+			expected: []string{`"username":"admin"`, `"password":"[REDACTED]"`},
+		},
+		{
+			name:     "service key",
+			value:    ServiceKey{Algorithm: "aes256", Password: "key-pass"},
+			secrets:  []string{"key-pass"},
+			expected: []string{`"algorithm":"aes256"`, `"password":"[REDACTED]"`},
+		},
+		{
+			name:     "key",
+			value:    Key{IKey: "ikey-value", EKey: "ekey-value"},
+			secrets:  []string{"ikey-value", "ekey-value"},
+			expected: []string{`"iKey":"[REDACTED]"`, `"eKey":"[REDACTED]"`},
+		},
+		{
+			name: "database",
+			value: Database{
+				Name:       "main",
+				Streaming:  map[string]interface{}{"secret": "stream-secret"},
+				URLParam:   map[string]interface{}{"password": "url-pass", "maxPoolSize": 5.0},
+				ExtraParam: map[string]interface{}{"auth": map[string]interface{}{"user": "admin", "password": "extra-pass"}},
+				Store:      map[string]interface{}{"clientSecret": "store-secret"},
+			},
+			secrets:  []string{"stream-secret", "url-pass", "extra-pass", "store-secret"},
+			expected: []string{`"name":"main"`, `"user":"admin"`, `"maxPoolSize":5`},
+		},
+		{
+			name:     "urac",
+			value:    c.Urac,
+			secrets:  []string{"urac-ikey", "social-token"},
+			expected: []string{`"strategy":"facebook"`, `"accessToken":"[REDACTED]"`},
+		},
+		{
+			name:     "registry",
+			value:    reg,
+			secrets:  []string{"db-pass", "extra-pass", "key-pass", "cookie-secret", "session-secret", "custom-key", "redis-pass"},
+			expected: []string{`"name":"test"`, `"algorithm":"aes256"`, `"url":"http://api"`},
+		},
+		{
+			name:     "context data",
+			value:    c,
+			secrets:  []string{"ikey-value", "ekey-value", "urac-ikey", "social-token", "mail-token", "db-pass", "extra-pass"},
+			expected: []string{`"code":"TNT"`, `"from":"me"`, `"name":"test"`},
+		},
+		{
+			name:     "unredacted registry",
+			value:    reg.Unredacted(),
+			expected: []string{`"password":"db-pass"`, `"password":"extra-pass"`, `"password":"key-pass"`, `"secret":"cookie-secret"`, `"apiKey":"custom-key"`},
+		},
+		{
+			name:     "unredacted context data",
+			value:    c.Unredacted(),
+			expected: []string{`"iKey":"ikey-value"`, `"iKey":"urac-ikey"`, `"accessToken":"social-token"`, `"token":"mail-token"`, `"password":"db-pass"`},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			b, err := json.Marshal(tc.value)
+			require.NoError(t, err)
+			for _, s := range tc.secrets {
+				assert.NotContains(t, string(b), s)
+			}
+			for _, s := range tc.expected {
+				assert.Contains(t, string(b), s)
+			}
+			if s, ok := tc.value.(interface{ String() string }); ok {
+				assert.Equal(t, string(b), s.String())
+			}
+		})
+	}
+
+	// unmarshalling the unredacted view gives back the original data
+	b, err := json.Marshal(reg.Unredacted())
+	require.NoError(t, err)
+	var decoded Registry
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	assert.Equal(t, reg.CoreDBs, decoded.CoreDBs)
+	assert.Equal(t, reg.ServiceConfig, decoded.ServiceConfig)
+}
+
+func TestRedaction_LogValue(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	logger.Info("test",
+		"credentials", Credentials{Username: "admin", Password: "db-pass"},
+		"serviceKey", ServiceKey{Password: "key-pass"},
+		"key", Key{EKey: "ekey-value"},
+		"registry", &Registry{Name: "test", ServiceConfig: ServiceConfig{Cookie: Cookie{Secret: "cookie-secret"}}},
+		"soajs", ContextData{Tenant: Tenant{Code: "TNT", Key: Key{IKey: "ikey-value"}}},
+	)
+	out := buf.String()
+	for _, s := range []string{"db-pass", "key-pass", "ekey-value", "cookie-secret", "ikey-value"} {
+		assert.NotContains(t, out, s)
+	}
+	assert.Contains(t, out, `"username":"admin"`)
+	assert.Contains(t, out, `"tenantCode":"TNT"`)
+}
+
+func TestConnect_HeadersNotRedacted(t *testing.T) {
+	c := ContextData{
+		Tenant: Tenant{Key: Key{IKey: "ikey-value", EKey: "ekey-value"}},
+		Awareness: Host{InterConnect: headerInterconnect{
+			{Name: "svc", Version: "1", Latest: "1", Host: "svc", Port: 4000},
+		}},
+	}
+	b, err := json.Marshal(c.Connect("svc"))
+	require.NoError(t, err)
+	assert.Contains(t, string(b), `"iKey":"ikey-value"`)
+	assert.Contains(t, string(b), `"eKey":"ekey-value"`)
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
 	"strconv"
@@ -17,38 +18,152 @@ var httpClient = &http.Client{
 	Timeout: 30 * time.Second,
 }
 
+// errNotModified reports a registry unchanged since the last fetch.
+var errNotModified = errors.New("registry not modified")
+
+type (
+	// RegistryOption configures optional behaviour of a registry created by New or NewFromConfig.
+	RegistryOption func(*Registry)
+
+	// ReloadStats counts the reloads of a registry. Unchanged reloads are the successful ones the controller
+	// answered with not modified, keeping the loaded registry.
+	ReloadStats struct {
+		Reloads    uint64    `json:"reloads"`
+		Unchanged  uint64    `json:"unchanged"`
+		Failures   uint64    `json:"failures"`
+		LastReload time.Time `json:"lastReload"`
+	}
+)
+
+// WithHTTPClient sets the client calling the controller, e.g. to share a transport between registries. The
+// default client times out after 30 seconds.
+func WithHTTPClient(c *http.Client) RegistryOption {
+	return func(reg *Registry) {
+		reg.client = c
+	}
+}
+
+// WithControllerAddress sets the hostname:port address of the controller, SOAJS_REGISTRY_API by default.
+func WithControllerAddress(addr string) RegistryOption {
+	return func(reg *Registry) {
+		reg.controllerAddr = addr
+	}
+}
+
 // New creates and initializes new registry by service name and code.
 // This function starts registry auto reload every AutoReloadRegistry if turnOnAutoReload set as true. You can break
-// this process using context.
+// this process using context, which also cancels the initial fetch and the in-flight reloads.
+func New(ctx context.Context, serviceName, envCode, serviceType string, turnOnAutoReload bool, opts ...RegistryOption) (*Registry, error) {
+	reg := &Registry{
+		Name:        serviceName,
+		Environment: envCode,
+		ServiceType: serviceType,
+		serviceName: serviceName,
+	}
+	for _, opt := range opts {
+		opt(reg)
+	}
+	if err := reg.ReloadContext(ctx); err != nil {
+		return nil, err
+	}
+	if turnOnAutoReload {
+		go reg.autoReload(ctx)
+	}
+	return reg, nil
+}
+
+// fetch downloads the registry of the service from the controller without touching the current one. The request
+// carries the ETag of the loaded registry, if any: fetch returns errNotModified when the controller answers that
+// the registry did not change.
 // nolint: errcheck
-func New(ctx context.Context, serviceName, envCode, serviceType string, turnOnAutoReload bool) (*Registry, error) {
+func (reg *Registry) fetch(ctx context.Context) (_ *Registry, err error) {
+	reg.mu.RLock()
+	serviceName, envCode, serviceType, etag := reg.Name, reg.Environment, reg.ServiceType, reg.etag
+	reg.mu.RUnlock()
 	if serviceName == "" || envCode == "" {
 		return nil, errors.New("service name and env code are required")
 	}
-	addr, err := registryAddress()
+	addr, err := reg.controllerAddress()
 	if err != nil {
 		return nil, fmt.Errorf("could not init registry api path: %v", err)
 	}
-	res, err := httpClient.Get(addr.getRegistry(serviceName, envCode, serviceType))
+	ctx, span := reg.startSpan(ctx, SpanRegistryFetch)
+	defer func() { endSpan(span, err) }()
+	req, err := newControllerRequest(ctx, http.MethodGet, addr.getRegistry(serviceName, envCode, serviceType), nil, span)
+	if err != nil {
+		return nil, fmt.Errorf("could not init registry from api gateway: %v", err)
+	}
+	if etag != "" {
+		req.Header.Set("If-None-Match", etag)
+	}
+	res, err := reg.controllerClient().Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("could not init registry from api gateway: %v", err)
 	}
 	defer res.Body.Close()
-	reg, err := registryResponse(res)
+	if etag != "" && res.StatusCode == http.StatusNotModified {
+		span.SetAttributes(Attribute{AttrHTTPStatus, strconv.Itoa(http.StatusNotModified)})
+		return nil, errNotModified
+	}
+	var raw bytes.Buffer
+	if reg.resolver != nil {
+		res.Body = io.NopCloser(io.TeeReader(res.Body, &raw))
+	}
+	r, err := registryResponse(res)
 	if err != nil {
 		return nil, err
 	}
-	reg.ServiceType = serviceType
-	if turnOnAutoReload {
-		go reg.autoReload(ctx)
+	r.etag = res.Header.Get("ETag")
+	if reg.resolver == nil {
+		return r, nil
 	}
-	return reg, nil
+	r.raw = raw.Bytes()
+	if err := reg.resolver.Resolve(r); err != nil {
+		return nil, fmt.Errorf("could not resolve registry secrets: %v", err)
+	}
+	return r, nil
+}
+
+// resolveLoaded decodes the loaded registry again from its raw response and resolves its secret references, so a
+// rotated file or secret is picked up when the registry did not change. It returns nil without a resolver.
+func (reg *Registry) resolveLoaded() (*Registry, error) {
+	reg.mu.RLock()
+	raw, etag := reg.raw, reg.etag
+	reg.mu.RUnlock()
+	if reg.resolver == nil || raw == nil {
+		return nil, nil
+	}
+	r, err := decodeRegistryResponse(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	r.etag, r.raw = etag, raw
+	if err := reg.resolver.Resolve(r); err != nil {
+		return nil, fmt.Errorf("could not resolve registry secrets: %v", err)
+	}
+	return r, nil
+}
+
+// newControllerRequest creates a request to the controller propagating the trace context of the span.
+func newControllerRequest(ctx context.Context, method, url string, body []byte, span Span) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if traceParent := span.TraceParent(); traceParent != "" {
+		req.Header.Set(HeaderTraceParent, traceParent)
+	}
+	return req, nil
 }
 
 // NewFromConfig creates and initializes new registry by the configuration.
-// This function starts registry auto reload every AutoReloadRegistry. You can break this process using context.
-func NewFromConfig(ctx context.Context, config Config) (*Registry, error) {
-	addr, err := registryAddress()
+// This function starts registry auto reload every AutoReloadRegistry. You can break this process using context,
+// which also cancels the registration of the service.
+func NewFromConfig(ctx context.Context, config Config, opts ...RegistryOption) (*Registry, error) {
+	addr, err := controllerAddress(opts)
 	if err != nil {
 		return nil, fmt.Errorf("could not init registry api path: %v", err)
 	}
@@ -59,104 +174,232 @@ func NewFromConfig(ctx context.Context, config Config) (*Registry, error) {
 	if err := config.Validate(); err != nil {
 		return nil, err
 	}
-	reg, err := New(ctx, config.ServiceName, soajsEnv, config.Type, true)
+	reg, err := New(ctx, config.ServiceName, soajsEnv, config.Type, true, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("could not fetch registry: %v", err)
 	}
-	err = manualDeploy(config, addr)
+	err = reg.manualDeploy(ctx, config, addr)
 	if err != nil {
 		return nil, err
 	}
 	return reg, nil
 }
 
+// manualDeploy registers the service to the controller when it is deployed manually.
+func (reg *Registry) manualDeploy(ctx context.Context, config Config, addr registryPath) error {
+	return reg.callManualDeploy(ctx, config, SpanRegistryRegister, addr.register(), "auto register")
+}
+
+// manualUndeploy unregisters the service from the controller when it is deployed manually.
+func (reg *Registry) manualUndeploy(ctx context.Context, config Config, addr registryPath) error {
+	return reg.callManualDeploy(ctx, config, SpanRegistryUnregister, addr.unregister(), "unregister")
+}
+
 // nolint: errcheck
-func manualDeploy(config Config, addr registryPath) error {
+func (reg *Registry) callManualDeploy(ctx context.Context, config Config, spanName, url, action string) (err error) {
+	manualDeploy, err := deployManual()
+	if err != nil {
+		return err
+	}
+	if !manualDeploy {
+		return nil
+	}
+	d, err := json.Marshal(newRegisterConf(config))
+	if err != nil {
+		return fmt.Errorf("could not marshal manual deploy %s config: %v", action, err)
+	}
+	ctx, span := reg.startSpan(ctx, spanName)
+	defer func() { endSpan(span, err) }()
+	req, err := newControllerRequest(ctx, http.MethodPost, url, d, span)
+	if err != nil {
+		return fmt.Errorf("could not call %s: %v", url, err)
+	}
+	res, err := reg.controllerClient().Do(req)
+	if err != nil {
+		return fmt.Errorf("could not call %s: %v", url, err)
+	}
+	defer res.Body.Close()
+	_, err = registryResponse(res)
+	return err
+}
+
+func deployManual() (bool, error) {
 	manualDeploySrt := os.Getenv(EnvDeployManual)
 	manualDeploy, err := strconv.ParseBool(manualDeploySrt)
 	if err != nil {
-		return fmt.Errorf("could not parse %s environment variable: %v", EnvDeployManual, err)
+		return false, fmt.Errorf("could not parse %s environment variable: %v", EnvDeployManual, err)
 	}
-	if manualDeploy {
-		if config.ServiceIP == "" {
-			config.ServiceIP = "127.0.0.1"
-		}
-		regConf := registerConf{
-			Name:                  config.ServiceName,
-			Group:                 config.ServiceGroup,
-			Port:                  config.ServicePort,
-			IP:                    config.ServiceIP,
-			Type:                  config.Type,
-			Version:               config.ServiceVersion,
-			SubType:               config.SubType,
-			Description:           config.Description,
-			Oauth:                 config.Oauth,
-			Urac:                  config.Urac,
-			UracProfile:           config.UracProfile,
-			UracACL:               config.UracACL,
-			UracConfig:            config.UracConfig,
-			UracGroupConfig:       config.UracGroupConfig,
-			TenantProfile:         config.TenantProfile,
-			ProvisionACL:          config.ProvisionACL,
-			RequestTimeout:        config.RequestTimeout,
-			RequestTimeoutRenewal: config.RequestTimeoutRenewal,
-			Middleware:            true,
-			ExtKeyRequired:        config.ExtKeyRequired,
-			Maintenance:           config.Maintenance,
-			InterConnect:          config.InterConnect,
-		}
-		d, err := json.Marshal(regConf)
-		if err != nil {
-			return fmt.Errorf("could not marshal manual deploy auto register config: %v", err)
-		}
-		res, err := httpClient.Post(addr.register(), "application/json", bytes.NewBuffer(d))
-		if err != nil {
-			return fmt.Errorf("could not call %s: %v", addr.register(), err)
-		}
-		defer res.Body.Close()
-		_, err = registryResponse(res)
-		if err != nil {
-			return err
-		}
+	return manualDeploy, nil
+}
+
+// RegisterPayload returns the JSON payload sent to the /register route of the controller when the service is
+// deployed manually.
+func (c *Config) RegisterPayload() ([]byte, error) {
+	b, err := json.Marshal(newRegisterConf(*c))
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal register payload: %v", err)
+	}
+	return b, nil
+}
+
+func newRegisterConf(config Config) registerConf {
+	if config.ServiceIP == "" {
+		config.ServiceIP = "127.0.0.1"
+	}
+	return registerConf{
+		Name:                  config.ServiceName,
+		Group:                 config.ServiceGroup,
+		Port:                  config.ServicePort,
+		IP:                    config.ServiceIP,
+		Type:                  config.Type,
+		Version:               config.ServiceVersion,
+		SubType:               config.SubType,
+		Description:           config.Description,
+		Oauth:                 config.Oauth,
+		Urac:                  config.Urac,
+		UracProfile:           config.UracProfile,
+		UracACL:               config.UracACL,
+		UracConfig:            config.UracConfig,
+		UracGroupConfig:       config.UracGroupConfig,
+		TenantProfile:         config.TenantProfile,
+		ProvisionACL:          config.ProvisionACL,
+		RequestTimeout:        config.RequestTimeout,
+		RequestTimeoutRenewal: config.RequestTimeoutRenewal,
+		Middleware:            true,
+		ExtKeyRequired:        config.ExtKeyRequired,
+		Maintenance:           config.Maintenance,
+		InterConnect:          config.InterConnect,
+		Prerequisites:         config.Prerequisites,
+		APIList:               config.APIList(),
 	}
-	return nil
 }
 
 // Reload does the same that New does, It reloads registry from soajs.
 func (reg *Registry) Reload() error {
-	r, err := New(context.Background(), reg.Name, reg.Environment, reg.ServiceType, false)
-	if err != nil {
+	return reg.ReloadContext(context.Background())
+}
+
+// ReloadContext reloads the registry from soajs like Reload. The request to the controller is aborted when ctx is
+// done, in which case the loaded registry is kept. When the controller answers that the registry did not change,
+// the loaded registry is kept, only its secret references are resolved again when a resolver is set.
+func (reg *Registry) ReloadContext(ctx context.Context) error {
+	start := time.Now()
+	r, err := reg.fetch(ctx)
+	unchanged := err == errNotModified
+	if unchanged {
+		r, err = reg.resolveLoaded()
+	}
+	reg.metricsOrNop().ObserveReload(reg.env(), time.Since(start), err)
+	reg.mu.Lock()
+	reg.reloadErr = err
+	reg.stats.Reloads++
+	switch {
+	case err != nil:
+		reg.stats.Failures++
+	case unchanged:
+		reg.stats.Unchanged++
+		reg.stats.LastReload = time.Now()
+	default:
+		reg.stats.LastReload = time.Now()
+	}
+	if err == nil && reg.reloadedCh != nil {
+		close(reg.reloadedCh)
+		reg.reloadedCh = nil
+	}
+	reg.mu.Unlock()
+	if err != nil || r == nil {
 		return err
 	}
-	// Thread-safe update of registry data
+	reg.update(r)
+	return nil
+}
+
+// reloaded returns a channel closed by the next successful reload of the registry, changed or not.
+func (reg *Registry) reloaded() <-chan struct{} {
 	reg.mu.Lock()
 	defer reg.mu.Unlock()
+	if reg.reloadedCh == nil {
+		reg.reloadedCh = make(chan struct{})
+	}
+	return reg.reloadedCh
+}
+
+// ReloadStats returns the reload counters of the registry.
+func (reg *Registry) ReloadStats() ReloadStats {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return reg.stats
+}
+
+// controllerClient returns the client calling the controller.
+// controllerAddress returns the address of the controller set by WithControllerAddress, SOAJS_REGISTRY_API by default.
+func (reg *Registry) controllerAddress() (registryPath, error) {
+	if reg.controllerAddr != "" {
+		return parseRegistryAddress("controller address", reg.controllerAddr)
+	}
+	return registryAddress()
+}
+
+func (reg *Registry) controllerClient() *http.Client {
+	if reg.client != nil {
+		return reg.client
+	}
+	return httpClient
+}
+
+func (reg *Registry) env() string {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return reg.Environment
+}
+
+// update swaps the registry data with the freshly fetched one.
+func (reg *Registry) update(r *Registry) {
+	// Thread-safe update of registry data
+	reg.mu.Lock()
 
 	reg.TimeLoaded = r.TimeLoaded
+	reg.ts = r.ts
+	reg.etag = r.etag
+	reg.raw = r.raw
 	reg.Name = r.Name
 	reg.Environment = r.Environment
-	reg.ServiceType = r.ServiceType
 	reg.CoreDBs = r.CoreDBs
 	reg.TenantMetaDBs = r.TenantMetaDBs
 	reg.ServiceConfig = r.ServiceConfig
 	reg.Custom = r.Custom
 	reg.Resources = r.Resources
 	reg.Services = r.Services
+	reg.loadedAt = time.Now()
+	reg.logBase = newLogHandler(r.ServiceConfig.Logger, reg.logOutput)
+	env, loadedAt := reg.Environment, reg.loadedAt
+	reg.mu.Unlock()
 
-	return nil
+	reg.metricsOrNop().RegistryLoaded(env, loadedAt)
+	reg.Logger().Debug("registry loaded", "env", env, "timeLoaded", r.TimeLoaded)
 }
 
 // You can run this method in go routine.
 func (reg *Registry) autoReload(ctx context.Context) {
+	if reg.push {
+		reg.watch(ctx)
+		return
+	}
 	ticker := time.NewTicker(reg.autoReloadDuration())
 	for {
 		select {
 		case <-ticker.C:
-			err := reg.Reload()
-			if err == nil {
+			err := reg.ReloadContext(ctx)
+			if ctx.Err() != nil {
 				ticker.Stop()
-				ticker = time.NewTicker(reg.autoReloadDuration())
+				return
 			}
+			if err != nil {
+				reg.Logger().Error("registry auto reload failed, keeping the loaded registry", "env", reg.env(), "error", err)
+				continue
+			}
+			ticker.Stop()
+			ticker = time.NewTicker(reg.autoReloadDuration())
 		case <-ctx.Done():
 			ticker.Stop()
 			return
@@ -178,6 +421,13 @@ func (reg *Registry) autoReloadDuration() time.Duration {
 	return time.Hour
 }
 
+// LoadedAt returns when the registry was last loaded from the controller.
+func (reg *Registry) LoadedAt() time.Time {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return reg.loadedAt
+}
+
 // Database returns one database by name.
 func (reg *Registry) Database(dbName string) (*Database, error) {
 	if dbName == "" {
@@ -256,3 +506,41 @@ func (reg *Registry) GetCustom(name string) (interface{}, error) {
 	}
 	return nil, errors.New("no custom registries found")
 }
+
+// DecodeCustom decodes the value of the custom registry into v, e.g. a struct generated by soajsgen.
+func (reg *Registry) DecodeCustom(name string, v interface{}) error {
+	if name == "" {
+		return errors.New("custom registry name is required")
+	}
+	reg.mu.RLock()
+	custom, ok := reg.Custom[name]
+	reg.mu.RUnlock()
+	if !ok {
+		return errors.New("custom registry not found")
+	}
+	if err := decodeValue(custom.Value, v); err != nil {
+		return fmt.Errorf("could not decode custom registry %s: %v", name, err)
+	}
+	return nil
+}
+
+// DecodeResourceConfig decodes the config of the resource into v, e.g. a struct generated by soajsgen.
+func (reg *Registry) DecodeResourceConfig(name string, v interface{}) error {
+	resource, err := reg.Resource(name)
+	if err != nil {
+		return err
+	}
+	if err := decodeValue(resource.Config, v); err != nil {
+		return fmt.Errorf("could not decode config of resource %s: %v", name, err)
+	}
+	return nil
+}
+
+// decodeValue decodes a value of the registry, as decoded from JSON, into v.
+func decodeValue(value, v interface{}) error {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
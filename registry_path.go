@@ -18,12 +18,26 @@ func registryAddress() (registryPath, error) {
 	if registryAPI == "" {
 		return "", fmt.Errorf("could not find environment variable %s", EnvRegistryAPIAddress)
 	}
+	return parseRegistryAddress(EnvRegistryAPIAddress, registryAPI)
+}
+
+// controllerAddress returns the address of the controller the options configure, see WithControllerAddress.
+func controllerAddress(opts []RegistryOption) (registryPath, error) {
+	reg := &Registry{}
+	for _, opt := range opts {
+		opt(reg)
+	}
+	return reg.controllerAddress()
+}
+
+// parseRegistryAddress validates the hostname:port address of the controller read from source.
+func parseRegistryAddress(source, registryAPI string) (registryPath, error) {
 	if index := strings.Index(registryAPI, ":"); index == -1 {
-		return "", fmt.Errorf("invalid format for %s. Got [%s], expected [hostname:port]", EnvRegistryAPIAddress, registryAPI)
+		return "", fmt.Errorf("invalid format for %s. Got [%s], expected [hostname:port]", source, registryAPI)
 	}
 	port := strings.Split(registryAPI, ":")[1]
 	if port == "" {
-		return "", fmt.Errorf("port is empty in %s. Got [%s], expected [hostname:port]", EnvRegistryAPIAddress, registryAPI)
+		return "", fmt.Errorf("port is empty in %s. Got [%s], expected [hostname:port]", source, registryAPI)
 	}
 	if _, err := strconv.Atoi(port); err != nil {
 		return "", fmt.Errorf("port must be an integer, got %q", port)
@@ -35,10 +49,22 @@ func (r registryPath) register() string {
 	return fmt.Sprintf("http://%s/register", r)
 }
 
+func (r registryPath) unregister() string {
+	return fmt.Sprintf("http://%s/unregister", r)
+}
+
 func (r registryPath) getRegistry(serviceName, envCode, serviceType string) string {
 	return fmt.Sprintf("http://%s/getRegistry?env=%s&serviceName=%s&type=%s", r, envCode, serviceName, serviceType)
 }
 
+func (r registryPath) registryEvents(serviceName, envCode, serviceType string) string {
+	return fmt.Sprintf("http://%s/registryEvents?env=%s&serviceName=%s&type=%s", r, envCode, serviceName, serviceType)
+}
+
+func (r registryPath) daemonGroupConf(group, daemonName, envCode string) string {
+	return fmt.Sprintf("http://%s/daemonGroupConf?grp=%s&daemon=%s&env=%s", r, group, daemonName, envCode)
+}
+
 func registryResponse(res *http.Response) (*Registry, error) {
 	if res.StatusCode < 200 || res.StatusCode > 299 {
 		b, err := io.ReadAll(res.Body)
@@ -47,8 +73,13 @@ func registryResponse(res *http.Response) (*Registry, error) {
 		}
 		return nil, fmt.Errorf("non 2xx status code: %d %s", res.StatusCode, b)
 	}
+	return decodeRegistryResponse(res.Body)
+}
+
+// decodeRegistryResponse decodes the registry of a successful registry API response.
+func decodeRegistryResponse(r io.Reader) (*Registry, error) {
 	var regRes registryAPIResponse
-	err := json.NewDecoder(res.Body).Decode(&regRes)
+	err := json.NewDecoder(r).Decode(&regRes)
 	if err != nil {
 		return nil, fmt.Errorf("could not decode registry response: %v", err)
 	}
@@ -60,5 +91,6 @@ func registryResponse(res *http.Response) (*Registry, error) {
 	if !regRes.Result {
 		return nil, errors.New("negative result by registry")
 	}
+	regRes.Registry.ts = regRes.Ts
 	return &regRes.Registry, nil
 }
@@ -10,3 +10,8 @@ func TestRegistryPath_register(t *testing.T) {
 	path := registryPath("localhost")
 	assert.Equal(t, "http://localhost/register", path.register())
 }
+
+func TestRegistryPath_unregister(t *testing.T) {
+	path := registryPath("localhost")
+	assert.Equal(t, "http://localhost/unregister", path.unregister())
+}
@@ -0,0 +1,151 @@
+package soajsgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// RegistrySet holds the registries of a service in several environments, loaded from the controller of
+	// SOAJS_REGISTRY_API. The registries share one http client, so one transport and its connections.
+	RegistrySet struct {
+		regs map[string]*Registry
+	}
+
+	// RegistrySetHealth is the health of the registries of a set. The set is healthy when the last reload of every
+	// registry succeeded.
+	RegistrySetHealth struct {
+		Healthy bool                 `json:"healthy"`
+		Envs    map[string]EnvHealth `json:"envs"`
+	}
+
+	// EnvHealth is the health of the registry of an environment.
+	EnvHealth struct {
+		Healthy  bool      `json:"healthy"`
+		LoadedAt time.Time `json:"loadedAt"`
+		Error    string    `json:"error,omitempty"`
+	}
+)
+
+// NewRegistrySet loads the registries of the service in the environments concurrently. It fails when any of them
+// can not be loaded. When turnOnAutoReload is true, every registry reloads on the interval of its own registry
+// until ctx is done. The options apply to every registry; a WithHTTPClient option replaces the shared client.
+func NewRegistrySet(ctx context.Context, serviceName, serviceType string, envCodes []string, turnOnAutoReload bool, opts ...RegistryOption) (*RegistrySet, error) {
+	if len(envCodes) == 0 {
+		return nil, errors.New("at least one env code is required")
+	}
+	client := &http.Client{
+		Timeout:   httpClient.Timeout,
+		Transport: http.DefaultTransport.(*http.Transport).Clone(),
+	}
+	opts = append([]RegistryOption{WithHTTPClient(client)}, opts...)
+
+	set := &RegistrySet{regs: make(map[string]*Registry, len(envCodes))}
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs []error
+	)
+	seen := make(map[string]bool, len(envCodes))
+	for _, code := range envCodes {
+		code = strings.ToLower(code)
+		if seen[code] {
+			continue
+		}
+		seen[code] = true
+		wg.Add(1)
+		go func(code string) {
+			defer wg.Done()
+			reg, err := New(ctx, serviceName, code, serviceType, false, opts...)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				errs = append(errs, fmt.Errorf("could not load registry of env %s: %v", code, err))
+				return
+			}
+			set.regs[code] = reg
+		}(code)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+		return nil, errors.Join(errs...)
+	}
+	if turnOnAutoReload {
+		for _, reg := range set.regs {
+			go reg.autoReload(ctx)
+		}
+	}
+	return set, nil
+}
+
+// ForEnv returns the registry of the environment.
+func (s *RegistrySet) ForEnv(code string) (*Registry, error) {
+	if code == "" {
+		return nil, errors.New("env code is required")
+	}
+	if reg, ok := s.regs[strings.ToLower(code)]; ok {
+		return reg, nil
+	}
+	return nil, fmt.Errorf("registry of env %s not found", code)
+}
+
+// Envs returns the environments of the set, sorted.
+func (s *RegistrySet) Envs() []string {
+	envs := make([]string, 0, len(s.regs))
+	for code := range s.regs {
+		envs = append(envs, code)
+	}
+	sort.Strings(envs)
+	return envs
+}
+
+// Reload reloads the registries concurrently. A registry failing to reload keeps its loaded data.
+func (s *RegistrySet) Reload() error {
+	return s.ReloadContext(context.Background())
+}
+
+// ReloadContext reloads the registries like Reload, aborting the requests to the controller when ctx is done.
+func (s *RegistrySet) ReloadContext(ctx context.Context) error {
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs []error
+	)
+	for _, code := range s.Envs() {
+		wg.Add(1)
+		go func(code string) {
+			defer wg.Done()
+			if err := s.regs[code].ReloadContext(ctx); err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("could not reload registry of env %s: %v", code, err))
+				mu.Unlock()
+			}
+		}(code)
+	}
+	wg.Wait()
+	sort.Slice(errs, func(i, j int) bool { return errs[i].Error() < errs[j].Error() })
+	return errors.Join(errs...)
+}
+
+// Health returns the health of the registries.
+func (s *RegistrySet) Health() RegistrySetHealth {
+	h := RegistrySetHealth{Healthy: true, Envs: make(map[string]EnvHealth, len(s.regs))}
+	for code, reg := range s.regs {
+		reg.mu.RLock()
+		env := EnvHealth{Healthy: reg.reloadErr == nil, LoadedAt: reg.loadedAt}
+		if reg.reloadErr != nil {
+			env.Error = reg.reloadErr.Error()
+		}
+		reg.mu.RUnlock()
+		h.Healthy = h.Healthy && env.Healthy
+		h.Envs[code] = env
+	}
+	return h
+}
@@ -0,0 +1,105 @@
+package soajsgo
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newSetController serves the registry of every env but the failing ones.
+func newSetController(t *testing.T, failing map[string]bool, mu *sync.Mutex) {
+	t.Helper()
+	controller := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		env := r.URL.Query().Get("env")
+		mu.Lock()
+		fail := failing[env]
+		mu.Unlock()
+		if fail {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = fmt.Fprintf(w, `{"result":true,"data":{"name":"ops","environment":%q,"services":{"%s-svc":{"port":4000}}}}`, env, env)
+	}))
+	t.Cleanup(controller.Close)
+	t.Setenv(EnvRegistryAPIAddress, strings.TrimPrefix(controller.URL, "http://"))
+}
+
+func TestNewRegistrySet(t *testing.T) {
+	var mu sync.Mutex
+	failing := map[string]bool{}
+	newSetController(t, failing, &mu)
+
+	set, err := NewRegistrySet(context.Background(), "ops", "service", []string{"DEV", "stg", "prod", "dev"}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"dev", "prod", "stg"}, set.Envs())
+
+	for _, code := range []string{"dev", "STG", "prod"} {
+		reg, err := set.ForEnv(code)
+		require.NoError(t, err)
+		assert.Equal(t, strings.ToLower(code), reg.Environment)
+		_, err = reg.Service(strings.ToLower(code) + "-svc")
+		assert.NoError(t, err)
+	}
+	dev, _ := set.ForEnv("dev")
+	prod, _ := set.ForEnv("prod")
+	assert.Same(t, dev.controllerClient(), prod.controllerClient(), "registries must share the client")
+	assert.NotSame(t, httpClient, dev.controllerClient())
+
+	_, err = set.ForEnv("qa")
+	assert.EqualError(t, err, "registry of env qa not found")
+	_, err = set.ForEnv("")
+	assert.EqualError(t, err, "env code is required")
+
+	health := set.Health()
+	assert.True(t, health.Healthy)
+	assert.Len(t, health.Envs, 3)
+	assert.False(t, health.Envs["stg"].LoadedAt.IsZero())
+
+	mu.Lock()
+	failing["stg"] = true
+	mu.Unlock()
+	assert.EqualError(t, set.Reload(), "could not reload registry of env stg: non 2xx status code: 502 ")
+	health = set.Health()
+	assert.False(t, health.Healthy)
+	assert.True(t, health.Envs["dev"].Healthy)
+	assert.Equal(t, EnvHealth{LoadedAt: health.Envs["stg"].LoadedAt, Error: "non 2xx status code: 502 "}, health.Envs["stg"])
+	_, err = set.ForEnv("stg")
+	assert.NoError(t, err, "a failed reload keeps the registry")
+
+	mu.Lock()
+	failing["stg"] = false
+	mu.Unlock()
+	require.NoError(t, set.Reload())
+	assert.True(t, set.Health().Healthy)
+}
+
+func TestNewRegistrySet_Errors(t *testing.T) {
+	var mu sync.Mutex
+	newSetController(t, map[string]bool{"stg": true, "prod": true}, &mu)
+
+	_, err := NewRegistrySet(context.Background(), "ops", "service", nil, false)
+	assert.EqualError(t, err, "at least one env code is required")
+
+	_, err = NewRegistrySet(context.Background(), "ops", "service", []string{"dev", "stg", "prod"}, false)
+	assert.EqualError(t, err, "could not load registry of env prod: non 2xx status code: 502 \n"+
+		"could not load registry of env stg: non 2xx status code: 502 ")
+}
+
+func TestNewRegistrySet_HTTPClient(t *testing.T) {
+	var mu sync.Mutex
+	newSetController(t, map[string]bool{}, &mu)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	set, err := NewRegistrySet(ctx, "ops", "service", []string{"dev"}, true, WithHTTPClient(http.DefaultClient))
+	require.NoError(t, err)
+	dev, err := set.ForEnv("dev")
+	require.NoError(t, err)
+	assert.Same(t, http.DefaultClient, dev.controllerClient(), "options must override the shared client")
+}
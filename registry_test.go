@@ -2,8 +2,13 @@ package soajsgo
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -172,7 +177,7 @@ func TestManualDeploy(t *testing.T) {
 			require.NoError(t, os.Setenv(EnvDeployManual, tc.envDeployManual))
 
 			addr := registryPath("localhost")
-			err := manualDeploy(tc.config, addr)
+			err := (&Registry{}).manualDeploy(context.Background(), tc.config, addr)
 			assert.Contains(t, err.Error(), tc.expectedErr.Error())
 
 			require.NoError(t, os.Setenv(EnvDeployManual, envDeployManual))
@@ -185,6 +190,133 @@ func TestRegistry_Reload(t *testing.T) {
 	assert.Error(t, reg.Reload())
 }
 
+// newHangingController serves a registry reloading every second, then hangs every request after the first until
+// the request is canceled. hung receives the hanging requests.
+func newHangingController(t *testing.T) (hung <-chan struct{}) {
+	t.Helper()
+	ch := make(chan struct{}, 10)
+	var calls int32
+	controller := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			_, _ = w.Write([]byte(`{"result":true,"data":{"name":"svc","environment":"dev","serviceConfig":{"awareness":{"autoReloadRegistry":1000}}}}`))
+			return
+		}
+		ch <- struct{}{}
+		<-r.Context().Done()
+	}))
+	t.Cleanup(controller.Close)
+	t.Setenv(EnvRegistryAPIAddress, strings.TrimPrefix(controller.URL, "http://"))
+	return ch
+}
+
+func TestNew_WithControllerAddress(t *testing.T) {
+	controller := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"result":true,"data":{"name":"svc","environment":"dev"}}`))
+	}))
+	defer controller.Close()
+	t.Setenv(EnvRegistryAPIAddress, "")
+
+	reg, err := New(context.Background(), "svc", "dev", "service", false, WithControllerAddress(strings.TrimPrefix(controller.URL, "http://")))
+	require.NoError(t, err)
+	assert.Equal(t, "dev", reg.Environment)
+
+	_, err = New(context.Background(), "svc", "dev", "service", false, WithControllerAddress("api"))
+	assert.EqualError(t, err, "could not init registry api path: invalid format for controller address. Got [api], expected [hostname:port]")
+}
+
+func TestRegistry_ReloadContext(t *testing.T) {
+	hung := newHangingController(t)
+	reg, err := New(context.Background(), "svc", "dev", "service", false)
+	require.NoError(t, err)
+	loadedAt := reg.LoadedAt()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err = reg.ReloadContext(ctx)
+	assert.ErrorContains(t, err, context.DeadlineExceeded.Error())
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Len(t, hung, 1)
+	assert.Equal(t, loadedAt, reg.LoadedAt(), "a canceled reload must keep the loaded registry")
+	assert.Equal(t, "dev", reg.Environment)
+}
+
+func TestNew_Canceled(t *testing.T) {
+	newHangingController(t)
+	_, err := New(context.Background(), "svc", "dev", "service", false)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = New(ctx, "svc", "dev", "service", true)
+	assert.ErrorContains(t, err, context.Canceled.Error())
+}
+
+func TestRegistry_autoReload_Canceled(t *testing.T) {
+	hung := newHangingController(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reg, err := New(ctx, "svc", "dev", "service", false)
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+	go func() {
+		reg.autoReload(ctx)
+		close(done)
+	}()
+	select {
+	case <-hung:
+	case <-time.After(5 * time.Second):
+		t.Fatal("auto reload did not call the controller")
+	}
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("auto reload did not abort the in-flight reload")
+	}
+}
+
+func TestRegistry_ReloadContext_NotModified(t *testing.T) {
+	var (
+		etag    atomic.Value
+		matches []string
+	)
+	etag.Store(`"1"`)
+	controller := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		matches = append(matches, r.Header.Get("If-None-Match"))
+		current := etag.Load().(string)
+		w.Header().Set("ETag", current)
+		if r.Header.Get("If-None-Match") == current {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		_, _ = w.Write([]byte(`{"result":true,"data":{"name":"svc","environment":"dev","timeLoaded":` + strings.Trim(current, `"`) + `}}`))
+	}))
+	defer controller.Close()
+	t.Setenv(EnvRegistryAPIAddress, strings.TrimPrefix(controller.URL, "http://"))
+	metrics := NewMetricsCollector()
+
+	reg, err := New(context.Background(), "svc", "dev", "service", false, WithMetrics(metrics))
+	require.NoError(t, err)
+	loadedAt := reg.LoadedAt()
+	require.NoError(t, reg.Reload())
+	assert.Equal(t, loadedAt, reg.LoadedAt(), "an unchanged registry must not be swapped")
+	etag.Store(`"2"`)
+	require.NoError(t, reg.Reload())
+	assert.Equal(t, int64(2), reg.TimeLoaded)
+	require.NoError(t, reg.Reload())
+
+	assert.Equal(t, []string{"", `"1"`, `"1"`, `"2"`}, matches)
+	stats := reg.ReloadStats()
+	assert.Equal(t, ReloadStats{Reloads: 4, Unchanged: 2, LastReload: stats.LastReload}, stats)
+	assert.False(t, stats.LastReload.IsZero())
+	var out strings.Builder
+	_, err = metrics.WriteTo(&out)
+	require.NoError(t, err)
+	assert.Contains(t, out.String(), `soajs_registry_reloads_total{env="dev",result="success"} 4`)
+}
+
 func TestRegistry_autoReloadDuration(t *testing.T) {
 	tt := []struct {
 		name             string
@@ -485,3 +617,46 @@ func TestRegistry_GetCustom(t *testing.T) {
 		})
 	}
 }
+
+func TestConfig_RegisterPayload(t *testing.T) {
+	c := Config{ServiceName: "users", ServicePort: 4000, Type: "service", ServiceVersion: "1",
+		Prerequisites: prerequisites{CPU: "500m", Memory: "256Mi"}}
+	b, err := c.RegisterPayload()
+	require.NoError(t, err)
+	var payload map[string]interface{}
+	require.NoError(t, json.Unmarshal(b, &payload))
+	assert.Equal(t, "users", payload["name"])
+	assert.Equal(t, "127.0.0.1", payload["ip"])
+	assert.Equal(t, 4000.0, payload["port"])
+	assert.Equal(t, true, payload["mw"])
+	assert.Equal(t, map[string]interface{}{"cpu": "500m", "memory": "256Mi"}, payload["prerequisites"])
+}
+
+func TestRegistry_DecodeCustom(t *testing.T) {
+	reg := &Registry{
+		Custom: CustomRegistries{
+			"mail": {Name: "mail", Value: map[string]interface{}{"host": "smtp", "port": 25.0}},
+			"bad":  {Name: "bad", Value: "text"},
+		},
+		Resources: Resources{"cluster": {"mongo": {Name: "mongo", Config: map[string]interface{}{"servers": []interface{}{"a", "b"}}}}},
+	}
+	var mail struct {
+		Host string `json:"host"`
+		Port int    `json:"port"`
+	}
+	require.NoError(t, reg.DecodeCustom("mail", &mail))
+	assert.Equal(t, "smtp", mail.Host)
+	assert.Equal(t, 25, mail.Port)
+	assert.EqualError(t, reg.DecodeCustom("", &mail), "custom registry name is required")
+	assert.EqualError(t, reg.DecodeCustom("sms", &mail), "custom registry not found")
+	assert.ErrorContains(t, reg.DecodeCustom("bad", &mail), "could not decode custom registry bad: json: cannot unmarshal string")
+
+	var mongo struct {
+		Servers []string `json:"servers"`
+	}
+	require.NoError(t, reg.DecodeResourceConfig("mongo", &mongo))
+	assert.Equal(t, []string{"a", "b"}, mongo.Servers)
+	assert.EqualError(t, reg.DecodeResourceConfig("redis", &mongo), "resource not found")
+	var port int
+	assert.ErrorContains(t, reg.DecodeResourceConfig("mongo", &port), "could not decode config of resource mongo")
+}
@@ -0,0 +1,180 @@
+package soajsgo
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultPushRetry is the delay to reconnect to the updates pushed by the controller.
+	defaultPushRetry = 5 * time.Second
+
+	// registryEventName is the server-sent event announcing a change of the registry.
+	registryEventName = "registry"
+)
+
+// registryEvent is the data of a registry event: the controller timestamp of the last change of the registry.
+type registryEvent struct {
+	Ts int64 `json:"ts"`
+}
+
+// WithPushUpdates makes the auto reload follow the registry updates the controller pushes as server-sent events on
+// /registryEvents, instead of polling. The registry reloads as soon as an event is newer than the loaded registry.
+// While the events can not be received, the registry polls every auto reload interval and reconnects after retry,
+// 5 seconds when retry is not positive.
+func WithPushUpdates(retry time.Duration) RegistryOption {
+	return func(reg *Registry) {
+		if retry <= 0 {
+			retry = defaultPushRetry
+		}
+		reg.push = true
+		reg.pushRetry = retry
+	}
+}
+
+// watch reloads the registry on the updates pushed by the controller until ctx is done.
+func (reg *Registry) watch(ctx context.Context) {
+	poll := time.NewTimer(reg.autoReloadDuration())
+	defer poll.Stop()
+	for {
+		connected, err := reg.streamUpdates(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		if connected {
+			resetTimer(poll, reg.autoReloadDuration())
+		}
+		reg.Logger().Warn("registry push updates interrupted, polling until reconnected", "env", reg.env(), "error", err)
+		if !reg.pollUntilRetry(ctx, poll) {
+			return
+		}
+	}
+}
+
+// pollUntilRetry reloads the registry when poll fires until it is time to reconnect to the pushed updates. It returns
+// false when ctx is done.
+func (reg *Registry) pollUntilRetry(ctx context.Context, poll *time.Timer) bool {
+	retry := time.NewTimer(reg.pushRetry)
+	defer retry.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-retry.C:
+			return true
+		case <-poll.C:
+			if err := reg.ReloadContext(ctx); err != nil && ctx.Err() == nil {
+				reg.Logger().Error("registry auto reload failed, keeping the loaded registry", "env", reg.env(), "error", err)
+			}
+			poll.Reset(reg.autoReloadDuration())
+		}
+	}
+}
+
+// streamUpdates receives the registry events of the controller, reloading the registry on a change, until the stream
+// ends. connected reports whether the stream was established.
+// nolint: errcheck
+func (reg *Registry) streamUpdates(ctx context.Context) (connected bool, err error) {
+	addr, err := reg.controllerAddress()
+	if err != nil {
+		return false, fmt.Errorf("could not init registry api path: %v", err)
+	}
+	reg.mu.RLock()
+	serviceName, envCode, serviceType := reg.Name, reg.Environment, reg.ServiceType
+	reg.mu.RUnlock()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, addr.registryEvents(serviceName, envCode, serviceType), nil)
+	if err != nil {
+		return false, fmt.Errorf("could not connect to registry events: %v", err)
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	// The stream is long lived: keep the transport of the controller client but not its timeout.
+	client := *reg.controllerClient()
+	client.Timeout = 0
+	res, err := client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("could not connect to registry events: %v", err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return false, fmt.Errorf("could not connect to registry events: non 2xx status code: %d", res.StatusCode)
+	}
+	if contentType := res.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/event-stream") {
+		return false, fmt.Errorf("could not connect to registry events: unexpected content type %q", contentType)
+	}
+	reg.Logger().Debug("registry push updates connected", "env", envCode)
+	err = readEvents(res.Body, func(event string, data []byte) {
+		if event == registryEventName {
+			reg.onRegistryEvent(ctx, data)
+		}
+	})
+	if err == nil {
+		err = errors.New("registry events closed by the controller")
+	}
+	return true, err
+}
+
+// onRegistryEvent reloads the registry when the event is newer than the loaded registry.
+func (reg *Registry) onRegistryEvent(ctx context.Context, data []byte) {
+	var e registryEvent
+	if err := json.Unmarshal(data, &e); err != nil {
+		reg.Logger().Warn("invalid registry event", "env", reg.env(), "error", err)
+		return
+	}
+	reg.mu.RLock()
+	ts := reg.ts
+	reg.mu.RUnlock()
+	if e.Ts <= ts {
+		return
+	}
+	if err := reg.ReloadContext(ctx); err != nil && ctx.Err() == nil {
+		reg.Logger().Error("registry reload on pushed update failed, keeping the loaded registry", "env", reg.env(), "error", err)
+	}
+}
+
+// readEvents reads the server-sent events of r and calls fn with the name, "message" by default, and the data of
+// each event. It returns at the end of r.
+func readEvents(r io.Reader, fn func(event string, data []byte)) error {
+	scanner := bufio.NewScanner(r)
+	event, data := "", [][]byte(nil)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			if data != nil {
+				if event == "" {
+					event = "message"
+				}
+				fn(event, bytes.Join(data, []byte("\n")))
+			}
+			event, data = "", nil
+			continue
+		}
+		field, value, _ := bytes.Cut(line, []byte(":"))
+		value = bytes.TrimPrefix(value, []byte(" "))
+		switch string(field) {
+		case "event":
+			event = string(value)
+		case "data":
+			data = append(data, append([]byte(nil), value...))
+		}
+	}
+	return scanner.Err()
+}
+
+// resetTimer stops the timer, draining a fired one, and resets it to d.
+func resetTimer(t *time.Timer, d time.Duration) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+	t.Reset(d)
+}
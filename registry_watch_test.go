@@ -0,0 +1,173 @@
+package soajsgo
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadEvents(t *testing.T) {
+	type event struct{ name, data string }
+	tt := []struct {
+		name     string
+		stream   string
+		expected []event
+	}{
+		{name: "named", stream: "event: registry\ndata: {\"ts\":1}\n\n", expected: []event{{"registry", `{"ts":1}`}}},
+		{name: "default name", stream: "data:hello\n\n", expected: []event{{"message", "hello"}}},
+		{name: "multi line data", stream: "data: a\ndata: b\n\n", expected: []event{{"message", "a\nb"}}},
+		{name: "comments and unknown fields", stream: ": keep alive\nid: 3\nretry: 10\n\nevent: registry\ndata: x\n\n", expected: []event{{"registry", "x"}}},
+		{name: "no data", stream: "event: registry\n\n"},
+		{name: "unterminated", stream: "event: registry\ndata: x\n"},
+		{name: "several", stream: "data: 1\n\ndata: 2\n\n", expected: []event{{"message", "1"}, {"message", "2"}}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var events []event
+			err := readEvents(strings.NewReader(tc.stream), func(name string, data []byte) {
+				events = append(events, event{name, string(data)})
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, events)
+		})
+	}
+}
+
+func TestWithPushUpdates(t *testing.T) {
+	reg := &Registry{}
+	WithPushUpdates(0)(reg)
+	assert.True(t, reg.push)
+	assert.Equal(t, defaultPushRetry, reg.pushRetry)
+	WithPushUpdates(time.Millisecond)(reg)
+	assert.Equal(t, time.Millisecond, reg.pushRetry)
+}
+
+// pushController is a controller pushing registry events. The registry served and pushed has the timestamp ts.
+type pushController struct {
+	mu        sync.Mutex
+	ts        int64
+	changed   chan struct{}
+	eventsErr int
+	fetches   int32
+	streams   int32
+	open      int32
+}
+
+func newPushController(t *testing.T, ts int64, autoReload int) *pushController {
+	t.Helper()
+	c := &pushController{ts: ts, changed: make(chan struct{})}
+	controller := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/getRegistry":
+			atomic.AddInt32(&c.fetches, 1)
+			c.mu.Lock()
+			ts := c.ts
+			c.mu.Unlock()
+			_, _ = fmt.Fprintf(w, `{"result":true,"ts":%d,"data":{"name":"svc","environment":"dev","timeLoaded":%d,"serviceConfig":{"awareness":{"autoReloadRegistry":%d}}}}`, ts, ts, autoReload)
+		case "/registryEvents":
+			atomic.AddInt32(&c.streams, 1)
+			assert.Equal(t, "text/event-stream", r.Header.Get("Accept"))
+			assert.Equal(t, "env=dev&serviceName=svc&type=service", r.URL.RawQuery)
+			c.serveEvents(w, r)
+		}
+	}))
+	t.Cleanup(func() {
+		controller.CloseClientConnections()
+		controller.Close()
+	})
+	t.Setenv(EnvRegistryAPIAddress, strings.TrimPrefix(controller.URL, "http://"))
+	return c
+}
+
+func (c *pushController) serveEvents(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	if c.eventsErr > 0 {
+		c.eventsErr--
+		c.mu.Unlock()
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	c.mu.Unlock()
+	atomic.AddInt32(&c.open, 1)
+	defer atomic.AddInt32(&c.open, -1)
+	w.Header().Set("Content-Type", "text/event-stream")
+	sent := int64(-1)
+	for {
+		c.mu.Lock()
+		ts, changed := c.ts, c.changed
+		c.mu.Unlock()
+		if ts != sent {
+			_, _ = fmt.Fprintf(w, "event: registry\ndata: {\"ts\":%d}\n\n", ts)
+			w.(http.Flusher).Flush()
+			sent = ts
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+func (c *pushController) set(ts int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ts = ts
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+func TestRegistry_watch(t *testing.T) {
+	c := newPushController(t, 10, 0)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reg, err := New(ctx, "svc", "dev", "service", true, WithPushUpdates(time.Millisecond), WithLogOutput(io.Discard))
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&c.open) == 1 }, 5*time.Second, time.Millisecond)
+	c.set(20)
+	require.Eventually(t, func() bool { return timeLoaded(reg) == 20 }, 5*time.Second, time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&c.fetches), "the current ts sent on connect must not reload")
+
+	c.set(5)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&c.fetches), "an older ts must not reload")
+}
+
+func TestRegistry_watch_Fallback(t *testing.T) {
+	c := newPushController(t, 10, 1000)
+	c.eventsErr = 1 << 30
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reg, err := New(ctx, "svc", "dev", "service", true, WithPushUpdates(10*time.Millisecond), WithLogOutput(io.Discard))
+	require.NoError(t, err)
+
+	// Without events, the registry polls every second and keeps trying to reconnect.
+	c.set(20)
+	require.Eventually(t, func() bool { return timeLoaded(reg) == 20 }, 5*time.Second, 10*time.Millisecond)
+	assert.Greater(t, atomic.LoadInt32(&c.streams), int32(1))
+
+	// Once reconnected, the pushed change reloads right away.
+	c.mu.Lock()
+	c.eventsErr = 0
+	c.mu.Unlock()
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&c.open) == 1 }, 5*time.Second, time.Millisecond)
+	c.set(30)
+	require.Eventually(t, func() bool { return timeLoaded(reg) == 30 }, 500*time.Millisecond, time.Millisecond)
+}
+
+func timeLoaded(reg *Registry) int64 {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	return reg.TimeLoaded
+}
@@ -0,0 +1,302 @@
+package soajsgo
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Error codes of the SOAJS envelope returned by the middleware.
+const (
+	// CodeNoContext is returned in strict mode when the request does not carry a SOAJS injected object.
+	CodeNoContext = 132
+	// CodeSession is returned when the session of the request cannot be loaded from the store.
+	CodeSession = 135
+	// CodeTimeout is returned when the request exceeds its timeout.
+	CodeTimeout = 136
+	// CodeACLDenied is returned when the tenant ACL does not allow the request.
+	CodeACLDenied = 154
+	// CodeMissingField is returned when a required input of the schema is missing.
+	CodeMissingField = 172
+	// CodeInvalidField is returned when an input does not validate against the schema.
+	CodeInvalidField = 173
+)
+
+type (
+	// Response is the SOAJS envelope of an API call: the data of a successful call or the errors of a failed one.
+	Response struct {
+		Result bool        `json:"result"`
+		Data   interface{} `json:"data,omitempty"`
+		Errors *Errors     `json:"errors,omitempty"`
+	}
+
+	// Errors lists the errors of a failed API call.
+	Errors struct {
+		Codes   []int   `json:"codes"`
+		Details []Error `json:"details"`
+	}
+
+	// Error is an error of the SOAJS envelope.
+	Error struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+
+	// ResponseError is returned by DecodeResponse when the call failed.
+	ResponseError struct {
+		StatusCode int
+		Errors     []Error
+	}
+
+	// ErrorCatalog maps the error codes of a service to their message template and HTTP status.
+	ErrorCatalog struct {
+		mu      sync.RWMutex
+		entries map[int]catalogEntry
+	}
+
+	catalogEntry struct {
+		status   int
+		template string
+	}
+
+	// rawResponse is the envelope decoded by DecodeResponse.
+	rawResponse struct {
+		Result bool            `json:"result"`
+		Data   json.RawMessage `json:"data"`
+		Errors *Errors         `json:"errors"`
+	}
+)
+
+// missingArg fills the verbs of a template formatted without matching arguments.
+const missingArg = "unknown"
+
+var (
+	// DefaultErrorCatalog is the catalog used by WriteError. It holds the codes of the middleware.
+	DefaultErrorCatalog = NewErrorCatalog()
+
+	// ErrErrorArgs is returned by ErrorCatalog.Format when the arguments do not match the template of the code.
+	ErrErrorArgs = errors.New("error arguments do not match the template")
+)
+
+// NewErrorCatalog creates a catalog holding the codes of the middleware.
+func NewErrorCatalog() *ErrorCatalog {
+	return (&ErrorCatalog{entries: make(map[int]catalogEntry)}).
+		Register(CodeNoContext, http.StatusUnauthorized, "SOAJS injected object is missing or invalid").
+		Register(CodeSession, http.StatusInternalServerError, "could not load session").
+		Register(CodeTimeout, http.StatusServiceUnavailable, "request timed out").
+		Register(CodeACLDenied, http.StatusForbidden, "access denied by ACL").
+		Register(CodeMissingField, http.StatusBadRequest, "Missing required field: %s").
+		Register(CodeInvalidField, http.StatusBadRequest, "Validation failed for field: %s -> The parameter '%s' failed due to: %s")
+}
+
+// RegisterError registers an error code in the default catalog.
+func RegisterError(code, status int, template string) {
+	DefaultErrorCatalog.Register(code, status, template)
+}
+
+// Register registers the HTTP status and the message template of the code, replacing a previous registration.
+// The template is formatted with the arguments of Error, the way fmt.Sprintf does.
+func (c *ErrorCatalog) Register(code, status int, template string) *ErrorCatalog {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[code] = catalogEntry{status: status, template: template}
+	return c
+}
+
+// Error returns the error of the code with its template formatted with args. An unknown code gets a generic message.
+// When args do not match the verbs of the template, e.g. none are given, the verbs are filled with a placeholder
+// instead, see Format.
+func (c *ErrorCatalog) Error(code int, args ...interface{}) Error {
+	e, err := c.Format(code, args...)
+	if err != nil {
+		c.mu.RLock()
+		template := c.entries[code].template
+		c.mu.RUnlock()
+		e.Message = fillVerbs(template, missingArg)
+	}
+	return e
+}
+
+// Format returns the error of the code like Error, but fails with ErrErrorArgs when the number of args does not match
+// the verbs of the template.
+func (c *ErrorCatalog) Format(code int, args ...interface{}) (Error, error) {
+	c.mu.RLock()
+	entry, ok := c.entries[code]
+	c.mu.RUnlock()
+	if !ok {
+		return Error{Code: code, Message: fmt.Sprintf("error %d", code)}, nil
+	}
+	if n := len(templateVerbs(entry.template)); n != len(args) {
+		return Error{Code: code}, fmt.Errorf("%w: code %d takes %d, got %d", ErrErrorArgs, code, n, len(args))
+	}
+	if len(args) == 0 {
+		return Error{Code: code, Message: entry.template}, nil
+	}
+	return Error{Code: code, Message: fmt.Sprintf(entry.template, args...)}, nil
+}
+
+// Status returns the HTTP status of the code, http.StatusInternalServerError for unknown codes.
+func (c *ErrorCatalog) Status(code int) int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if entry, ok := c.entries[code]; ok && entry.status != 0 {
+		return entry.status
+	}
+	return http.StatusInternalServerError
+}
+
+// Codes returns the registered codes, sorted.
+func (c *ErrorCatalog) Codes() []int {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	codes := make([]int, 0, len(c.entries))
+	for code := range c.entries {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+	return codes
+}
+
+// WriteError writes the error of the code, its template formatted with args, with the HTTP status of the code.
+func (c *ErrorCatalog) WriteError(w http.ResponseWriter, code int, args ...interface{}) {
+	c.WriteErrors(w, c.Error(code, args...))
+}
+
+// WriteErrors writes the errors with the HTTP status of the first one.
+func (c *ErrorCatalog) WriteErrors(w http.ResponseWriter, errs ...Error) {
+	status := http.StatusInternalServerError
+	if len(errs) > 0 {
+		status = c.Status(errs[0].Code)
+	}
+	c.writeErrors(w, status, errs...)
+}
+
+// writeErrors writes the errors with the HTTP status.
+func (c *ErrorCatalog) writeErrors(w http.ResponseWriter, status int, errs ...Error) {
+	res := Response{Errors: &Errors{Codes: []int{}, Details: []Error{}}}
+	for _, e := range errs {
+		res.Errors.Codes = append(res.Errors.Codes, e.Code)
+		res.Errors.Details = append(res.Errors.Details, e)
+	}
+	writeJSON(w, status, res)
+}
+
+// WriteData writes the successful response of an API call with v as data.
+func WriteData(w http.ResponseWriter, v interface{}) {
+	writeJSON(w, http.StatusOK, Response{Result: true, Data: v})
+}
+
+// WriteError writes the error of the code with the HTTP status of the default catalog. An empty message is
+// replaced by the template of the code, its verbs filled with a placeholder.
+func WriteError(w http.ResponseWriter, code int, msg string) {
+	e := DefaultErrorCatalog.Error(code)
+	if msg != "" {
+		e.Message = msg
+	}
+	DefaultErrorCatalog.WriteErrors(w, e)
+}
+
+// templateVerbs returns the start and end of the verbs of a fmt template, %% excluded.
+func templateVerbs(template string) [][2]int {
+	var verbs [][2]int
+	for i := 0; i < len(template); i++ {
+		if template[i] != '%' {
+			continue
+		}
+		start := i
+		for i++; i < len(template); i++ {
+			ch := template[i]
+			if ch == '%' && i == start+1 {
+				break
+			}
+			if ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' {
+				verbs = append(verbs, [2]int{start, i + 1})
+				break
+			}
+		}
+	}
+	return verbs
+}
+
+// fillVerbs returns the template with its verbs replaced by s and %% by %.
+func fillVerbs(template, s string) string {
+	var b strings.Builder
+	last := 0
+	for _, v := range templateVerbs(template) {
+		b.WriteString(strings.ReplaceAll(template[last:v[0]], "%%", "%"))
+		b.WriteString(s)
+		last = v[1]
+	}
+	b.WriteString(strings.ReplaceAll(template[last:], "%%", "%"))
+	return b.String()
+}
+
+// DecodeResponse decodes the SOAJS envelope of the response of another service, e.g. called with Connect, into v.
+// A failed call is returned as a *ResponseError. v may be nil when the data is not needed.
+func DecodeResponse(res *http.Response, v interface{}) error {
+	b, err := io.ReadAll(res.Body)
+	if err != nil {
+		return fmt.Errorf("could not read response: %v", err)
+	}
+	var raw rawResponse
+	if err := json.Unmarshal(b, &raw); err != nil {
+		if res.StatusCode < 200 || res.StatusCode > 299 {
+			return &ResponseError{StatusCode: res.StatusCode}
+		}
+		return fmt.Errorf("could not decode response: %v", err)
+	}
+	if !raw.Result || (raw.Errors != nil && len(raw.Errors.Details) > 0) {
+		e := &ResponseError{StatusCode: res.StatusCode}
+		if raw.Errors != nil {
+			e.Errors = raw.Errors.Details
+		}
+		return e
+	}
+	if v == nil || len(raw.Data) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(raw.Data, v); err != nil {
+		return fmt.Errorf("could not decode response data: %v", err)
+	}
+	return nil
+}
+
+// Error implements error.
+func (e Error) Error() string {
+	return fmt.Sprintf("[%d] %s", e.Code, e.Message)
+}
+
+// Error implements error.
+func (e *ResponseError) Error() string {
+	if len(e.Errors) == 0 {
+		return fmt.Sprintf("call failed with status %d", e.StatusCode)
+	}
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("call failed with status %d: %s", e.StatusCode, strings.Join(msgs, ", "))
+}
+
+// Has reports whether the call failed with the error code.
+func (e *ResponseError) Has(code int) bool {
+	for _, err := range e.Errors {
+		if err.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	_ = enc.Encode(v)
+}
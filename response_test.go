@@ -0,0 +1,196 @@
+package soajsgo
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorCatalog(t *testing.T) {
+	catalog := NewErrorCatalog().
+		Register(400, http.StatusBadRequest, "missing field %s").
+		Register(401, 0, "no status").
+		Register(402, http.StatusBadRequest, "field %s is %-5d%% of %v")
+
+	tt := []struct {
+		name            string
+		code            int
+		args            []interface{}
+		expectedMessage string
+		expectedStatus  int
+	}{
+		{name: "template with args", code: 400, args: []interface{}{"name"}, expectedMessage: "missing field name", expectedStatus: http.StatusBadRequest},
+		{name: "template without args", code: 400, expectedMessage: "missing field unknown", expectedStatus: http.StatusBadRequest},
+		{name: "template with too many args", code: 400, args: []interface{}{"a", "b"}, expectedMessage: "missing field unknown", expectedStatus: http.StatusBadRequest},
+		{name: "template with verbs and percent", code: 402, args: []interface{}{"a", 5, "b"}, expectedMessage: "field a is 5    % of b", expectedStatus: http.StatusBadRequest},
+		{name: "template with verbs and percent without args", code: 402, expectedMessage: "field unknown is unknown% of unknown", expectedStatus: http.StatusBadRequest},
+		{name: "builtin code", code: CodeACLDenied, expectedMessage: "access denied by ACL", expectedStatus: http.StatusForbidden},
+		{name: "registered without status", code: 401, expectedMessage: "no status", expectedStatus: http.StatusInternalServerError},
+		{name: "unknown code", code: 999, expectedMessage: "error 999", expectedStatus: http.StatusInternalServerError},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, Error{Code: tc.code, Message: tc.expectedMessage}, catalog.Error(tc.code, tc.args...))
+			assert.Equal(t, tc.expectedStatus, catalog.Status(tc.code))
+		})
+	}
+	assert.Equal(t, []int{CodeNoContext, CodeSession, CodeTimeout, CodeACLDenied, CodeMissingField, CodeInvalidField, 400, 401, 402}, catalog.Codes())
+}
+
+func TestErrorCatalog_Format(t *testing.T) {
+	catalog := NewErrorCatalog()
+	e, err := catalog.Format(CodeMissingField, "name")
+	require.NoError(t, err)
+	assert.Equal(t, Error{Code: CodeMissingField, Message: "Missing required field: name"}, e)
+
+	_, err = catalog.Format(CodeMissingField)
+	assert.ErrorIs(t, err, ErrErrorArgs)
+	assert.EqualError(t, err, "error arguments do not match the template: code 172 takes 1, got 0")
+	_, err = catalog.Format(CodeInvalidField, "name")
+	assert.ErrorIs(t, err, ErrErrorArgs)
+	_, err = catalog.Format(CodeTimeout, "late")
+	assert.ErrorIs(t, err, ErrErrorArgs)
+}
+
+func TestWriteResponse(t *testing.T) {
+	tt := []struct {
+		name           string
+		write          func(w http.ResponseWriter)
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "data",
+			write:          func(w http.ResponseWriter) { WriteData(w, map[string]int{"count": 1}) },
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"result":true,"data":{"count":1}}` + "\n",
+		},
+		{
+			name:           "error with message",
+			write:          func(w http.ResponseWriter) { WriteError(w, CodeNoContext, "no header") },
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   `{"result":false,"errors":{"codes":[132],"details":[{"code":132,"message":"no header"}]}}` + "\n",
+		},
+		{
+			name:           "error with template",
+			write:          func(w http.ResponseWriter) { WriteError(w, CodeTimeout, "") },
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   `{"result":false,"errors":{"codes":[136],"details":[{"code":136,"message":"request timed out"}]}}` + "\n",
+		},
+		{
+			name:           "error with template without args",
+			write:          func(w http.ResponseWriter) { WriteError(w, CodeMissingField, "") },
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"result":false,"errors":{"codes":[172],"details":[{"code":172,"message":"Missing required field: unknown"}]}}` + "\n",
+		},
+		{
+			name: "several errors",
+			write: func(w http.ResponseWriter) {
+				NewErrorCatalog().Register(400, http.StatusBadRequest, "bad %s").WriteErrors(w, Error{Code: 400, Message: "bad a"}, Error{Code: 401, Message: "other"})
+			},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"result":false,"errors":{"codes":[400,401],"details":[{"code":400,"message":"bad a"},{"code":401,"message":"other"}]}}` + "\n",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			tc.write(rec)
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			assert.Equal(t, tc.expectedBody, rec.Body.String())
+		})
+	}
+}
+
+func TestDecodeResponse(t *testing.T) {
+	type data struct {
+		Count int `json:"count"`
+	}
+	tt := []struct {
+		name          string
+		status        int
+		body          string
+		v             *data
+		expectedData  *data
+		expectedErr   string
+		expectedCodes []int
+	}{
+		{
+			name:         "data",
+			status:       http.StatusOK,
+			body:         `{"result":true,"data":{"count":2}}`,
+			v:            &data{},
+			expectedData: &data{Count: 2},
+		},
+		{
+			name:   "data ignored",
+			status: http.StatusOK,
+			body:   `{"result":true,"data":{"count":2}}`,
+		},
+		{
+			name:          "errors",
+			status:        http.StatusForbidden,
+			body:          `{"result":false,"errors":{"codes":[154],"details":[{"code":154,"message":"denied"}]}}`,
+			v:             &data{},
+			expectedData:  &data{},
+			expectedErr:   "call failed with status 403: [154] denied",
+			expectedCodes: []int{154},
+		},
+		{
+			name:        "negative result without errors",
+			status:      http.StatusOK,
+			body:        `{"result":false}`,
+			expectedErr: "call failed with status 200",
+		},
+		{
+			name:        "not an envelope",
+			status:      http.StatusBadGateway,
+			body:        `bad gateway`,
+			expectedErr: "call failed with status 502",
+		},
+		{
+			name:        "invalid body",
+			status:      http.StatusOK,
+			body:        `bad`,
+			expectedErr: "could not decode response: invalid character",
+		},
+		{
+			name:        "invalid data",
+			status:      http.StatusOK,
+			body:        `{"result":true,"data":{"count":"two"}}`,
+			v:           &data{},
+			expectedErr: "could not decode response data: json: cannot unmarshal string",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			rec.WriteHeader(tc.status)
+			_, _ = rec.WriteString(tc.body)
+
+			var v interface{}
+			if tc.v != nil {
+				v = tc.v
+			}
+			err := DecodeResponse(rec.Result(), v)
+			if tc.expectedErr != "" {
+				require.ErrorContains(t, err, tc.expectedErr)
+			} else {
+				require.NoError(t, err)
+			}
+			if tc.expectedData != nil {
+				assert.Equal(t, tc.expectedData, tc.v)
+			}
+			for _, code := range tc.expectedCodes {
+				var resErr *ResponseError
+				require.ErrorAs(t, err, &resErr)
+				assert.True(t, resErr.Has(code))
+				assert.False(t, resErr.Has(code+1))
+			}
+		})
+	}
+}
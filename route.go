@@ -0,0 +1,40 @@
+package soajsgo
+
+import "strings"
+
+// matchRoute reports whether path matches the SOAJS route pattern, where a segment starting with ":" matches any
+// non empty segment. The values of those segments are returned by parameter name.
+func matchRoute(pattern, path string) (map[string]string, bool) {
+	patternSegments := routeSegments(pattern)
+	pathSegments := routeSegments(path)
+	if len(patternSegments) != len(pathSegments) {
+		return nil, false
+	}
+	params := make(map[string]string)
+	for i, segment := range patternSegments {
+		if strings.HasPrefix(segment, ":") && len(segment) > 1 {
+			if pathSegments[i] == "" {
+				return nil, false
+			}
+			params[segment[1:]] = pathSegments[i]
+			continue
+		}
+		if segment != pathSegments[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// routeScore ranks a pattern matching a path with params: the more literal segments, the more specific the pattern.
+func routeScore(pattern string, params map[string]string) int {
+	return len(routeSegments(pattern)) - len(params)
+}
+
+func routeSegments(route string) []string {
+	route = strings.Trim(route, "/")
+	if route == "" {
+		return nil
+	}
+	return strings.Split(route, "/")
+}
@@ -0,0 +1,31 @@
+package soajsgo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchRoute(t *testing.T) {
+	tt := []struct {
+		name           string
+		pattern        string
+		path           string
+		expectedParams map[string]string
+		expectedMatch  bool
+	}{
+		{name: "root", pattern: "/", path: "/", expectedParams: map[string]string{}, expectedMatch: true},
+		{name: "static", pattern: "/users", path: "/users/", expectedParams: map[string]string{}, expectedMatch: true},
+		{name: "param", pattern: "/users/:id/groups", path: "/users/42/groups", expectedParams: map[string]string{"id": "42"}, expectedMatch: true},
+		{name: "different segment", pattern: "/users/:id/groups", path: "/users/42/roles", expectedMatch: false},
+		{name: "different length", pattern: "/users/:id", path: "/users", expectedMatch: false},
+		{name: "empty param", pattern: "/users/:id/groups", path: "/users//groups", expectedMatch: false},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			params, ok := matchRoute(tc.pattern, tc.path)
+			assert.Equal(t, tc.expectedMatch, ok)
+			assert.Equal(t, tc.expectedParams, params)
+		})
+	}
+}
@@ -0,0 +1,319 @@
+package soajsgo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// defaultShutdownTimeout is how long Run waits for in-flight requests to drain.
+const defaultShutdownTimeout = 10 * time.Second
+
+type (
+	// RunOption configures Run.
+	RunOption func(*runOptions)
+
+	runOptions struct {
+		shutdownTimeout   time.Duration
+		registryOptions   []RegistryOption
+		maintenanceRoutes map[string]http.Handler
+		signals           []os.Signal
+		server            func(*http.Server)
+		middlewareOptions []MiddlewareOption
+		prerequisites     PrerequisitesPolicy
+	}
+)
+
+// WithShutdownTimeout sets how long in-flight requests are allowed to drain on shutdown. Default is 10 seconds.
+func WithShutdownTimeout(d time.Duration) RunOption {
+	return func(o *runOptions) {
+		o.shutdownTimeout = d
+	}
+}
+
+// WithRegistryOptions passes options to the registry created by Run.
+func WithRegistryOptions(opts ...RegistryOption) RunOption {
+	return func(o *runOptions) {
+		o.registryOptions = append(o.registryOptions, opts...)
+	}
+}
+
+// WithMiddlewareOptions passes options to the middleware serving the handler, e.g. WithSession or WithACL.
+func WithMiddlewareOptions(opts ...MiddlewareOption) RunOption {
+	return func(o *runOptions) {
+		o.middlewareOptions = append(o.middlewareOptions, opts...)
+	}
+}
+
+// WithMaintenanceRoute adds a route served on the maintenance port.
+func WithMaintenanceRoute(route string, h http.Handler) RunOption {
+	return func(o *runOptions) {
+		o.maintenanceRoutes[route] = h
+	}
+}
+
+// WithSignals replaces the signals that trigger the shutdown. Default is SIGINT and SIGTERM.
+func WithSignals(sig ...os.Signal) RunOption {
+	return func(o *runOptions) {
+		o.signals = sig
+	}
+}
+
+// WithServer lets the caller tune the http servers, e.g. their timeouts, before they start.
+func WithServer(fn func(*http.Server)) RunOption {
+	return func(o *runOptions) {
+		o.server = fn
+	}
+}
+
+// Run validates the config, checks its prerequisites against the cgroup limits, see WithPrerequisitesPolicy, creates
+// the registry, registers the service when deployed manually, then serves handler behind Middleware on
+// Config.ServicePort and the maintenance routes on the maintenance port. It refuses to run when SOAJS_DEV_MODE is
+// enabled in a refused production environment or its fixture cannot be loaded.
+// Run blocks until ctx is done, a shutdown signal is received or a server fails. It then drains in-flight requests,
+// stops the registry auto reload and unregisters the service.
+func Run(ctx context.Context, config Config, handler http.Handler, opts ...RunOption) error {
+	o := runOptions{
+		shutdownTimeout:   defaultShutdownTimeout,
+		maintenanceRoutes: make(map[string]http.Handler),
+		signals:           []os.Signal{syscall.SIGINT, syscall.SIGTERM},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	prerequisitesErr, err := o.checkPrerequisites(config)
+	if err != nil {
+		return err
+	}
+	var mo middlewareOptions
+	for _, opt := range o.middlewareOptions {
+		opt(&mo)
+	}
+	dev, err := devMode(mo.devStore, mo.devRefusedEnvs)
+	if err != nil {
+		return err
+	}
+	if dev != nil {
+		// the store is loaded once, the middleware then uses it
+		o.middlewareOptions = append(o.middlewareOptions, WithDevStore(dev))
+	}
+	addr, err := controllerAddress(o.registryOptions)
+	if err != nil {
+		return fmt.Errorf("could not init registry api path: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, o.signals...)
+	defer stop()
+	reloadCtx, stopReload := context.WithCancel(ctx)
+	defer stopReload()
+
+	reg, err := NewFromConfig(reloadCtx, config, o.registryOptions...)
+	if err != nil {
+		return err
+	}
+	if prerequisitesErr != nil {
+		reg.Logger().Warn(prerequisitesErr.Error())
+	}
+	if dev != nil {
+		if _, err := devModeEnabled(mo.devRefusedEnvs, reg.env()); err != nil {
+			stopReload()
+			return errors.Join(err, o.unregister(ctx, reg, config, addr))
+		}
+	}
+
+	maintenance := NewMaintenanceHandler(reg, config)
+	for route, h := range o.maintenanceRoutes {
+		maintenance.Handle(route, h)
+	}
+	service := reg.MiddlewareWith(append([]MiddlewareOption{
+		WithRequestTimeout(config.requestTimeout(), config.RequestTimeoutRenewal),
+		WithSchema(config.Schema),
+	}, o.middlewareOptions...)...)(handler)
+	servers := []*http.Server{}
+	maintenancePort := config.maintenancePort(reg)
+	if config.Maintenance.Port.Type == maintenancePortInherit || maintenancePort == config.ServicePort {
+		servers = append(servers, newServer(config.ServicePort, maintenance.Wrap(service)))
+	} else {
+		servers = append(servers,
+			newServer(config.ServicePort, service),
+			newServer(maintenancePort, maintenance),
+		)
+	}
+
+	errs, err := serve(servers, o)
+	if err != nil {
+		stopReload()
+		return errors.Join(err, o.unregister(ctx, reg, config, addr))
+	}
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+	case runErr = <-errs:
+	}
+
+	shutdownErr := shutdown(servers, o.shutdownTimeout)
+	stopReload()
+	return errors.Join(runErr, shutdownErr, o.unregister(ctx, reg, config, addr))
+}
+
+// RunDaemon runs a daemon the way Run runs a service: it validates the config, whose type must be daemon, checks its
+// prerequisites, creates the registry, registers the daemon when deployed manually and runs the jobs of the daemon
+// group named by SOAJS_DAEMON_GRP_CONF, see Daemon.Run. The maintenance routes, with the daemon status on
+// DaemonStatusRoute, are served on the maintenance port. RunDaemon blocks until ctx is done, a shutdown signal is
+// received, the daemon fails or the maintenance server fails. It then waits for the running jobs, stops the registry
+// auto reload and unregisters the daemon.
+func RunDaemon(ctx context.Context, config Config, jobs map[string]JobFunc, opts ...RunOption) error {
+	o := runOptions{
+		shutdownTimeout:   defaultShutdownTimeout,
+		maintenanceRoutes: make(map[string]http.Handler),
+		signals:           []os.Signal{syscall.SIGINT, syscall.SIGTERM},
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	if config.Type != ServiceTypeDaemon {
+		return fmt.Errorf("could not run daemon: config type is %s, expected %s", config.Type, ServiceTypeDaemon)
+	}
+	prerequisitesErr, err := o.checkPrerequisites(config)
+	if err != nil {
+		return err
+	}
+	group, err := daemonGroup()
+	if err != nil {
+		return err
+	}
+	addr, err := controllerAddress(o.registryOptions)
+	if err != nil {
+		return fmt.Errorf("could not init registry api path: %v", err)
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, o.signals...)
+	defer stop()
+	reloadCtx, stopReload := context.WithCancel(ctx)
+	defer stopReload()
+
+	reg, err := NewFromConfig(reloadCtx, config, o.registryOptions...)
+	if err != nil {
+		return err
+	}
+	if prerequisitesErr != nil {
+		reg.Logger().Warn(prerequisitesErr.Error())
+	}
+	daemon := NewDaemon(reg, group, jobs)
+	maintenance := NewMaintenanceHandler(reg, config)
+	maintenance.Handle(DaemonStatusRoute, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		maintenance.WriteResponse(w, r, true, daemon.Status())
+	}))
+	for route, h := range o.maintenanceRoutes {
+		maintenance.Handle(route, h)
+	}
+	servers := []*http.Server{newServer(config.maintenancePort(reg), maintenance)}
+	errs, err := serve(servers, o)
+	if err != nil {
+		stopReload()
+		return errors.Join(err, o.unregister(ctx, reg, config, addr))
+	}
+
+	daemonCtx, stopDaemon := context.WithCancel(ctx)
+	defer stopDaemon()
+	daemonErr := make(chan error, 1)
+	go func() {
+		daemonErr <- daemon.Run(daemonCtx)
+	}()
+
+	var runErr error
+	select {
+	case <-ctx.Done():
+		stopDaemon()
+		runErr = <-daemonErr
+	case runErr = <-daemonErr:
+	case runErr = <-errs:
+		stopDaemon()
+		runErr = errors.Join(runErr, <-daemonErr)
+	}
+
+	shutdownErr := shutdown(servers, o.shutdownTimeout)
+	stopReload()
+	return errors.Join(runErr, shutdownErr, o.unregister(ctx, reg, config, addr))
+}
+
+// checkPrerequisites checks the prerequisites of the config against the cgroup limits following the policy. It
+// returns the unmet prerequisites to warn about, or an error when the policy is PrerequisitesFail.
+func (o runOptions) checkPrerequisites(config Config) (warning, err error) {
+	if o.prerequisites == PrerequisitesIgnore {
+		return nil, nil
+	}
+	if err := checkSystemPrerequisites(config); err != nil {
+		if o.prerequisites == PrerequisitesFail {
+			return nil, err
+		}
+		return err, nil
+	}
+	return nil, nil
+}
+
+// unregister unregisters the service or daemon from the controller when it is deployed manually, waiting at most
+// the shutdown timeout.
+func (o runOptions) unregister(ctx context.Context, reg *Registry, config Config, addr registryPath) error {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), o.shutdownTimeout)
+	defer cancel()
+	if err := reg.manualUndeploy(ctx, config, addr); err != nil {
+		return fmt.Errorf("could not unregister %s: %v", config.Type, err)
+	}
+	return nil
+}
+
+// serve starts the servers, tuned by the server option. The returned channel receives the failures of the servers.
+func serve(servers []*http.Server, o runOptions) (<-chan error, error) {
+	errs := make(chan error, len(servers))
+	for _, srv := range servers {
+		if o.server != nil {
+			o.server(srv)
+		}
+		ln, err := net.Listen("tcp", srv.Addr)
+		if err != nil {
+			_ = shutdown(servers, o.shutdownTimeout)
+			return nil, fmt.Errorf("could not listen on %s: %v", srv.Addr, err)
+		}
+		go func(srv *http.Server, ln net.Listener) {
+			if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				errs <- fmt.Errorf("server on %s failed: %v", srv.Addr, err)
+			}
+		}(srv, ln)
+	}
+	return errs, nil
+}
+
+func newServer(port int, h http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              fmt.Sprintf(":%d", port),
+		Handler:           h,
+		ReadHeaderTimeout: 15 * time.Second,
+		IdleTimeout:       60 * time.Second,
+	}
+}
+
+func shutdown(servers []*http.Server, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	var err error
+	for _, srv := range servers {
+		if e := srv.Shutdown(ctx); e != nil {
+			err = errors.Join(err, fmt.Errorf("could not shut down server on %s: %v", srv.Addr, e))
+		}
+	}
+	return err
+}
@@ -0,0 +1,285 @@
+package soajsgo
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func freePort(t *testing.T) int {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer ln.Close()
+	return ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestRun(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls []string
+	)
+	controller := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls = append(calls, r.URL.Path)
+		mu.Unlock()
+		_, _ = w.Write([]byte(`{"result":true,"data":{"name":"test","environment":"dev","serviceConfig":{"session":{"secret":"s","saveUninitialized":true}}}}`))
+	}))
+	defer controller.Close()
+	t.Setenv(EnvRegistryAPIAddress, strings.TrimPrefix(controller.URL, "http://"))
+	t.Setenv(EnvSoajsEnv, "dev")
+	t.Setenv(EnvDeployManual, "true")
+
+	servicePort, maintPort := freePort(t), freePort(t)
+	config := Config{
+		ServiceName:    "test",
+		ServiceGroup:   "group",
+		ServicePort:    servicePort,
+		Type:           "service",
+		ServiceVersion: "1",
+		Maintenance: maintenance{
+			Port:      maintenancePort{Type: maintenancePortCustom, Value: maintPort},
+			Readiness: "/ready",
+		},
+	}
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+
+	store := NewMemoryStore()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- Run(ctx, config, handler, WithShutdownTimeout(time.Second), WithMiddlewareOptions(WithSession(store)))
+	}()
+
+	get := func(port int, path string) (*http.Response, error) {
+		return http.Get(fmt.Sprintf("http://127.0.0.1:%d%s", port, path))
+	}
+	require.Eventually(t, func() bool {
+		res, err := get(servicePort, "/")
+		if err != nil {
+			return false
+		}
+		defer res.Body.Close()
+		return res.StatusCode == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, 1, store.Len(), "session middleware option must apply")
+
+	res, err := get(maintPort, "/ready")
+	require.NoError(t, err)
+	var body maintenanceResponse
+	require.NoError(t, json.NewDecoder(res.Body).Decode(&body))
+	require.NoError(t, res.Body.Close())
+	assert.True(t, body.Result)
+	assert.Equal(t, serviceInfo{ServiceName: "TEST", Type: "rest", Route: "/ready"}, body.Service)
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after cancellation")
+	}
+	_, err = get(servicePort, "/")
+	assert.Error(t, err)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"/getRegistry", "/register", "/unregister"}, calls)
+}
+
+func TestRun_InvalidConfig(t *testing.T) {
+	err := Run(context.Background(), Config{}, http.NotFoundHandler())
+	assert.EqualError(t, err, "could not find [Type] in your config, type is <required>")
+}
+
+func TestRun_ListenFailure(t *testing.T) {
+	tt := []struct {
+		name string
+		typ  string
+		run  func(config Config) error
+	}{
+		{
+			name: "service",
+			typ:  "service",
+			run: func(config Config) error {
+				return Run(context.Background(), config, http.NotFoundHandler(), WithShutdownTimeout(time.Second))
+			},
+		},
+		{
+			name: "daemon",
+			typ:  ServiceTypeDaemon,
+			run: func(config Config) error {
+				return RunDaemon(context.Background(), config, nil, WithShutdownTimeout(time.Second))
+			},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				mu    sync.Mutex
+				calls []string
+			)
+			controller := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				calls = append(calls, r.URL.Path)
+				mu.Unlock()
+				_, _ = w.Write([]byte(`{"result":true,"data":{"name":"test","environment":"dev"}}`))
+			}))
+			defer controller.Close()
+			t.Setenv(EnvRegistryAPIAddress, strings.TrimPrefix(controller.URL, "http://"))
+			t.Setenv(EnvSoajsEnv, "dev")
+			t.Setenv(EnvDeployManual, "true")
+			t.Setenv(EnvDaemonGroupConf, "group")
+
+			ln, err := net.Listen("tcp", ":0")
+			require.NoError(t, err)
+			defer ln.Close()
+			port := ln.Addr().(*net.TCPAddr).Port
+			config := Config{
+				ServiceName:    "test",
+				ServiceGroup:   "group",
+				ServicePort:    port,
+				Type:           tc.typ,
+				ServiceVersion: "1",
+				Maintenance:    maintenance{Port: maintenancePort{Type: maintenancePortInherit}, Readiness: "/ready"},
+			}
+			err = tc.run(config)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), fmt.Sprintf("could not listen on :%d", port))
+
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, []string{"/getRegistry", "/register", "/unregister"}, calls)
+		})
+	}
+}
+
+func TestRunDaemon(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls []string
+	)
+	controller := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls = append(calls, r.URL.Path)
+		mu.Unlock()
+		if r.URL.Path == "/daemonGroupConf" {
+			WriteData(w, DaemonGroupConfig{Status: 1, Type: "interval", Interval: 3600000, Jobs: map[string]DaemonJob{"job": {}}})
+			return
+		}
+		if r.URL.Path == "/getRegistry" {
+			assert.Equal(t, ServiceTypeDaemon, r.URL.Query().Get("type"))
+		}
+		_, _ = w.Write([]byte(`{"result":true,"data":{"name":"cleaner","environment":"dev"}}`))
+	}))
+	defer controller.Close()
+	t.Setenv(EnvRegistryAPIAddress, strings.TrimPrefix(controller.URL, "http://"))
+	t.Setenv(EnvSoajsEnv, "dev")
+	t.Setenv(EnvDeployManual, "true")
+	t.Setenv(EnvDaemonGroupConf, "grp")
+
+	port := freePort(t)
+	config := Config{
+		ServiceName:    "cleaner",
+		ServiceGroup:   "group",
+		ServicePort:    port,
+		Type:           ServiceTypeDaemon,
+		ServiceVersion: "1",
+		Maintenance: maintenance{
+			Port:      maintenancePort{Type: maintenancePortInherit},
+			Readiness: "/ready",
+		},
+	}
+	ran := make(chan struct{}, 1)
+	jobs := map[string]JobFunc{"job": func(ctx context.Context) error {
+		ran <- struct{}{}
+		return nil
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- RunDaemon(ctx, config, jobs, WithShutdownTimeout(time.Second))
+	}()
+	select {
+	case <-ran:
+	case <-time.After(5 * time.Second):
+		t.Fatal("daemon job did not run")
+	}
+
+	var body struct {
+		Result  bool         `json:"result"`
+		Service serviceInfo  `json:"service"`
+		Data    DaemonStatus `json:"data"`
+	}
+	require.Eventually(t, func() bool {
+		res, err := http.Get(fmt.Sprintf("http://127.0.0.1:%d%s", port, DaemonStatusRoute))
+		if err != nil {
+			return false
+		}
+		defer res.Body.Close()
+		return json.NewDecoder(res.Body).Decode(&body) == nil && body.Data.Jobs["job"].Runs == 1
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.True(t, body.Result)
+	assert.Equal(t, serviceInfo{ServiceName: "CLEANER", Type: "daemon", Route: DaemonStatusRoute}, body.Service)
+	assert.Equal(t, "grp", body.Data.Group)
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("RunDaemon did not return after cancellation")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"/getRegistry", "/register", "/daemonGroupConf", "/unregister"}, calls)
+}
+
+func TestRunDaemon_Errors(t *testing.T) {
+	config := Config{
+		ServiceName:    "cleaner",
+		ServiceGroup:   "group",
+		ServicePort:    4000,
+		Type:           "service",
+		ServiceVersion: "1",
+		Maintenance:    maintenance{Port: maintenancePort{Type: maintenancePortInherit}, Readiness: "/ready"},
+	}
+	err := RunDaemon(context.Background(), config, nil)
+	assert.EqualError(t, err, "could not run daemon: config type is service, expected daemon")
+
+	config.Type = ServiceTypeDaemon
+	t.Setenv(EnvDaemonGroupConf, "")
+	err = RunDaemon(context.Background(), config, nil)
+	assert.EqualError(t, err, "could not find environment variable SOAJS_DAEMON_GRP_CONF")
+}
+
+func TestRun_PrerequisitesNotMet(t *testing.T) {
+	defer func(old string) { cgroupRoot = old }(cgroupRoot)
+	cgroupRoot = writeCgroup(t, map[string]string{"cgroup.controllers": "", "memory.max": "268435456"})
+	config := Config{
+		ServiceName:    "test",
+		ServiceGroup:   "group",
+		ServicePort:    4000,
+		Type:           "service",
+		ServiceVersion: "1",
+		Maintenance:    maintenance{Port: maintenancePort{Type: maintenancePortInherit}, Readiness: "/ready"},
+		Prerequisites:  prerequisites{Memory: "1Gi"},
+	}
+	err := Run(context.Background(), config, http.NotFoundHandler(), WithPrerequisitesPolicy(PrerequisitesFail))
+	assert.EqualError(t, err, "prerequisites not met: memory 1Gi exceeds the limit of 256Mi")
+}
@@ -0,0 +1,487 @@
+package soajsgo
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Input sources of a schema field, the prefix of its source entries, e.g. "query.name".
+const (
+	SourceQuery   = "query"
+	SourceBody    = "body"
+	SourceParams  = "params"
+	SourceHeaders = "headers"
+)
+
+// schemaCommonFields is the key of the common fields, in the schema and in a route.
+const schemaCommonFields = "commonFields"
+
+// DefaultMaxBodySize is the size limit of the request bodies the schema middleware reads, in bytes.
+const DefaultMaxBodySize = 10 << 20
+
+type (
+	// Schema is the IMFV input schema of a service, the schema section of the SOAJS service configuration:
+	// the common fields and the routes by method ("get", "post", "put", "del", "patch", ...). MaxBodySize limits
+	// the size of the request bodies read, DefaultMaxBodySize when zero; it is not part of the configuration.
+	Schema struct {
+		CommonFields map[string]Field
+		Methods      map[string]map[string]Route
+		MaxBodySize  int64
+	}
+
+	// Route is the schema of a route: its API info, the common fields it uses and its own fields.
+	Route struct {
+		APIInfo      APIInfo
+		CommonFields []string
+		Fields       map[string]Field
+	}
+
+	// APIInfo describes a route, as listed by the controller. Access marks the routes requiring a logged in user,
+	// Urac the routes reading the URAC of the user.
+	APIInfo struct {
+		Label     string `json:"l"`
+		Group     string `json:"group"`
+		GroupMain bool   `json:"groupMain,omitempty"`
+		Access    bool   `json:"access,omitempty"`
+		Urac      bool   `json:"urac,omitempty"`
+	}
+
+	// Field is an input of a route. Source lists where the input is read from, in order, e.g. "query.name",
+	// "body.user.name", "params.id" or "headers.x-tenant". Validation is a JSON schema.
+	Field struct {
+		Source     []string               `json:"source"`
+		Required   bool                   `json:"required,omitempty"`
+		Default    interface{}            `json:"default,omitempty"`
+		Validation map[string]interface{} `json:"validation,omitempty"`
+	}
+
+	// inputsKey is the context key of the validated inputs.
+	inputsKey struct{}
+)
+
+// WithSchema validates the inputs of the requests against the schema, see Schema.Middleware. Run applies the
+// schema of the config.
+func WithSchema(s Schema) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.schema = s
+	}
+}
+
+// Middleware binds and validates the inputs of the requests whose route is in the schema. Missing inputs get
+// a copy of their default. A request with missing or invalid inputs is answered with the CodeMissingField and
+// CodeInvalidField errors, with http.StatusRequestEntityTooLarge when its body exceeds MaxBodySize, otherwise the
+// inputs are available with InputsFromContext and BindInputs. Requests to other routes are passed through.
+func (s Schema) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, params, ok := s.route(r.Method, r.URL.Path)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		in := &requestInputs{w: w, r: r, params: params, maxBodySize: s.MaxBodySize}
+		inputs, errs := s.bind(in, route)
+		if in.bodyTooLarge {
+			DefaultErrorCatalog.writeErrors(w, http.StatusRequestEntityTooLarge, errs...)
+			return
+		}
+		if len(errs) > 0 {
+			DefaultErrorCatalog.WriteErrors(w, errs...)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), inputsKey{}, inputs)))
+	})
+}
+
+// InputsFromContext returns the inputs the schema middleware validated, by field name.
+func InputsFromContext(ctx context.Context) (map[string]interface{}, bool) {
+	inputs, ok := ctx.Value(inputsKey{}).(map[string]interface{})
+	return inputs, ok
+}
+
+// BindInputs decodes the validated inputs into v, e.g. a pointer to a struct whose json tags are the field names.
+func BindInputs(ctx context.Context, v interface{}) error {
+	inputs, ok := InputsFromContext(ctx)
+	if !ok {
+		return fmt.Errorf("no validated inputs in context")
+	}
+	b, err := json.Marshal(inputs)
+	if err != nil {
+		return fmt.Errorf("could not encode inputs: %v", err)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("could not bind inputs: %v", err)
+	}
+	return nil
+}
+
+// Validate checks that every field has a source and every route only uses existing common fields.
+func (s Schema) Validate() error {
+	for name, f := range s.CommonFields {
+		if err := f.validate(); err != nil {
+			return fmt.Errorf("invalid common field %s: %v", name, err)
+		}
+	}
+	for method, routes := range s.Methods {
+		for path, route := range routes {
+			for _, name := range route.CommonFields {
+				if _, ok := s.CommonFields[name]; !ok {
+					return fmt.Errorf("route %s %s uses unknown common field %s", method, path, name)
+				}
+			}
+			for name, f := range route.Fields {
+				if err := f.validate(); err != nil {
+					return fmt.Errorf("invalid field %s of route %s %s: %v", name, method, path, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// UnmarshalJSON decodes the schema section of the service configuration.
+func (s *Schema) UnmarshalJSON(b []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	*s = Schema{}
+	for key, value := range raw {
+		if key == schemaCommonFields {
+			if err := json.Unmarshal(value, &s.CommonFields); err != nil {
+				return fmt.Errorf("invalid schema common fields: %v", err)
+			}
+			continue
+		}
+		var routes map[string]Route
+		if err := json.Unmarshal(value, &routes); err != nil {
+			return fmt.Errorf("invalid schema of method %s: %v", key, err)
+		}
+		if s.Methods == nil {
+			s.Methods = make(map[string]map[string]Route)
+		}
+		s.Methods[strings.ToLower(key)] = routes
+	}
+	return nil
+}
+
+// MarshalJSON encodes the schema as the schema section of the service configuration.
+func (s Schema) MarshalJSON() ([]byte, error) {
+	out := make(map[string]interface{}, len(s.Methods)+1)
+	if len(s.CommonFields) > 0 {
+		out[schemaCommonFields] = s.CommonFields
+	}
+	for method, routes := range s.Methods {
+		out[method] = routes
+	}
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON decodes a route, where the _apiInfo and commonFields keys sit next to the fields.
+func (r *Route) UnmarshalJSON(b []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	*r = Route{}
+	for key, value := range raw {
+		var err error
+		switch key {
+		case "_apiInfo":
+			err = json.Unmarshal(value, &r.APIInfo)
+		case schemaCommonFields:
+			err = json.Unmarshal(value, &r.CommonFields)
+		default:
+			var f Field
+			if err = json.Unmarshal(value, &f); err == nil {
+				if r.Fields == nil {
+					r.Fields = make(map[string]Field)
+				}
+				r.Fields[key] = f
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("invalid route entry %s: %v", key, err)
+		}
+	}
+	return nil
+}
+
+// MarshalJSON encodes a route with the _apiInfo and commonFields keys next to the fields.
+func (r Route) MarshalJSON() ([]byte, error) {
+	out := make(map[string]interface{}, len(r.Fields)+2)
+	for name, f := range r.Fields {
+		out[name] = f
+	}
+	out["_apiInfo"] = r.APIInfo
+	if len(r.CommonFields) > 0 {
+		out[schemaCommonFields] = r.CommonFields
+	}
+	return json.Marshal(out)
+}
+
+func (f Field) validate() error {
+	if len(f.Source) == 0 {
+		return fmt.Errorf("source is required")
+	}
+	for _, source := range f.Source {
+		switch prefix, name, _ := strings.Cut(source, "."); prefix {
+		case SourceQuery, SourceBody, SourceParams, SourceHeaders:
+			if name == "" {
+				return fmt.Errorf("source %s has no name", source)
+			}
+		default:
+			return fmt.Errorf("unknown source %s", source)
+		}
+	}
+	return checkSchema(f.Validation)
+}
+
+// schemaMethod returns the keys of the method in the schema. SOAJS names the DELETE routes "del".
+func schemaMethod(method string) []string {
+	m := strings.ToLower(method)
+	if m == "delete" {
+		return []string{"del", m}
+	}
+	return []string{m}
+}
+
+// route finds the route of the request. Among the matching patterns, the most specific one wins, see routeScore.
+func (s Schema) route(method, path string) (Route, map[string]string, bool) {
+	var (
+		best        Route
+		bestParams  map[string]string
+		bestPattern string
+		bestScore   = -1
+	)
+	for _, m := range schemaMethod(method) {
+		for pattern, route := range s.Methods[m] {
+			params, ok := matchRoute(pattern, path)
+			if !ok {
+				continue
+			}
+			score := routeScore(pattern, params)
+			if score > bestScore || (score == bestScore && pattern < bestPattern) {
+				best, bestParams, bestPattern, bestScore = route, params, pattern, score
+			}
+		}
+	}
+	return best, bestParams, bestScore >= 0
+}
+
+// fields returns the fields of the route, including the common fields it uses.
+func (s Schema) fields(route Route) map[string]Field {
+	fields := make(map[string]Field, len(route.Fields)+len(route.CommonFields))
+	for _, name := range route.CommonFields {
+		if f, ok := s.CommonFields[name]; ok {
+			fields[name] = f
+		}
+	}
+	for name, f := range route.Fields {
+		fields[name] = f
+	}
+	return fields
+}
+
+// bind reads, coerces, defaults and validates the fields of the route.
+func (s Schema) bind(in *requestInputs, route Route) (map[string]interface{}, []Error) {
+	fields := s.fields(route)
+	names := make([]string, 0, len(fields))
+	for name := range fields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	inputs := make(map[string]interface{}, len(fields))
+	var errs []Error
+	for _, name := range names {
+		f := fields[name]
+		v, found, err := in.lookup(f)
+		if err != nil {
+			errs = append(errs, DefaultErrorCatalog.Error(CodeInvalidField, name, name, err.Error()))
+			continue
+		}
+		if !found {
+			if f.Default == nil {
+				if f.Required {
+					errs = append(errs, DefaultErrorCatalog.Error(CodeMissingField, name))
+				}
+				continue
+			}
+			// handlers may modify their inputs, the default of the field must not change
+			if v, err = copyValue(f.Default); err != nil {
+				errs = append(errs, DefaultErrorCatalog.Error(CodeInvalidField, name, name, err.Error()))
+				continue
+			}
+		}
+		if err := validateValue(name, v, f.Validation); err != nil {
+			errs = append(errs, DefaultErrorCatalog.Error(CodeInvalidField, name, err.path, err.reason))
+			continue
+		}
+		inputs[name] = v
+	}
+	return inputs, errs
+}
+
+// requestInputs reads the sources of a request. The body is read once, on first use, up to maxBodySize, and
+// restored for the handler.
+type requestInputs struct {
+	w           http.ResponseWriter
+	r           *http.Request
+	params      map[string]string
+	maxBodySize int64
+
+	bodyRead     bool
+	body         interface{}
+	form         url.Values
+	bodyErr      error
+	bodyTooLarge bool
+}
+
+// lookup returns the value of the first source of the field the request has, with the string inputs coerced
+// to the type of the validation.
+func (in *requestInputs) lookup(f Field) (interface{}, bool, error) {
+	for _, source := range f.Source {
+		prefix, name, _ := strings.Cut(source, ".")
+		var values []string
+		switch prefix {
+		case SourceQuery:
+			values = in.r.URL.Query()[name]
+		case SourceParams:
+			if v, ok := in.params[name]; ok {
+				values = []string{v}
+			}
+		case SourceHeaders:
+			values = in.r.Header.Values(name)
+		case SourceBody:
+			if err := in.readBody(); err != nil {
+				return nil, false, err
+			}
+			if in.form != nil {
+				values = in.form[name]
+				break
+			}
+			if v, ok := lookupPath(in.body, name); ok {
+				return v, true, nil
+			}
+		}
+		if len(values) > 0 {
+			return coerce(values, f.Validation), true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+func (in *requestInputs) readBody() error {
+	if in.bodyRead {
+		return in.bodyErr
+	}
+	in.bodyRead = true
+	if in.r.Body == nil {
+		return nil
+	}
+	limit := in.maxBodySize
+	if limit <= 0 {
+		limit = DefaultMaxBodySize
+	}
+	b, err := io.ReadAll(http.MaxBytesReader(in.w, in.r.Body, limit))
+	_ = in.r.Body.Close()
+	in.r.Body = io.NopCloser(bytes.NewReader(b))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		in.bodyTooLarge = errors.As(err, &tooLarge)
+		in.bodyErr = fmt.Errorf("could not read body: %v", err)
+		return in.bodyErr
+	}
+	if len(bytes.TrimSpace(b)) == 0 {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(in.r.Header.Get("Content-Type"))
+	if mediaType == "application/x-www-form-urlencoded" {
+		in.form, in.bodyErr = url.ParseQuery(string(b))
+	} else {
+		in.bodyErr = json.Unmarshal(b, &in.body)
+	}
+	if in.bodyErr != nil {
+		in.bodyErr = fmt.Errorf("could not parse body: %v", in.bodyErr)
+	}
+	return in.bodyErr
+}
+
+// copyValue returns a deep copy of a value, decoded from its JSON encoding.
+func copyValue(v interface{}) (interface{}, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("could not copy default: %v", err)
+	}
+	var out interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, fmt.Errorf("could not copy default: %v", err)
+	}
+	return out, nil
+}
+
+// lookupPath returns the value at the dotted path of a decoded JSON document.
+func lookupPath(doc interface{}, path string) (interface{}, bool) {
+	for _, key := range strings.Split(path, ".") {
+		m, ok := doc.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if doc, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return doc, true
+}
+
+// coerce converts the string values of the query, params, headers or form to the type of the validation.
+func coerce(values []string, validation map[string]interface{}) interface{} {
+	types := schemaTypes(validation)
+	if types["array"] {
+		items, _ := validation["items"].(map[string]interface{})
+		out := make([]interface{}, 0, len(values))
+		for _, v := range values {
+			out = append(out, coerceString(v, schemaTypes(items)))
+		}
+		return out
+	}
+	return coerceString(values[0], types)
+}
+
+func coerceString(v string, types map[string]bool) interface{} {
+	if types["string"] || len(types) == 0 {
+		return v
+	}
+	if types["integer"] {
+		if i, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return float64(i)
+		}
+	}
+	if types["number"] {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			return f
+		}
+	}
+	if types["boolean"] {
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	if types["object"] || types["array"] {
+		var doc interface{}
+		if err := json.Unmarshal([]byte(v), &doc); err == nil {
+			return doc
+		}
+	}
+	return v
+}
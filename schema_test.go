@@ -0,0 +1,309 @@
+package soajsgo
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSchema = `{
+	"commonFields": {
+		"id": {"source": ["params.id"], "required": true, "validation": {"type": "string", "pattern": "^[0-9a-f]+$"}}
+	},
+	"get": {
+		"/users": {
+			"_apiInfo": {"l": "List users", "group": "User", "groupMain": true},
+			"limit": {"source": ["query.limit"], "default": 10, "validation": {"type": "integer", "minimum": 1, "maximum": 100}},
+			"active": {"source": ["query.active"], "validation": {"type": "boolean"}},
+			"tags": {"source": ["query.tag"], "validation": {"type": "array", "items": {"type": "string"}}}
+		},
+		"/users/:id": {
+			"_apiInfo": {"l": "Get user", "group": "User"},
+			"commonFields": ["id"],
+			"tenant": {"source": ["headers.x-tenant"], "required": true, "validation": {"type": "string"}}
+		},
+		"/users/me": {
+			"_apiInfo": {"l": "Get current user", "group": "User"}
+		}
+	},
+	"post": {
+		"/users": {
+			"_apiInfo": {"l": "Add user", "group": "User"},
+			"name": {"source": ["body.name"], "required": true, "validation": {"type": "string", "minLength": 2}},
+			"email": {"source": ["body.contact.email", "query.email"], "validation": {"type": "string", "format": "email"}},
+			"age": {"source": ["body.age"], "validation": {"type": "integer"}}
+		}
+	},
+	"del": {
+		"/users/:id": {
+			"_apiInfo": {"l": "Delete user", "group": "User"},
+			"commonFields": ["id"]
+		}
+	}
+}`
+
+func loadTestSchema(t *testing.T) Schema {
+	var s Schema
+	require.NoError(t, json.Unmarshal([]byte(testSchema), &s))
+	return s
+}
+
+func TestSchemaJSON(t *testing.T) {
+	s := loadTestSchema(t)
+	require.NoError(t, s.Validate())
+	assert.Contains(t, s.CommonFields, "id")
+	assert.Len(t, s.Methods, 3)
+	route := s.Methods["get"]["/users/:id"]
+	assert.Equal(t, APIInfo{Label: "Get user", Group: "User"}, route.APIInfo)
+	assert.Equal(t, []string{"id"}, route.CommonFields)
+	assert.Equal(t, []string{"headers.x-tenant"}, route.Fields["tenant"].Source)
+	assert.True(t, s.Methods["get"]["/users"].APIInfo.GroupMain)
+
+	b, err := json.Marshal(s)
+	require.NoError(t, err)
+	var again Schema
+	require.NoError(t, json.Unmarshal(b, &again))
+	assert.Equal(t, s, again)
+
+	var config Config
+	require.NoError(t, json.Unmarshal([]byte(`{"schema":`+testSchema+`}`), &config))
+	assert.Equal(t, s, config.Schema)
+}
+
+func TestSchemaValidate(t *testing.T) {
+	tt := []struct {
+		name        string
+		schema      string
+		expectedErr string
+	}{
+		{
+			name:        "missing source",
+			schema:      `{"get":{"/a":{"f":{"required":true}}}}`,
+			expectedErr: "invalid field f of route get /a: source is required",
+		},
+		{
+			name:        "unknown source",
+			schema:      `{"get":{"/a":{"f":{"source":["cookie.f"]}}}}`,
+			expectedErr: "invalid field f of route get /a: unknown source cookie.f",
+		},
+		{
+			name:        "source without name",
+			schema:      `{"commonFields":{"f":{"source":["query"]}}}`,
+			expectedErr: "invalid common field f: source query has no name",
+		},
+		{
+			name:        "invalid pattern",
+			schema:      `{"get":{"/a":{"f":{"source":["query.f"],"validation":{"type":"object","properties":{"p":{"pattern":"("}}}}}}}`,
+			expectedErr: "invalid field f of route get /a: invalid pattern (: error parsing regexp: missing closing ): `(`",
+		},
+		{
+			name:        "unknown common field",
+			schema:      `{"get":{"/a":{"commonFields":["f"]}}}`,
+			expectedErr: "route get /a uses unknown common field f",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var s Schema
+			require.NoError(t, json.Unmarshal([]byte(tc.schema), &s))
+			assert.EqualError(t, s.Validate(), tc.expectedErr)
+		})
+	}
+}
+
+func TestSchemaMiddleware(t *testing.T) {
+	s := loadTestSchema(t)
+	tt := []struct {
+		name           string
+		method         string
+		target         string
+		contentType    string
+		body           string
+		headers        map[string]string
+		expectedStatus int
+		expectedInputs map[string]interface{}
+		expectedBody   string
+	}{
+		{
+			name:           "query with default",
+			method:         http.MethodGet,
+			target:         "/users?active=true&tag=a&tag=b",
+			expectedStatus: http.StatusOK,
+			expectedInputs: map[string]interface{}{"limit": float64(10), "active": true, "tags": []interface{}{"a", "b"}},
+		},
+		{
+			name:           "coerced query",
+			method:         http.MethodGet,
+			target:         "/users?limit=20",
+			expectedStatus: http.StatusOK,
+			expectedInputs: map[string]interface{}{"limit": float64(20)},
+		},
+		{
+			name:           "invalid query",
+			method:         http.MethodGet,
+			target:         "/users?limit=abc",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"result":false,"errors":{"codes":[173],"details":[{"code":173,"message":"Validation failed for field: limit -> The parameter 'limit' failed due to: must be of type integer"}]}}`,
+		},
+		{
+			name:           "params and headers",
+			method:         http.MethodGet,
+			target:         "/users/a1",
+			headers:        map[string]string{"X-Tenant": "TNT"},
+			expectedStatus: http.StatusOK,
+			expectedInputs: map[string]interface{}{"id": "a1", "tenant": "TNT"},
+		},
+		{
+			name:           "literal route wins",
+			method:         http.MethodGet,
+			target:         "/users/me",
+			expectedStatus: http.StatusOK,
+			expectedInputs: map[string]interface{}{},
+		},
+		{
+			name:           "missing and invalid fields",
+			method:         http.MethodGet,
+			target:         "/users/XYZ",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody: `{"result":false,"errors":{"codes":[173,172],"details":[` +
+				`{"code":173,"message":"Validation failed for field: id -> The parameter 'id' failed due to: must match pattern ^[0-9a-f]+$"},` +
+				`{"code":172,"message":"Missing required field: tenant"}]}}`,
+		},
+		{
+			name:           "json body",
+			method:         http.MethodPost,
+			target:         "/users",
+			contentType:    "application/json",
+			body:           `{"name":"john","age":30,"contact":{"email":"john@example.com"}}`,
+			expectedStatus: http.StatusOK,
+			expectedInputs: map[string]interface{}{"name": "john", "age": float64(30), "email": "john@example.com"},
+		},
+		{
+			name:           "second source",
+			method:         http.MethodPost,
+			target:         "/users?email=john@example.com",
+			contentType:    "application/json",
+			body:           `{"name":"john"}`,
+			expectedStatus: http.StatusOK,
+			expectedInputs: map[string]interface{}{"name": "john", "email": "john@example.com"},
+		},
+		{
+			name:           "form body",
+			method:         http.MethodPost,
+			target:         "/users",
+			contentType:    "application/x-www-form-urlencoded",
+			body:           "name=john&age=30",
+			expectedStatus: http.StatusOK,
+			expectedInputs: map[string]interface{}{"name": "john", "age": float64(30)},
+		},
+		{
+			name:           "invalid json body",
+			method:         http.MethodPost,
+			target:         "/users",
+			contentType:    "application/json",
+			body:           `{`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody: `{"result":false,"errors":{"codes":[173,173,173],"details":[` +
+				`{"code":173,"message":"Validation failed for field: age -> The parameter 'age' failed due to: could not parse body: unexpected end of JSON input"},` +
+				`{"code":173,"message":"Validation failed for field: email -> The parameter 'email' failed due to: could not parse body: unexpected end of JSON input"},` +
+				`{"code":173,"message":"Validation failed for field: name -> The parameter 'name' failed due to: could not parse body: unexpected end of JSON input"}]}}`,
+		},
+		{
+			name:           "delete route",
+			method:         http.MethodDelete,
+			target:         "/users/ff",
+			expectedStatus: http.StatusOK,
+			expectedInputs: map[string]interface{}{"id": "ff"},
+		},
+		{
+			name:           "route not in schema",
+			method:         http.MethodPut,
+			target:         "/users",
+			expectedStatus: http.StatusNoContent,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				inputs, ok := InputsFromContext(r.Context())
+				if !ok {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+				assert.Equal(t, tc.expectedInputs, inputs)
+				body, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+				assert.Equal(t, tc.body, string(body))
+			}))
+			req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			for k, v := range tc.headers {
+				req.Header.Set(k, v)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			if tc.expectedBody != "" {
+				assert.Equal(t, tc.expectedBody+"\n", rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestSchemaMiddleware_DefaultCopy(t *testing.T) {
+	var s Schema
+	require.NoError(t, json.Unmarshal([]byte(`{"get":{"/a":{"f":{"source":["query.f"],"default":{"tags":["a"]}}}}}`), &s))
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		inputs, ok := InputsFromContext(r.Context())
+		require.True(t, ok)
+		f := inputs["f"].(map[string]interface{})
+		assert.Equal(t, []interface{}{"a"}, f["tags"])
+		f["tags"] = append(f["tags"].([]interface{}), "b")
+		f["other"] = true
+	}))
+	for i := 0; i < 2; i++ {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/a", nil))
+	}
+	assert.Equal(t, map[string]interface{}{"tags": []interface{}{"a"}}, s.Methods["get"]["/a"].Fields["f"].Default)
+}
+
+func TestSchemaMiddleware_BodyTooLarge(t *testing.T) {
+	s := loadTestSchema(t)
+	s.MaxBodySize = 16
+	handler := s.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("handler must not be called")
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"john","age":30}`))
+	req.Header.Set("Content-Type", "application/json")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	var res Response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, []int{CodeInvalidField, CodeInvalidField, CodeInvalidField}, res.Errors.Codes)
+	assert.Equal(t, "Validation failed for field: age -> The parameter 'age' failed due to: could not read body: http: request body too large",
+		res.Errors.Details[0].Message)
+}
+
+func TestBindInputs(t *testing.T) {
+	var user struct {
+		Name  string `json:"name"`
+		Age   int    `json:"age"`
+		Email string `json:"email"`
+	}
+	handler := (&Registry{}).MiddlewareWith(WithSchema(loadTestSchema(t)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, BindInputs(r.Context(), &user))
+	}))
+	req := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{"name":"john","age":30}`))
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, "john", user.Name)
+	assert.Equal(t, 30, user.Age)
+
+	assert.EqualError(t, BindInputs(req.Context(), &user), "no validated inputs in context")
+}
@@ -0,0 +1,465 @@
+package soajsgo
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultSessionName is the session cookie name when the service config has none.
+	defaultSessionName = "soajsID"
+	// defaultSessionTTL is how long a session lives in the store when its cookie has no max age.
+	defaultSessionTTL = 24 * time.Hour
+	// sessionUnsetKeep keeps a session without data in the store, instead of destroying it.
+	sessionUnsetKeep = "keep"
+	// sessionCoreDB is the core database of the sessions in the registry.
+	sessionCoreDB = "session"
+)
+
+// ErrSessionNotFound is returned by a SessionStore for an unknown or expired session.
+var ErrSessionNotFound = errors.New("session not found")
+
+type (
+	// SessionStore persists the sessions by id. Implementations must be safe for concurrent use.
+	SessionStore interface {
+		// Get returns the data of the session, ErrSessionNotFound when it does not exist or expired.
+		Get(ctx context.Context, id string) (SessionData, error)
+		// Set saves the data of the session, expiring after ttl.
+		Set(ctx context.Context, id string, data SessionData, ttl time.Duration) error
+		// Touch resets the expiry of an unchanged session.
+		Touch(ctx context.Context, id string, ttl time.Duration) error
+		// Destroy deletes the session.
+		Destroy(ctx context.Context, id string) error
+	}
+
+	// SessionData is the data of a multi-tenant session by tenant id. A tenant only sees its own data.
+	SessionData map[string]map[string]interface{}
+
+	// TenantSession is the session of the tenant of a request. It is safe for concurrent use.
+	TenantSession struct {
+		mu        sync.Mutex
+		id        string
+		values    map[string]interface{}
+		modified  bool
+		destroyed bool
+	}
+
+	// MemoryStore is a SessionStore keeping the sessions in memory, e.g. for tests or a single instance.
+	MemoryStore struct {
+		mu       sync.Mutex
+		sessions map[string]memorySession
+	}
+
+	memorySession struct {
+		data    []byte
+		expires time.Time
+	}
+
+	// sessionConfig is the session section of the service config, with its defaults applied.
+	sessionConfig struct {
+		name              string
+		secrets           []string
+		path              string
+		httpOnly          bool
+		secure            bool
+		maxAge            time.Duration
+		resave            bool
+		saveUninitialized bool
+		rolling           bool
+		unset             string
+	}
+
+	// sessionWriter sets the session cookie before the response header is written.
+	sessionWriter struct {
+		http.ResponseWriter
+		once      sync.Once
+		setCookie func() bool
+		sent      bool
+	}
+
+	// sessionKey is the context key of the tenant session.
+	sessionKey struct{}
+)
+
+// WithSession loads the session of every request from the store, following the session section of the service
+// config: cookie name, secret, rolling, resave, saveUninitialized, unset and the cookie path, httpOnly, secure and
+// maxAge. The cookie is signed the way express-session does, so sessions are shared with SOAJS Node.js services.
+// When the session has no secret, the secret of the cookie section is used. Sessions are disabled when neither
+// is set.
+func WithSession(store SessionStore) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.sessionStore = store
+	}
+}
+
+// SessionFromContext returns the session the session middleware loaded for the request.
+func SessionFromContext(ctx context.Context) (*TenantSession, bool) {
+	s, ok := ctx.Value(sessionKey{}).(*TenantSession)
+	return s, ok
+}
+
+// SessionDatabase returns the core database of the sessions, whose Store, Collection, Stringify and ExpireAfter
+// entries configure a persistent SessionStore.
+func (reg *Registry) SessionDatabase() (Database, bool) {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	db, ok := reg.CoreDBs[sessionCoreDB]
+	return db, ok
+}
+
+func (reg *Registry) sessionMiddleware(store SessionStore, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conf := reg.sessionConfig()
+		if len(conf.secrets) == 0 {
+			reg.Logger().Warn("sessions disabled, the service config has no session secret")
+			next.ServeHTTP(w, r)
+			return
+		}
+		ctx := r.Context()
+		var tenant string
+		if data, ok := FromContext(ctx); ok {
+			tenant = data.Tenant.ID
+		}
+		id, data, isNew, err := conf.load(ctx, store, r)
+		if err != nil {
+			reg.Logger().Error("could not load session", "error", err)
+			WriteError(w, CodeSession, err.Error())
+			return
+		}
+		s := &TenantSession{id: id, values: make(map[string]interface{})}
+		for k, v := range data[tenant] {
+			s.values[k] = v
+		}
+		sw := &sessionWriter{ResponseWriter: w}
+		sw.setCookie = func() bool {
+			cookie := conf.cookie(s, isNew)
+			if cookie != nil {
+				http.SetCookie(sw.ResponseWriter, cookie)
+			}
+			return cookie != nil
+		}
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(ctx, sessionKey{}, s)))
+		sw.writeCookie()
+		if err := conf.save(context.WithoutCancel(ctx), store, s, data, tenant, isNew, sw.sent); err != nil {
+			reg.Logger().Error("could not save session", "error", err)
+		}
+	})
+}
+
+// sessionConfig returns the session config of the registry.
+func (reg *Registry) sessionConfig() sessionConfig {
+	reg.mu.RLock()
+	s := reg.ServiceConfig.Session
+	cookieSecret := reg.ServiceConfig.Cookie.Secret
+	reg.mu.RUnlock()
+	conf := sessionConfig{
+		name:              s.Name,
+		path:              s.Cookie.Path,
+		httpOnly:          s.Cookie.HTTPOnly,
+		secure:            s.Cookie.Secure,
+		maxAge:            sessionMaxAge(s.Cookie.MaxAge),
+		resave:            s.Resave,
+		saveUninitialized: s.SaveUninitialized,
+		rolling:           s.Rolling,
+		unset:             s.Unset,
+	}
+	if conf.name == "" {
+		conf.name = defaultSessionName
+	}
+	if conf.path == "" {
+		conf.path = "/"
+	}
+	for _, secret := range []string{s.Secret, cookieSecret} {
+		if secret != "" {
+			conf.secrets = append(conf.secrets, secret)
+		}
+	}
+	return conf
+}
+
+// sessionMaxAge converts the max age of the session cookie, in milliseconds.
+func sessionMaxAge(v interface{}) time.Duration {
+	ms, ok := normalize(v).(float64)
+	if !ok || ms <= 0 {
+		return 0
+	}
+	return time.Duration(ms) * time.Millisecond
+}
+
+// ttl returns how long the sessions live in the store.
+func (c sessionConfig) ttl() time.Duration {
+	if c.maxAge > 0 {
+		return c.maxAge
+	}
+	return defaultSessionTTL
+}
+
+// load returns the session of the request cookie, or a new session when the cookie is missing, badly signed or
+// its session expired.
+func (c sessionConfig) load(ctx context.Context, store SessionStore, r *http.Request) (string, SessionData, bool, error) {
+	if cookie, err := r.Cookie(c.name); err == nil {
+		if id, ok := unsignSessionID(cookie.Value, c.secrets); ok {
+			data, err := store.Get(ctx, id)
+			if err == nil {
+				return id, data, false, nil
+			}
+			if !errors.Is(err, ErrSessionNotFound) {
+				return "", nil, false, fmt.Errorf("could not load session: %v", err)
+			}
+		}
+	}
+	id, err := newSessionID()
+	if err != nil {
+		return "", nil, false, fmt.Errorf("could not generate session id: %v", err)
+	}
+	return id, SessionData{}, true, nil
+}
+
+// cookie returns the session cookie to set, nil when the response does not set it: a new session sets it when
+// saveUninitialized is on or it was modified, an existing one when rolling is on or it was modified and expires.
+func (c sessionConfig) cookie(s *TenantSession, isNew bool) *http.Cookie {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.destroyed {
+		return nil
+	}
+	set := c.rolling || (c.maxAge > 0 && s.modified)
+	if isNew {
+		set = c.saveUninitialized || s.modified
+	}
+	if !set {
+		return nil
+	}
+	cookie := &http.Cookie{
+		Name:     c.name,
+		Value:    signSessionID(s.id, c.secrets[0]),
+		Path:     c.path,
+		HttpOnly: c.httpOnly,
+		Secure:   c.secure,
+	}
+	if c.maxAge > 0 {
+		cookie.MaxAge = int(c.maxAge / time.Second)
+		cookie.Expires = time.Now().Add(c.maxAge)
+	}
+	return cookie
+}
+
+// save stores the session at the end of the request: a new session when its cookie was sent, an existing one when
+// it was modified or resave is on. An unchanged existing session is touched.
+func (c sessionConfig) save(ctx context.Context, store SessionStore, s *TenantSession, data SessionData, tenant string, isNew, sent bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if isNew && !sent {
+		return nil
+	}
+	if s.destroyed {
+		delete(data, tenant)
+		if len(data) == 0 && c.unset != sessionUnsetKeep {
+			return store.Destroy(ctx, s.id)
+		}
+		return store.Set(ctx, s.id, data, c.ttl())
+	}
+	if isNew || s.modified || c.resave {
+		data[tenant] = s.values
+		return store.Set(ctx, s.id, data, c.ttl())
+	}
+	return store.Touch(ctx, s.id, c.ttl())
+}
+
+// newSessionID returns a random session id, 24 bytes encoded in URL safe base64 as express-session does.
+func newSessionID() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// signSessionID returns the cookie value of the session id, "s:" followed by the id signed with HMAC SHA-256,
+// escaped the way encodeURIComponent does.
+func signSessionID(id, secret string) string {
+	return url.QueryEscape("s:" + id + "." + sessionSignature(id, secret))
+}
+
+// unsignSessionID returns the session id of the cookie value when one of the secrets signed it.
+func unsignSessionID(value string, secrets []string) (string, bool) {
+	v, err := url.QueryUnescape(value)
+	if err != nil || !strings.HasPrefix(v, "s:") {
+		return "", false
+	}
+	v = v[len("s:"):]
+	i := strings.LastIndex(v, ".")
+	if i < 0 {
+		return "", false
+	}
+	id, signature := v[:i], v[i+1:]
+	for _, secret := range secrets {
+		if hmac.Equal([]byte(sessionSignature(id, secret)), []byte(signature)) {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+func sessionSignature(id, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(id))
+	return base64.RawStdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// ID returns the id of the session.
+func (s *TenantSession) ID() string {
+	return s.id
+}
+
+// Get returns the value of the key.
+func (s *TenantSession) Get(key string) (interface{}, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	v, ok := s.values[key]
+	return v, ok
+}
+
+// Set sets the value of the key. The value is saved in the store at the end of the request.
+func (s *TenantSession) Set(key string, value interface{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values[key] = value
+	s.modified = true
+	s.destroyed = false
+}
+
+// Delete deletes the key.
+func (s *TenantSession) Delete(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.values, key)
+	s.modified = true
+}
+
+// Values returns a copy of the values of the session.
+func (s *TenantSession) Values() map[string]interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make(map[string]interface{}, len(s.values))
+	for k, v := range s.values {
+		out[k] = v
+	}
+	return out
+}
+
+// Destroy deletes the data of the tenant from the session. The session is destroyed in the store once no tenant
+// has data, unless the unset option of the service config is "keep".
+func (s *TenantSession) Destroy() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.values = make(map[string]interface{})
+	s.destroyed = true
+}
+
+// NewMemoryStore creates an empty MemoryStore.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]memorySession)}
+}
+
+// Get implements SessionStore.
+func (m *MemoryStore) Get(_ context.Context, id string) (SessionData, error) {
+	m.mu.Lock()
+	s, ok := m.sessions[id]
+	if ok && !time.Now().Before(s.expires) {
+		delete(m.sessions, id)
+		ok = false
+	}
+	m.mu.Unlock()
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	var data SessionData
+	if err := json.Unmarshal(s.data, &data); err != nil {
+		return nil, fmt.Errorf("could not decode session %s: %v", id, err)
+	}
+	if data == nil {
+		data = SessionData{}
+	}
+	return data, nil
+}
+
+// Set implements SessionStore. The data is stored as JSON, as persistent stores do.
+func (m *MemoryStore) Set(_ context.Context, id string, data SessionData, ttl time.Duration) error {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("could not encode session %s: %v", id, err)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[id] = memorySession{data: b, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+// Touch implements SessionStore.
+func (m *MemoryStore) Touch(_ context.Context, id string, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if s, ok := m.sessions[id]; ok {
+		s.expires = time.Now().Add(ttl)
+		m.sessions[id] = s
+	}
+	return nil
+}
+
+// Destroy implements SessionStore.
+func (m *MemoryStore) Destroy(_ context.Context, id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+	return nil
+}
+
+// Len returns the number of sessions in the store, expired ones included.
+func (m *MemoryStore) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.sessions)
+}
+
+func (sw *sessionWriter) writeCookie() {
+	sw.once.Do(func() {
+		sw.sent = sw.setCookie()
+	})
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (sw *sessionWriter) WriteHeader(status int) {
+	sw.writeCookie()
+	sw.ResponseWriter.WriteHeader(status)
+}
+
+// Write implements http.ResponseWriter.
+func (sw *sessionWriter) Write(b []byte) (int, error) {
+	sw.writeCookie()
+	return sw.ResponseWriter.Write(b)
+}
+
+// Flush implements http.Flusher.
+func (sw *sessionWriter) Flush() {
+	sw.writeCookie()
+	if f, ok := sw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap returns the underlying response writer, for http.ResponseController.
+func (sw *sessionWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
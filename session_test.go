@@ -0,0 +1,248 @@
+package soajsgo
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingStore struct {
+	*MemoryStore
+}
+
+func (failingStore) Get(context.Context, string) (SessionData, error) {
+	return nil, errors.New("connection refused")
+}
+
+func newSessionRegistry(s Session) *Registry {
+	reg := &Registry{}
+	reg.ServiceConfig.Session = s
+	WithLogOutput(io.Discard)(reg)
+	return reg
+}
+
+// serveSession serves a request of the tenant through the session middleware, with the cookie when not nil.
+func serveSession(t *testing.T, reg *Registry, store SessionStore, tenant string, cookie *http.Cookie, handler func(s *TenantSession)) *httptest.ResponseRecorder {
+	t.Helper()
+	h := reg.MiddlewareWith(WithSession(store))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, ok := SessionFromContext(r.Context())
+		require.True(t, ok)
+		handler(s)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(HeaderInjectObj, `{"tenant":{"id":"`+tenant+`","code":"TNT"}}`)
+	if cookie != nil {
+		req.AddCookie(cookie)
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func sessionCookie(rec *httptest.ResponseRecorder) *http.Cookie {
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == defaultSessionName {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestSessionSignature(t *testing.T) {
+	value := signSessionID("abc", "keyboard cat")
+	assert.Equal(t, "s%3Aabc.BpxCrWRpvZMh%2Fwk%2Fdjl34N%2Bm%2BVQEU7K%2F5WenLwJCgFU", value)
+
+	tt := []struct {
+		name       string
+		value      string
+		secrets    []string
+		expectedID string
+		expectedOK bool
+	}{
+		{name: "signed", value: value, secrets: []string{"keyboard cat"}, expectedID: "abc", expectedOK: true},
+		{name: "previous secret", value: value, secrets: []string{"new", "keyboard cat"}, expectedID: "abc", expectedOK: true},
+		{name: "wrong secret", value: value, secrets: []string{"other"}},
+		{name: "unsigned", value: "abc", secrets: []string{"keyboard cat"}},
+		{name: "no signature", value: "s%3Aabc", secrets: []string{"keyboard cat"}},
+		{name: "bad escape", value: "s%3Aabc.%zz", secrets: []string{"keyboard cat"}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			id, ok := unsignSessionID(tc.value, tc.secrets)
+			assert.Equal(t, tc.expectedOK, ok)
+			assert.Equal(t, tc.expectedID, id)
+		})
+	}
+}
+
+func TestSessionMiddleware(t *testing.T) {
+	store := NewMemoryStore()
+	reg := newSessionRegistry(Session{Secret: "secret", Cookie: SessionCookie{HTTPOnly: true}})
+
+	rec := serveSession(t, reg, store, "t1", nil, func(s *TenantSession) {})
+	assert.Nil(t, sessionCookie(rec), "uninitialized session must not set a cookie")
+	assert.Equal(t, 0, store.Len())
+
+	rec = serveSession(t, reg, store, "t1", nil, func(s *TenantSession) { s.Set("user", "john") })
+	cookie := sessionCookie(rec)
+	require.NotNil(t, cookie)
+	assert.True(t, cookie.HttpOnly)
+	assert.Equal(t, "/", cookie.Path)
+	assert.Zero(t, cookie.MaxAge)
+	assert.Equal(t, 1, store.Len())
+
+	rec = serveSession(t, reg, store, "t1", cookie, func(s *TenantSession) {
+		v, ok := s.Get("user")
+		assert.True(t, ok)
+		assert.Equal(t, "john", v)
+	})
+	assert.Nil(t, sessionCookie(rec), "unchanged session must not set a cookie without rolling")
+
+	serveSession(t, reg, store, "t2", cookie, func(s *TenantSession) {
+		assert.Empty(t, s.Values(), "tenants must not share session data")
+		s.Set("user", "jane")
+	})
+	serveSession(t, reg, store, "t1", cookie, func(s *TenantSession) {
+		assert.Equal(t, map[string]interface{}{"user": "john"}, s.Values())
+		s.Destroy()
+	})
+	serveSession(t, reg, store, "t2", cookie, func(s *TenantSession) {
+		assert.Equal(t, map[string]interface{}{"user": "jane"}, s.Values())
+		s.Destroy()
+	})
+	assert.Equal(t, 0, store.Len(), "session without data must be destroyed")
+
+	serveSession(t, reg, store, "t1", cookie, func(s *TenantSession) {
+		assert.Empty(t, s.Values())
+		assert.NotEqual(t, cookie.Value, signSessionID(s.ID(), "secret"))
+	})
+}
+
+func TestSessionMiddlewareOptions(t *testing.T) {
+	tt := []struct {
+		name           string
+		session        Session
+		cookieSecret   string
+		expectedCookie bool
+		expectedMaxAge int
+		expectedStored int
+	}{
+		{name: "save uninitialized", session: Session{Secret: "secret", SaveUninitialized: true}, expectedCookie: true, expectedStored: 1},
+		{name: "cookie secret", session: Session{SaveUninitialized: true}, cookieSecret: "secret", expectedCookie: true, expectedStored: 1},
+		{name: "max age", session: Session{Secret: "secret", SaveUninitialized: true, Cookie: SessionCookie{MaxAge: float64(60000)}}, expectedCookie: true, expectedMaxAge: 60, expectedStored: 1},
+		{name: "no secret", session: Session{SaveUninitialized: true}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			store := NewMemoryStore()
+			reg := newSessionRegistry(tc.session)
+			reg.ServiceConfig.Cookie.Secret = tc.cookieSecret
+			h := reg.MiddlewareWith(WithSession(store))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_, ok := SessionFromContext(r.Context())
+				assert.Equal(t, tc.expectedCookie, ok)
+			}))
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+			cookie := sessionCookie(rec)
+			assert.Equal(t, tc.expectedCookie, cookie != nil)
+			if cookie != nil {
+				assert.Equal(t, tc.expectedMaxAge, cookie.MaxAge)
+			}
+			assert.Equal(t, tc.expectedStored, store.Len())
+		})
+	}
+}
+
+func TestSessionMiddlewareRolling(t *testing.T) {
+	store := NewMemoryStore()
+	reg := newSessionRegistry(Session{Name: "sid", Secret: "secret", Rolling: true, Unset: "keep"})
+	h := reg.MiddlewareWith(WithSession(store))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s, _ := SessionFromContext(r.Context())
+		if r.URL.Path == "/logout" {
+			s.Destroy()
+			return
+		}
+		s.Set("visits", 1)
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	cookies := rec.Result().Cookies()
+	require.Len(t, cookies, 1)
+	assert.Equal(t, "sid", cookies[0].Name)
+
+	req := httptest.NewRequest(http.MethodGet, "/logout", nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Empty(t, rec.Result().Cookies(), "destroyed session must not set a cookie")
+	assert.Equal(t, 1, store.Len(), "unset keep must keep the session")
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Len(t, rec.Result().Cookies(), 1, "rolling session must set the cookie on every response")
+}
+
+func TestSessionMiddlewareStoreError(t *testing.T) {
+	reg := newSessionRegistry(Session{Secret: "secret"})
+	h := reg.MiddlewareWith(WithSession(failingStore{NewMemoryStore()}))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("handler must not be called")
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: defaultSessionName, Value: signSessionID("abc", "secret")})
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	var res Response
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.Equal(t, []int{CodeSession}, res.Errors.Codes)
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryStore()
+	_, err := store.Get(ctx, "a")
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+
+	require.NoError(t, store.Set(ctx, "a", SessionData{"t1": {"n": 1}}, time.Hour))
+	data, err := store.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.Equal(t, SessionData{"t1": {"n": float64(1)}}, data)
+
+	require.NoError(t, store.Set(ctx, "b", SessionData{}, time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	_, err = store.Get(ctx, "b")
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+
+	require.NoError(t, store.Touch(ctx, "a", time.Millisecond))
+	time.Sleep(5 * time.Millisecond)
+	_, err = store.Get(ctx, "a")
+	assert.ErrorIs(t, err, ErrSessionNotFound)
+
+	require.NoError(t, store.Set(ctx, "c", SessionData{}, time.Hour))
+	require.NoError(t, store.Destroy(ctx, "c"))
+	assert.Equal(t, 0, store.Len())
+}
+
+func TestRegistrySessionDatabase(t *testing.T) {
+	reg := &Registry{CoreDBs: map[string]Database{"session": {Name: "core_session", Collection: "sessions", ExpireAfter: 1209600}}}
+	db, ok := reg.SessionDatabase()
+	assert.True(t, ok)
+	assert.Equal(t, "sessions", db.Collection)
+
+	_, ok = (&Registry{}).SessionDatabase()
+	assert.False(t, ok)
+}
@@ -0,0 +1,38 @@
+// Package soajschi adapts the SOAJS middleware to the chi router.
+package soajschi
+
+import (
+	"net/http"
+
+	soajsgo "github.com/soajs/soajs.golang"
+)
+
+// Middleware returns a chi middleware that injects the SOAJS context data into the context of the request.
+// Requests rejected by the options, e.g. soajsgo.Strict or soajsgo.WithACL, are answered with the SOAJS error
+// envelope and do not reach the next handler.
+func Middleware(reg *soajsgo.Registry, opts ...soajsgo.MiddlewareOption) func(http.Handler) http.Handler {
+	return reg.MiddlewareWith(opts...)
+}
+
+// Data returns the SOAJS context data of the request.
+func Data(r *http.Request) (soajsgo.ContextData, bool) {
+	return soajsgo.FromContext(r.Context())
+}
+
+// Tenant returns the tenant of the request.
+func Tenant(r *http.Request) (soajsgo.Tenant, bool) {
+	data, ok := Data(r)
+	return data.Tenant, ok
+}
+
+// Urac returns the logged in user of the request. It reports false when there is no logged in user.
+func Urac(r *http.Request) (soajsgo.Urac, bool) {
+	data, ok := Data(r)
+	return data.Urac, ok && data.Urac.ID != ""
+}
+
+// Registry returns the registry of the service handling the request.
+func Registry(r *http.Request) (*soajsgo.Registry, bool) {
+	data, ok := Data(r)
+	return data.Reg, ok && data.Reg != nil
+}
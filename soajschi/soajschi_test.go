@@ -0,0 +1,58 @@
+package soajschi
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	soajsgo "github.com/soajs/soajs.golang"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	acl := `{"tenant":{"code":"TNT"},"urac":{"_id":"1"},"application":{"acl":{"svc":{"apisPermission":"restricted","apis":{"/users/:id":{}}}}}}`
+
+	tt := []struct {
+		name           string
+		opts           []soajsgo.MiddlewareOption
+		header         string
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "no context", header: "", path: "/users/42", expectedStatus: http.StatusOK, expectedBody: "none"},
+		{name: "strict", opts: []soajsgo.MiddlewareOption{soajsgo.Strict()}, header: "", path: "/users/42", expectedStatus: http.StatusUnauthorized},
+		{name: "context", header: acl, path: "/users/42", expectedStatus: http.StatusOK, expectedBody: "TNT 1 42 true"},
+		{name: "acl allowed", opts: []soajsgo.MiddlewareOption{soajsgo.WithACL("svc", "1")}, header: acl, path: "/users/42", expectedStatus: http.StatusOK, expectedBody: "TNT 1 42 true"},
+		{name: "acl denied", opts: []soajsgo.MiddlewareOption{soajsgo.WithACL("svc", "1")}, header: acl, path: "/denied", expectedStatus: http.StatusForbidden},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := func(w http.ResponseWriter, r *http.Request) {
+				tenant, ok := Tenant(r)
+				if !ok {
+					_, _ = w.Write([]byte("none"))
+					return
+				}
+				urac, _ := Urac(r)
+				_, hasReg := Registry(r)
+				_, _ = fmt.Fprintf(w, "%s %s %s %t", tenant.Code, urac.ID, chi.URLParam(r, "id"), hasReg)
+			}
+			router := chi.NewRouter()
+			router.Use(Middleware(&soajsgo.Registry{}, tc.opts...))
+			router.Get("/users/{id}", handler)
+			router.Get("/denied", handler)
+
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			req.Header.Set("soajsinjectobj", tc.header)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			if tc.expectedBody != "" {
+				assert.Equal(t, tc.expectedBody, rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,61 @@
+// Package soajsecho adapts the SOAJS middleware to the Echo web framework.
+package soajsecho
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	soajsgo "github.com/soajs/soajs.golang"
+)
+
+// ContextKey is the key the SOAJS context data is stored under in echo.Context.
+const ContextKey = "soajs"
+
+// Middleware returns an echo middleware that injects the SOAJS context data into echo.Context and into the context
+// of the request. The next handlers write through the writer of the SOAJS middleware. Requests rejected by the
+// options, e.g. soajsgo.Strict or soajsgo.WithACL, are answered with the SOAJS error envelope and do not reach the
+// next handler.
+func Middleware(reg *soajsgo.Registry, opts ...soajsgo.MiddlewareOption) echo.MiddlewareFunc {
+	mw := reg.MiddlewareWith(opts...)
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			var err error
+			res := c.Response()
+			mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c.SetRequest(r)
+				if data, ok := soajsgo.FromContext(r.Context()); ok {
+					c.Set(ContextKey, data)
+				}
+				// w writes to res, its writer cannot be swapped for w without writing to itself
+				c.SetResponse(echo.NewResponse(w, c.Echo()))
+				defer c.SetResponse(res)
+				err = next(c)
+			})).ServeHTTP(res, c.Request())
+			return err
+		}
+	}
+}
+
+// Data returns the SOAJS context data of the request.
+func Data(c echo.Context) (soajsgo.ContextData, bool) {
+	data, ok := c.Get(ContextKey).(soajsgo.ContextData)
+	return data, ok
+}
+
+// Tenant returns the tenant of the request.
+func Tenant(c echo.Context) (soajsgo.Tenant, bool) {
+	data, ok := Data(c)
+	return data.Tenant, ok
+}
+
+// Urac returns the logged in user of the request. It reports false when there is no logged in user.
+func Urac(c echo.Context) (soajsgo.Urac, bool) {
+	data, ok := Data(c)
+	return data.Urac, ok && data.Urac.ID != ""
+}
+
+// Registry returns the registry of the service handling the request.
+func Registry(c echo.Context) (*soajsgo.Registry, bool) {
+	data, ok := Data(c)
+	return data.Reg, ok && data.Reg != nil
+}
@@ -0,0 +1,116 @@
+package soajsecho
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	soajsgo "github.com/soajs/soajs.golang"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	acl := `{"tenant":{"code":"TNT"},"urac":{"_id":"1"},"application":{"acl":{"svc":{"apisPermission":"restricted","apis":{"/allowed":{}}}}}}`
+
+	tt := []struct {
+		name           string
+		opts           []soajsgo.MiddlewareOption
+		header         string
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "no context", header: "", path: "/allowed", expectedStatus: http.StatusOK, expectedBody: "none"},
+		{name: "strict", opts: []soajsgo.MiddlewareOption{soajsgo.Strict()}, header: "", path: "/allowed", expectedStatus: http.StatusUnauthorized},
+		{name: "context", header: acl, path: "/allowed", expectedStatus: http.StatusOK, expectedBody: "TNT 1 true"},
+		{name: "acl allowed", opts: []soajsgo.MiddlewareOption{soajsgo.WithACL("svc", "1")}, header: acl, path: "/allowed", expectedStatus: http.StatusOK, expectedBody: "TNT 1 true"},
+		{name: "acl denied", opts: []soajsgo.MiddlewareOption{soajsgo.WithACL("svc", "1")}, header: acl, path: "/denied", expectedStatus: http.StatusForbidden},
+		{name: "handler error", header: acl, path: "/error", expectedStatus: http.StatusTeapot},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := func(c echo.Context) error {
+				tenant, ok := Tenant(c)
+				if !ok {
+					return c.String(http.StatusOK, "none")
+				}
+				urac, _ := Urac(c)
+				_, hasReg := Registry(c)
+				_, inRequest := soajsgo.FromContext(c.Request().Context())
+				return c.String(http.StatusOK, fmt.Sprintf("%s %s %t", tenant.Code, urac.ID, hasReg && inRequest))
+			}
+			e := echo.New()
+			e.Use(Middleware(&soajsgo.Registry{}, tc.opts...))
+			e.GET("/allowed", handler)
+			e.GET("/denied", handler)
+			e.GET("/error", func(c echo.Context) error {
+				return echo.NewHTTPError(http.StatusTeapot, errors.New("failed"))
+			})
+
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			req.Header.Set("soajsinjectobj", tc.header)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			if tc.expectedBody != "" {
+				assert.Equal(t, tc.expectedBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestMiddleware_TimeoutAndSession(t *testing.T) {
+	timeout := httptest.NewRecorder()
+	soajsgo.WriteError(timeout, soajsgo.CodeTimeout, soajsgo.ErrRequestTimeout.Error())
+
+	tt := []struct {
+		name           string
+		handler        echo.HandlerFunc
+		expectedStatus int
+		expectedBody   string
+		expectedCookie bool
+	}{
+		{
+			name: "session cookie",
+			handler: func(c echo.Context) error {
+				s, _ := soajsgo.SessionFromContext(c.Request().Context())
+				s.Set("user", "john")
+				return c.String(http.StatusCreated, "ok")
+			},
+			expectedStatus: http.StatusCreated,
+			expectedBody:   "ok",
+			expectedCookie: true,
+		},
+		{
+			name: "timeout",
+			handler: func(c echo.Context) error {
+				<-c.Request().Context().Done()
+				return c.String(http.StatusOK, "late")
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   timeout.Body.String(),
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := &soajsgo.Registry{}
+			reg.ServiceConfig.Session = soajsgo.Session{Name: "sid", Secret: "secret"}
+			e := echo.New()
+			e.Use(Middleware(reg, soajsgo.WithRequestTimeout(50*time.Millisecond, 0), soajsgo.WithSession(soajsgo.NewMemoryStore())))
+			e.GET("/", tc.handler)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("soajsinjectobj", `{"tenant":{"id":"t1","code":"TNT"}}`)
+			rec := httptest.NewRecorder()
+			e.ServeHTTP(rec, req)
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			assert.Equal(t, tc.expectedBody, rec.Body.String())
+			cookies := rec.Result().Cookies()
+			assert.Equal(t, tc.expectedCookie, len(cookies) == 1 && cookies[0].Name == "sid", cookies)
+		})
+	}
+}
@@ -0,0 +1,156 @@
+// Package soajsgin adapts the SOAJS middleware to the Gin web framework.
+package soajsgin
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	soajsgo "github.com/soajs/soajs.golang"
+)
+
+// ContextKey is the key the SOAJS context data is stored under in gin.Context.
+const ContextKey = "soajs"
+
+// noWritten is the size of a response whose header is not written yet, as in gin.
+const noWritten = -1
+
+// responseWriter is the gin.ResponseWriter the next handlers write through, so their response goes through the
+// writer of the SOAJS middleware, e.g. the one answering timed out requests or setting the session cookie. As in
+// gin, the header is written on the first write or by WriteHeaderNow.
+type responseWriter struct {
+	http.ResponseWriter
+	gin    gin.ResponseWriter
+	status int
+	size   int
+}
+
+// Middleware returns a gin middleware that injects the SOAJS context data into gin.Context and into the context of
+// the request. The next handlers write through the writer of the SOAJS middleware. Requests rejected by the options,
+// e.g. soajsgo.Strict or soajsgo.WithACL, are aborted.
+func Middleware(reg *soajsgo.Registry, opts ...soajsgo.MiddlewareOption) gin.HandlerFunc {
+	mw := reg.MiddlewareWith(opts...)
+	return func(c *gin.Context) {
+		passed := false
+		writer := c.Writer
+		mw(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			passed = true
+			c.Request = r
+			if data, ok := soajsgo.FromContext(r.Context()); ok {
+				c.Set(ContextKey, data)
+			}
+			rw := &responseWriter{ResponseWriter: w, gin: writer, status: http.StatusOK, size: noWritten}
+			c.Writer = rw
+			defer func() { c.Writer = writer }()
+			c.Next()
+			rw.WriteHeaderNow()
+		})).ServeHTTP(writer, c.Request)
+		if !passed {
+			c.Abort()
+		}
+	}
+}
+
+// WriteHeader implements http.ResponseWriter, the status is written with the header.
+func (w *responseWriter) WriteHeader(status int) {
+	if status > 0 && !w.Written() {
+		w.status = status
+	}
+}
+
+// WriteHeaderNow implements gin.ResponseWriter.
+func (w *responseWriter) WriteHeaderNow() {
+	if !w.Written() {
+		w.size = 0
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+}
+
+// Write implements http.ResponseWriter.
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.WriteHeaderNow()
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+// WriteString implements gin.ResponseWriter.
+func (w *responseWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Status implements gin.ResponseWriter.
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+// Size implements gin.ResponseWriter.
+func (w *responseWriter) Size() int {
+	return w.size
+}
+
+// Written implements gin.ResponseWriter.
+func (w *responseWriter) Written() bool {
+	return w.size != noWritten
+}
+
+// Hijack implements http.Hijacker.
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if w.size < 0 {
+		w.size = 0
+	}
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Flush implements http.Flusher.
+func (w *responseWriter) Flush() {
+	w.WriteHeaderNow()
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// CloseNotify implements http.CloseNotifier.
+func (w *responseWriter) CloseNotify() <-chan bool {
+	return w.gin.CloseNotify()
+}
+
+// Pusher implements gin.ResponseWriter.
+func (w *responseWriter) Pusher() http.Pusher {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p
+	}
+	return nil
+}
+
+// Unwrap returns the writer of the SOAJS middleware, for http.ResponseController.
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Data returns the SOAJS context data of the request.
+func Data(c *gin.Context) (soajsgo.ContextData, bool) {
+	v, ok := c.Get(ContextKey)
+	if !ok {
+		return soajsgo.ContextData{}, false
+	}
+	data, ok := v.(soajsgo.ContextData)
+	return data, ok
+}
+
+// Tenant returns the tenant of the request.
+func Tenant(c *gin.Context) (soajsgo.Tenant, bool) {
+	data, ok := Data(c)
+	return data.Tenant, ok
+}
+
+// Urac returns the logged in user of the request. It reports false when there is no logged in user.
+func Urac(c *gin.Context) (soajsgo.Urac, bool) {
+	data, ok := Data(c)
+	return data.Urac, ok && data.Urac.ID != ""
+}
+
+// Registry returns the registry of the service handling the request.
+func Registry(c *gin.Context) (*soajsgo.Registry, bool) {
+	data, ok := Data(c)
+	return data.Reg, ok && data.Reg != nil
+}
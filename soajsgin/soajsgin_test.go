@@ -0,0 +1,113 @@
+package soajsgin
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	soajsgo "github.com/soajs/soajs.golang"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	acl := `{"tenant":{"code":"TNT"},"urac":{"_id":"1"},"application":{"acl":{"svc":{"apisPermission":"restricted","apis":{"/allowed":{}}}}}}`
+
+	tt := []struct {
+		name           string
+		opts           []soajsgo.MiddlewareOption
+		header         string
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{name: "no context", header: "", path: "/allowed", expectedStatus: http.StatusOK, expectedBody: "none"},
+		{name: "strict", opts: []soajsgo.MiddlewareOption{soajsgo.Strict()}, header: "", path: "/allowed", expectedStatus: http.StatusUnauthorized},
+		{name: "context", header: acl, path: "/allowed", expectedStatus: http.StatusOK, expectedBody: "TNT 1 true"},
+		{name: "acl allowed", opts: []soajsgo.MiddlewareOption{soajsgo.WithACL("svc", "1")}, header: acl, path: "/allowed", expectedStatus: http.StatusOK, expectedBody: "TNT 1 true"},
+		{name: "acl denied", opts: []soajsgo.MiddlewareOption{soajsgo.WithACL("svc", "1")}, header: acl, path: "/denied", expectedStatus: http.StatusForbidden},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			handler := func(c *gin.Context) {
+				tenant, ok := Tenant(c)
+				if !ok {
+					c.String(http.StatusOK, "none")
+					return
+				}
+				urac, _ := Urac(c)
+				_, hasReg := Registry(c)
+				_, inRequest := soajsgo.FromContext(c.Request.Context())
+				c.String(http.StatusOK, "%s %s %t", tenant.Code, urac.ID, hasReg && inRequest)
+			}
+			router := gin.New()
+			router.Use(Middleware(&soajsgo.Registry{}, tc.opts...))
+			router.GET("/allowed", handler)
+			router.GET("/denied", handler)
+
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			req.Header.Set("soajsinjectobj", tc.header)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			if tc.expectedBody != "" {
+				assert.Equal(t, tc.expectedBody, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestMiddleware_TimeoutAndSession(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	timeout := httptest.NewRecorder()
+	soajsgo.WriteError(timeout, soajsgo.CodeTimeout, soajsgo.ErrRequestTimeout.Error())
+
+	tt := []struct {
+		name           string
+		handler        gin.HandlerFunc
+		expectedStatus int
+		expectedBody   string
+		expectedCookie bool
+	}{
+		{
+			name: "session cookie",
+			handler: func(c *gin.Context) {
+				s, _ := soajsgo.SessionFromContext(c.Request.Context())
+				s.Set("user", "john")
+				c.String(http.StatusCreated, "ok")
+			},
+			expectedStatus: http.StatusCreated,
+			expectedBody:   "ok",
+			expectedCookie: true,
+		},
+		{
+			name: "timeout",
+			handler: func(c *gin.Context) {
+				<-c.Request.Context().Done()
+				c.String(http.StatusOK, "late")
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   timeout.Body.String(),
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := &soajsgo.Registry{}
+			reg.ServiceConfig.Session = soajsgo.Session{Name: "sid", Secret: "secret"}
+			router := gin.New()
+			router.Use(Middleware(reg, soajsgo.WithRequestTimeout(50*time.Millisecond, 0), soajsgo.WithSession(soajsgo.NewMemoryStore())))
+			router.GET("/", tc.handler)
+
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set("soajsinjectobj", `{"tenant":{"id":"t1","code":"TNT"}}`)
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			assert.Equal(t, tc.expectedBody, rec.Body.String())
+			cookies := rec.Result().Cookies()
+			assert.Equal(t, tc.expectedCookie, len(cookies) == 1 && cookies[0].Name == "sid", cookies)
+		})
+	}
+}
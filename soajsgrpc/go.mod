@@ -0,0 +1,22 @@
+module github.com/soajs/soajs.golang/soajsgrpc
+
+go 1.21
+
+require (
+	github.com/soajs/soajs.golang v0.0.0
+	github.com/stretchr/testify v1.11.1
+	google.golang.org/grpc v1.65.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	golang.org/x/net v0.25.0 // indirect
+	golang.org/x/sys v0.20.0 // indirect
+	golang.org/x/text v0.15.0 // indirect
+	google.golang.org/genproto/googleapis/rpc v0.0.0-20240528184218-531527333157 // indirect
+	google.golang.org/protobuf v1.34.1 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace github.com/soajs/soajs.golang => ../
@@ -0,0 +1,155 @@
+// Package soajsgrpc carries the SOAJS context over gRPC metadata.
+//
+// Server interceptors read the injected object from the soajsinjectobj metadata into the same soajsgo.ContextData
+// the http middleware provides. Client interceptors forward the context of the incoming call the way
+// soajsgo.ContextData.Connect does: the injected object for services found in the InterConnect mesh, the tenant
+// external key otherwise. The request id of the context is forwarded as x-request-id.
+package soajsgrpc
+
+import (
+	"context"
+
+	soajsgo "github.com/soajs/soajs.golang"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// MetadataInjectObj is the metadata key holding the injected object.
+	MetadataInjectObj = soajsgo.HeaderInjectObj
+	// MetadataKey is the metadata key holding the tenant external key when calling through the gateway.
+	MetadataKey = "key"
+	// MetadataRequestID is the metadata key holding the request id logged with the request logger.
+	MetadataRequestID = "x-request-id"
+)
+
+type (
+	// Option configures the server interceptors.
+	Option func(*options)
+
+	options struct {
+		strict bool
+	}
+
+	// serverStream overrides the context of a grpc.ServerStream.
+	serverStream struct {
+		grpc.ServerStream
+		ctx context.Context
+	}
+)
+
+// Strict rejects the calls that do not carry a valid injected object with codes.Unauthenticated instead of passing
+// them through without context.
+func Strict() Option {
+	return func(o *options) {
+		o.strict = true
+	}
+}
+
+// UnaryServerInterceptor injects the SOAJS context data of unary calls.
+func UnaryServerInterceptor(reg *soajsgo.Registry, opts ...Option) grpc.UnaryServerInterceptor {
+	o := newOptions(opts)
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, err := inject(ctx, reg, o)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor injects the SOAJS context data of streaming calls.
+func StreamServerInterceptor(reg *soajsgo.Registry, opts ...Option) grpc.StreamServerInterceptor {
+	o := newOptions(opts)
+	return func(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := inject(ss.Context(), reg, o)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// UnaryClientInterceptor forwards the SOAJS context of ctx to the service and version the connection targets.
+func UnaryClientInterceptor(serviceName, version string) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply interface{}, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, err := forward(ctx, serviceName, version)
+		if err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor forwards the SOAJS context of ctx to the service and version the connection targets.
+func StreamClientInterceptor(serviceName, version string) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, err := forward(ctx, serviceName, version)
+		if err != nil {
+			return nil, err
+		}
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+// Target returns the address to dial for the service and version, as resolved by ContextData.Connect.
+func Target(data soajsgo.ContextData, serviceName, version string) string {
+	return data.Connect(serviceName, version).Host
+}
+
+// Context returns the stream context with the SOAJS context data.
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func newOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt(&o)
+	}
+	return o
+}
+
+func inject(ctx context.Context, reg *soajsgo.Registry, o options) (context.Context, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	data, err := reg.ContextDataFromHeader(first(md, MetadataInjectObj))
+	if err != nil {
+		if o.strict {
+			return nil, status.Error(codes.Unauthenticated, err.Error())
+		}
+		return ctx, nil
+	}
+	data.RequestID = first(md, MetadataRequestID)
+	return context.WithValue(ctx, soajsgo.SoajsKey, data), nil
+}
+
+func first(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func forward(ctx context.Context, serviceName, version string) (context.Context, error) {
+	data, ok := soajsgo.FromContext(ctx)
+	if !ok {
+		return ctx, nil
+	}
+	if data.RequestID != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, MetadataRequestID, data.RequestID)
+	}
+	connect := data.Connect(serviceName, version)
+	if connect.Headers.SoajsInjectobj == nil {
+		if connect.Headers.Key == "" {
+			return ctx, nil
+		}
+		return metadata.AppendToOutgoingContext(ctx, MetadataKey, connect.Headers.Key), nil
+	}
+	obj, err := data.InjectObj()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return metadata.AppendToOutgoingContext(ctx, MetadataInjectObj, obj), nil
+}
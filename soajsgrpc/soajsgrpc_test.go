@@ -0,0 +1,154 @@
+package soajsgrpc
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"testing"
+
+	soajsgo "github.com/soajs/soajs.golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// healthServer records the SOAJS context and metadata of the calls it receives.
+type healthServer struct {
+	grpc_health_v1.UnimplementedHealthServer
+	data    soajsgo.ContextData
+	hasData bool
+	md      metadata.MD
+}
+
+func (s *healthServer) record(ctx context.Context) {
+	s.data, s.hasData = soajsgo.FromContext(ctx)
+	s.md, _ = metadata.FromIncomingContext(ctx)
+}
+
+func (s *healthServer) Check(ctx context.Context, _ *grpc_health_v1.HealthCheckRequest) (*grpc_health_v1.HealthCheckResponse, error) {
+	s.record(ctx)
+	return &grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING}, nil
+}
+
+func (s *healthServer) Watch(_ *grpc_health_v1.HealthCheckRequest, stream grpc_health_v1.Health_WatchServer) error {
+	s.record(stream.Context())
+	return stream.Send(&grpc_health_v1.HealthCheckResponse{Status: grpc_health_v1.HealthCheckResponse_SERVING})
+}
+
+func newClient(t *testing.T, reg *soajsgo.Registry, opts ...Option) (grpc_health_v1.HealthClient, *healthServer) {
+	t.Helper()
+	ln := bufconn.Listen(1024 * 1024)
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(reg, opts...)),
+		grpc.StreamInterceptor(StreamServerInterceptor(reg, opts...)),
+	)
+	health := &healthServer{}
+	grpc_health_v1.RegisterHealthServer(srv, health)
+	go func() {
+		_ = srv.Serve(ln)
+	}()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return ln.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor("svc", "1")),
+		grpc.WithStreamInterceptor(StreamClientInterceptor("svc", "1")),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+	return grpc_health_v1.NewHealthClient(conn), health
+}
+
+func TestInterceptors(t *testing.T) {
+	mesh := soajsgo.ContextData{
+		Tenant:    soajsgo.Tenant{Code: "TNT", Key: soajsgo.Key{IKey: "ikey", EKey: "ekey"}},
+		Urac:      soajsgo.Urac{ID: "1"},
+		RequestID: "req-1",
+	}
+	require.NoError(t, json.Unmarshal([]byte(`{"host":"gateway","port":4000,"interConnect":[{"name":"svc","version":"1","latest":"1","host":"svc","port":4001}]}`), &mesh.Awareness))
+	gateway := mesh
+	gateway.Awareness = soajsgo.Host{Host: "gateway", Port: 4000}
+
+	tt := []struct {
+		name            string
+		opts            []Option
+		ctx             context.Context
+		expectedCode    codes.Code
+		expectedData    bool
+		expectedTenant  string
+		expectedKey     []string
+		expectedInjects bool
+	}{
+		{
+			name:         "no context",
+			ctx:          context.Background(),
+			expectedCode: codes.OK,
+		},
+		{
+			name:         "strict without context",
+			opts:         []Option{Strict()},
+			ctx:          context.Background(),
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name:            "mesh forwards injected object",
+			ctx:             context.WithValue(context.Background(), soajsgo.SoajsKey, mesh),
+			expectedCode:    codes.OK,
+			expectedData:    true,
+			expectedTenant:  "TNT",
+			expectedInjects: true,
+		},
+		{
+			name:         "gateway forwards key",
+			ctx:          context.WithValue(context.Background(), soajsgo.SoajsKey, gateway),
+			expectedCode: codes.OK,
+			expectedKey:  []string{"ekey"},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := &soajsgo.Registry{Name: "test"}
+			client, health := newClient(t, reg, tc.opts...)
+
+			_, err := client.Check(tc.ctx, &grpc_health_v1.HealthCheckRequest{})
+			assert.Equal(t, tc.expectedCode, status.Code(err))
+			if err != nil {
+				return
+			}
+			assert.Equal(t, tc.expectedData, health.hasData)
+			assert.Equal(t, tc.expectedTenant, health.data.Tenant.Code)
+			assert.Equal(t, tc.expectedKey, health.md.Get(MetadataKey))
+			if tc.expectedInjects {
+				assert.Equal(t, "ikey", health.data.Tenant.Key.IKey)
+				assert.Equal(t, "1", health.data.Urac.ID)
+				assert.Equal(t, "req-1", health.data.RequestID)
+				assert.Same(t, reg, health.data.Reg)
+			}
+
+			stream, err := client.Watch(tc.ctx, &grpc_health_v1.HealthCheckRequest{})
+			require.NoError(t, err)
+			_, err = stream.Recv()
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedData, health.hasData)
+			assert.Equal(t, tc.expectedTenant, health.data.Tenant.Code)
+		})
+	}
+}
+
+func TestTarget(t *testing.T) {
+	var data soajsgo.ContextData
+	require.NoError(t, json.Unmarshal([]byte(`{"host":"gateway","port":4000,"interConnect":[{"name":"svc","version":"1","latest":"1","host":"svc","port":4001}]}`), &data.Awareness))
+	assert.Equal(t, "svc:4001", Target(data, "svc", "1"))
+	assert.Equal(t, "gateway:4000/", Target(data, "other", "1"))
+}
@@ -0,0 +1,82 @@
+// Package soajsotel implements the soajsgo.Tracer of the middleware and registry calls with OpenTelemetry.
+//
+// The middleware span continues the W3C traceparent of the incoming request, and Connect propagates the
+// traceparent of that span to the services it resolves.
+package soajsotel
+
+import (
+	"context"
+
+	soajsgo "github.com/soajs/soajs.golang"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName is the name of the OpenTelemetry tracer.
+const InstrumentationName = "github.com/soajs/soajs.golang"
+
+type (
+	tracer struct {
+		tracer trace.Tracer
+	}
+
+	span struct {
+		ctx  context.Context
+		span trace.Span
+	}
+)
+
+var traceContext = propagation.TraceContext{}
+
+// NewTracer returns a soajsgo.Tracer creating its spans with the tracer provider, e.g. otel.GetTracerProvider().
+func NewTracer(tp trace.TracerProvider) soajsgo.Tracer {
+	return &tracer{tracer: tp.Tracer(InstrumentationName)}
+}
+
+// Start implements soajsgo.Tracer.
+func (t *tracer) Start(ctx context.Context, name string, attrs ...soajsgo.Attribute) (context.Context, soajsgo.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		if traceParent := soajsgo.TraceParentFromContext(ctx); traceParent != "" {
+			ctx = traceContext.Extract(ctx, propagation.MapCarrier{soajsgo.HeaderTraceParent: traceParent})
+		}
+	}
+	kind := trace.SpanKindClient
+	if name == soajsgo.SpanMiddleware {
+		kind = trace.SpanKindServer
+	}
+	ctx, s := t.tracer.Start(ctx, name, trace.WithSpanKind(kind), trace.WithAttributes(convert(attrs)...))
+	return ctx, &span{ctx: ctx, span: s}
+}
+
+// SetAttributes implements soajsgo.Span.
+func (s *span) SetAttributes(attrs ...soajsgo.Attribute) {
+	s.span.SetAttributes(convert(attrs)...)
+}
+
+// RecordError implements soajsgo.Span.
+func (s *span) RecordError(err error) {
+	s.span.RecordError(err)
+	s.span.SetStatus(codes.Error, err.Error())
+}
+
+// End implements soajsgo.Span.
+func (s *span) End() {
+	s.span.End()
+}
+
+// TraceParent implements soajsgo.Span.
+func (s *span) TraceParent() string {
+	carrier := propagation.MapCarrier{}
+	traceContext.Inject(s.ctx, carrier)
+	return carrier.Get(soajsgo.HeaderTraceParent)
+}
+
+func convert(attrs []soajsgo.Attribute) []attribute.KeyValue {
+	kvs := make([]attribute.KeyValue, 0, len(attrs))
+	for _, a := range attrs {
+		kvs = append(kvs, attribute.String(a.Key, a.Value))
+	}
+	return kvs
+}
@@ -0,0 +1,124 @@
+package soajsotel
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	soajsgo "github.com/soajs/soajs.golang"
+	"github.com/soajs/soajs.golang/soajstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const incoming = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func newTracer(t *testing.T) (soajsgo.Tracer, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+	return NewTracer(tp), exporter
+}
+
+func findSpan(spans tracetest.SpanStubs, name string) (tracetest.SpanStub, bool) {
+	for _, s := range spans {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return tracetest.SpanStub{}, false
+}
+
+func TestMiddleware(t *testing.T) {
+	tt := []struct {
+		name           string
+		traceParent    string
+		opts           []soajsgo.MiddlewareOption
+		header         *soajstest.HeaderBuilder
+		expectedRemote bool
+		expectedStatus codes.Code
+	}{
+		{
+			name:           "continues incoming trace",
+			traceParent:    incoming,
+			header:         soajstest.NewHeader().Tenant("tid", "TNT").Application("PROD", "PROD_PCK", "app").InterConnect("mesh", "1", "1", "mesh", 4001),
+			expectedRemote: true,
+			expectedStatus: codes.Unset,
+		},
+		{
+			name:           "starts new trace",
+			header:         soajstest.NewHeader().Tenant("tid", "TNT").InterConnect("mesh", "1", "1", "mesh", 4001),
+			expectedStatus: codes.Unset,
+		},
+		{
+			name:           "records strict rejection",
+			traceParent:    incoming,
+			opts:           []soajsgo.MiddlewareOption{soajsgo.Strict()},
+			expectedRemote: true,
+			expectedStatus: codes.Error,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			tracer, exporter := newTracer(t)
+			reg := &soajsgo.Registry{Name: "svc", Environment: "dev"}
+			soajsgo.WithTracer(tracer)(reg)
+
+			var connect soajsgo.Connect
+			handler := reg.MiddlewareWith(tc.opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				data, ok := soajsgo.FromContext(r.Context())
+				require.True(t, ok)
+				connect = data.Connect("mesh", "1")
+			}))
+			req := soajstest.NewRequest(http.MethodGet, "/tenant", nil, tc.header)
+			if tc.traceParent != "" {
+				req.Header.Set(soajsgo.HeaderTraceParent, tc.traceParent)
+			}
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			span, ok := findSpan(exporter.GetSpans(), soajsgo.SpanMiddleware)
+			require.True(t, ok)
+			assert.Equal(t, trace.SpanKindServer, span.SpanKind)
+			assert.Equal(t, tc.expectedRemote, span.Parent.IsRemote())
+			if tc.expectedRemote {
+				assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext.TraceID().String())
+				assert.Equal(t, "00f067aa0ba902b7", span.Parent.SpanID().String())
+			}
+			assert.Equal(t, tc.expectedStatus, span.Status.Code)
+			assert.Contains(t, span.Attributes, attribute.String(soajsgo.AttrServiceName, "svc"))
+			assert.Contains(t, span.Attributes, attribute.String(soajsgo.AttrURLPath, "/tenant"))
+			if tc.header == nil {
+				return
+			}
+			assert.Contains(t, span.Attributes, attribute.String(soajsgo.AttrTenantCode, "TNT"))
+			assert.Equal(t, "00-"+span.SpanContext.TraceID().String()+"-"+span.SpanContext.SpanID().String()+"-01",
+				connect.Headers.TraceParent)
+		})
+	}
+}
+
+func TestRegistrySpans(t *testing.T) {
+	controller := soajstest.NewController(t)
+	controller.SetRegistryJSON("dev", []byte(`{"name":"svc","environment":"dev"}`))
+	tracer, exporter := newTracer(t)
+
+	reg, err := soajsgo.New(context.Background(), "svc", "dev", "service", false, soajsgo.WithTracer(tracer))
+	require.NoError(t, err)
+	controller.FailNext(1, http.StatusInternalServerError)
+	require.Error(t, reg.Reload())
+
+	spans := exporter.GetSpans()
+	require.Len(t, spans, 2)
+	for i, expected := range []codes.Code{codes.Unset, codes.Error} {
+		assert.Equal(t, soajsgo.SpanRegistryFetch, spans[i].Name)
+		assert.Equal(t, trace.SpanKindClient, spans[i].SpanKind)
+		assert.Equal(t, expected, spans[i].Status.Code)
+	}
+	assert.Equal(t, 2, controller.CallCount("/getRegistry"))
+}
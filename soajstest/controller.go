@@ -0,0 +1,310 @@
+// Package soajstest provides helpers to test services built with soajsgo without a SOAJS infrastructure: a fake
+// controller serving the registry API and builders for the injected object the gateway attaches to requests.
+package soajstest
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	soajsgo "github.com/soajs/soajs.golang"
+)
+
+type (
+	// Controller is a fake SOAJS controller serving /getRegistry, /register, /unregister and /daemonGroupConf. It
+	// returns the registry fixture of the requested environment, not modified when unchanged since the last fetch
+	// of the caller, and the daemon group config fixture of the requested group. It records every call and can be
+	// told to fail. It also pushes the changes of the registry fixtures as server-sent events on /registryEvents,
+	// see soajsgo.WithPushUpdates.
+	Controller struct {
+		srv    *httptest.Server
+		closed chan struct{}
+
+		mu         sync.Mutex
+		registries map[string]json.RawMessage
+		daemons    map[string]json.RawMessage
+		ts         int64
+		// updated is the ts of the last change of each environment, changed is closed on a change.
+		updated    map[string]int64
+		changed    chan struct{}
+		calls      []Call
+		failures   int
+		failStatus int
+		latency    time.Duration
+	}
+
+	// Call is a request received by the fake controller.
+	Call struct {
+		Method string
+		Path   string
+		Query  url.Values
+		Body   []byte
+	}
+
+	// response is the envelope of the registry API.
+	response struct {
+		Result  bool            `json:"result"`
+		Ts      int64           `json:"ts"`
+		Service serviceInfo     `json:"service"`
+		Data    json.RawMessage `json:"data,omitempty"`
+		Errors  *soajsgo.Errors `json:"errors,omitempty"`
+	}
+	serviceInfo struct {
+		Service string `json:"service"`
+		Type    string `json:"type"`
+		Route   string `json:"route"`
+	}
+)
+
+// NewController starts a fake controller, points the SOAJS_REGISTRY_API environment variable of the test to it
+// and stops it when the test ends.
+func NewController(t testing.TB) *Controller {
+	t.Helper()
+	c := StartController()
+	t.Cleanup(c.Close)
+	t.Setenv(soajsgo.EnvRegistryAPIAddress, c.Addr())
+	return c
+}
+
+// StartController starts a fake controller. It has to be stopped with Close.
+func StartController() *Controller {
+	c := &Controller{
+		registries: make(map[string]json.RawMessage),
+		daemons:    make(map[string]json.RawMessage),
+		updated:    make(map[string]int64),
+		changed:    make(chan struct{}),
+		closed:     make(chan struct{}),
+	}
+	c.srv = httptest.NewServer(http.HandlerFunc(c.serveHTTP))
+	return c
+}
+
+// Close stops the fake controller.
+func (c *Controller) Close() {
+	close(c.closed)
+	c.srv.Close()
+}
+
+// Addr returns the host:port of the fake controller, the format SOAJS_REGISTRY_API expects.
+func (c *Controller) Addr() string {
+	return strings.TrimPrefix(c.srv.URL, "http://")
+}
+
+// URL returns the base URL of the fake controller.
+func (c *Controller) URL() string {
+	return c.srv.URL
+}
+
+// SetRegistry sets the registry returned for the environment. Calling it again updates the registry, so the next
+// reload of a service picks up the change.
+func (c *Controller) SetRegistry(env string, reg *soajsgo.Registry) error {
+	b, err := json.Marshal(reg.Unredacted())
+	if err != nil {
+		return fmt.Errorf("could not marshal registry fixture: %v", err)
+	}
+	c.SetRegistryJSON(env, b)
+	return nil
+}
+
+// SetRegistryJSON sets the raw registry returned for the environment, e.g. a registry captured from a controller.
+// The change is pushed to the registry events of the environment.
+func (c *Controller) SetRegistryJSON(env string, raw []byte) {
+	env = strings.ToLower(env)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.registries[env] = json.RawMessage(raw)
+	// The timestamps increase strictly so that every change is newer than the registries already served.
+	c.ts = max(time.Now().UnixMilli(), c.ts+1)
+	c.updated[env] = c.ts
+	close(c.changed)
+	c.changed = make(chan struct{})
+}
+
+// SetDaemonGroupConfig sets the daemon group config returned for the group, the SOAJS_DAEMON_GRP_CONF of a daemon.
+// Calling it again updates the config, so a daemon picks up the change after the next reload of its registry.
+func (c *Controller) SetDaemonGroupConfig(group string, conf soajsgo.DaemonGroupConfig) error {
+	b, err := json.Marshal(conf)
+	if err != nil {
+		return fmt.Errorf("could not marshal daemon group config fixture: %v", err)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.daemons[group] = json.RawMessage(b)
+	return nil
+}
+
+// FailNext makes the next n calls fail with the http status.
+func (c *Controller) FailNext(n, status int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.failures = n
+	c.failStatus = status
+}
+
+// SetLatency delays every response, e.g. to test timeouts and cancellation.
+func (c *Controller) SetLatency(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.latency = d
+}
+
+// Calls returns the calls received so far.
+func (c *Controller) Calls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Call(nil), c.calls...)
+}
+
+// CallCount returns how many calls were received on path.
+func (c *Controller) CallCount(path string) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n := 0
+	for _, call := range c.calls {
+		if call.Path == path {
+			n++
+		}
+	}
+	return n
+}
+
+// Reset forgets the recorded calls and pending failures.
+func (c *Controller) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls = nil
+	c.failures = 0
+}
+
+func (c *Controller) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Method: r.Method, Path: r.URL.Path, Query: r.URL.Query(), Body: body})
+	latency := c.latency
+	fail := c.failures > 0
+	status := c.failStatus
+	if fail {
+		c.failures--
+	}
+	c.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+	if fail {
+		writeError(w, r, status, fmt.Sprintf("injected failure on %s", r.URL.Path))
+		return
+	}
+	switch r.URL.Path {
+	case "/getRegistry":
+		c.getRegistry(w, r)
+	case "/registryEvents":
+		c.registryEvents(w, r)
+	case "/register", "/unregister":
+		c.writeResponse(w, r, http.StatusOK, nil)
+	case "/daemonGroupConf":
+		c.daemonGroupConf(w, r)
+	default:
+		writeError(w, r, http.StatusNotFound, fmt.Sprintf("unknown route %s", r.URL.Path))
+	}
+}
+
+// getRegistry serves the registry fixture of the environment with the ts of its last change as ETag, answering not
+// modified when the request already has it.
+func (c *Controller) getRegistry(w http.ResponseWriter, r *http.Request) {
+	env := strings.ToLower(r.URL.Query().Get("env"))
+	c.mu.Lock()
+	reg, ok := c.registries[env]
+	etag := fmt.Sprintf(`"%d"`, c.updated[env])
+	c.mu.Unlock()
+	if !ok {
+		writeError(w, r, http.StatusOK, fmt.Sprintf("no registry fixture for environment %s", env))
+		return
+	}
+	w.Header().Set("ETag", etag)
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	c.writeResponse(w, r, http.StatusOK, reg)
+}
+
+// daemonGroupConf serves the daemon group config fixture of the group.
+func (c *Controller) daemonGroupConf(w http.ResponseWriter, r *http.Request) {
+	group := r.URL.Query().Get("grp")
+	c.mu.Lock()
+	conf, ok := c.daemons[group]
+	c.mu.Unlock()
+	if !ok {
+		writeError(w, r, http.StatusOK, fmt.Sprintf("no daemon group config fixture for group %s", group))
+		return
+	}
+	c.writeResponse(w, r, http.StatusOK, conf)
+}
+
+// registryEvents streams the ts of the changes of the registry of the environment, starting with the current one.
+func (c *Controller) registryEvents(w http.ResponseWriter, r *http.Request) {
+	env := strings.ToLower(r.URL.Query().Get("env"))
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	sent := int64(-1)
+	for {
+		c.mu.Lock()
+		ts, changed := c.updated[env], c.changed
+		c.mu.Unlock()
+		if ts != sent {
+			_, _ = fmt.Fprintf(w, "event: registry\ndata: {\"ts\":%d}\n\n", ts)
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+			sent = ts
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+func (c *Controller) writeResponse(w http.ResponseWriter, r *http.Request, status int, data json.RawMessage) {
+	c.mu.Lock()
+	ts := c.ts
+	c.mu.Unlock()
+	writeJSON(w, status, response{
+		Result:  true,
+		Ts:      ts,
+		Service: serviceInfo{Service: "CONTROLLER", Type: "rest", Route: r.URL.Path},
+		Data:    data,
+	})
+}
+
+func writeError(w http.ResponseWriter, r *http.Request, status int, msg string) {
+	writeJSON(w, status, response{
+		Service: serviceInfo{Service: "CONTROLLER", Type: "rest", Route: r.URL.Path},
+		Errors: &soajsgo.Errors{
+			Codes:   []int{status},
+			Details: []soajsgo.Error{{Code: status, Message: msg}},
+		},
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
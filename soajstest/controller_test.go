@@ -0,0 +1,165 @@
+package soajstest
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	soajsgo "github.com/soajs/soajs.golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestController(t *testing.T) {
+	c := NewController(t)
+	require.NoError(t, c.SetRegistry("dev", &soajsgo.Registry{
+		Name:        "test",
+		Environment: "dev",
+		CoreDBs: map[string]soajsgo.Database{"main": {
+			Name:        "main",
+			Credentials: soajsgo.Credentials{Username: "admin", Password: "pass"},
+		}},
+	}))
+
+	reg, err := soajsgo.New(context.Background(), "test", "dev", "service", false)
+	require.NoError(t, err)
+	db, err := reg.Database("main")
+	require.NoError(t, err)
+	assert.Equal(t, "pass", db.Credentials.Password)
+
+	// dynamic update
+	c.SetRegistryJSON("dev", []byte(`{"name":"test","environment":"dev","services":{"urac":{"group":"soajs","port":4001}}}`))
+	require.NoError(t, reg.Reload())
+	svc, err := reg.Service("urac")
+	require.NoError(t, err)
+	assert.Equal(t, 4001, svc.Port)
+	_, err = reg.Database("main")
+	assert.Error(t, err)
+
+	// failure injection
+	c.FailNext(1, http.StatusInternalServerError)
+	assert.Error(t, reg.Reload())
+	assert.NoError(t, reg.Reload())
+	stats := reg.ReloadStats()
+	assert.Equal(t, uint64(4), stats.Reloads)
+	assert.Equal(t, uint64(1), stats.Unchanged, "the registry did not change since the last reload")
+	assert.Equal(t, uint64(1), stats.Failures)
+
+	// unknown environment
+	_, err = soajsgo.New(context.Background(), "test", "stg", "service", false)
+	assert.EqualError(t, err, "unable to register service at gateway: [200] [no registry fixture for environment stg]")
+
+	calls := c.Calls()
+	require.Len(t, calls, 5)
+	assert.Equal(t, "/getRegistry", calls[0].Path)
+	assert.Equal(t, "dev", calls[0].Query.Get("env"))
+	assert.Equal(t, "test", calls[0].Query.Get("serviceName"))
+	assert.Equal(t, "service", calls[0].Query.Get("type"))
+	assert.Equal(t, 5, c.CallCount("/getRegistry"))
+
+	c.Reset()
+	assert.Empty(t, c.Calls())
+}
+
+func TestController_Register(t *testing.T) {
+	c := NewController(t)
+	require.NoError(t, c.SetRegistry("dev", &soajsgo.Registry{Name: "test", Environment: "dev"}))
+	t.Setenv(soajsgo.EnvSoajsEnv, "dev")
+	t.Setenv(soajsgo.EnvDeployManual, "true")
+
+	config := soajsgo.Config{
+		ServiceName:    "test",
+		ServiceGroup:   "group",
+		ServicePort:    4000,
+		Type:           "service",
+		ServiceVersion: "1",
+	}
+	config.Maintenance.Readiness = "/heartbeat"
+	config.Maintenance.Port.Type = "inherit"
+	config.RegisterAPI("GET", "/users", soajsgo.APIInfo{Label: "List users", Group: "User"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	_, err := soajsgo.NewFromConfig(ctx, config)
+	require.NoError(t, err)
+	require.Equal(t, 1, c.CallCount("/register"))
+	calls := c.Calls()
+	assert.Contains(t, string(calls[len(calls)-1].Body), `"name":"test"`)
+	assert.Contains(t, string(calls[len(calls)-1].Body), `"apiList":[{"m":"get","v":"/users","l":"List users","group":"User"}]`)
+}
+
+func TestController_RegistryEvents(t *testing.T) {
+	c := NewController(t)
+	c.SetRegistryJSON("dev", []byte(`{"name":"test","environment":"dev","services":{"urac":{"port":4001}}}`))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reg, err := soajsgo.New(ctx, "test", "dev", "service", true, soajsgo.WithPushUpdates(time.Millisecond), soajsgo.WithLogOutput(io.Discard))
+	require.NoError(t, err)
+	require.Eventually(t, func() bool { return c.CallCount("/registryEvents") == 1 }, 5*time.Second, time.Millisecond)
+
+	c.SetRegistryJSON("stg", []byte(`{"name":"test","environment":"stg"}`))
+	c.SetRegistryJSON("dev", []byte(`{"name":"test","environment":"dev","services":{"urac":{"port":4002}}}`))
+	require.Eventually(t, func() bool {
+		svc, err := reg.Service("urac")
+		return err == nil && svc.Port == 4002
+	}, 5*time.Second, time.Millisecond)
+	assert.Equal(t, 2, c.CallCount("/getRegistry"), "only the changes of the environment must reload")
+}
+
+func TestController_DaemonGroupConf(t *testing.T) {
+	c := NewController(t)
+	c.SetRegistryJSON("dev", []byte(`{"name":"cleaner","environment":"dev"}`))
+	require.NoError(t, c.SetDaemonGroupConfig("grp", soajsgo.DaemonGroupConfig{
+		Group:    "grp",
+		Status:   1,
+		Type:     "interval",
+		Interval: 3600000,
+		Jobs:     map[string]soajsgo.DaemonJob{"job": {}},
+	}))
+	t.Setenv(soajsgo.EnvSoajsEnv, "dev")
+	t.Setenv(soajsgo.EnvDaemonGroupConf, "grp")
+	t.Setenv(soajsgo.EnvDeployManual, "false")
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := ln.Addr().(*net.TCPAddr).Port
+	require.NoError(t, ln.Close())
+	config := soajsgo.Config{
+		ServiceName:    "cleaner",
+		ServiceGroup:   "group",
+		ServicePort:    port,
+		Type:           soajsgo.ServiceTypeDaemon,
+		ServiceVersion: "1",
+	}
+	config.Maintenance.Readiness = "/heartbeat"
+	config.Maintenance.Port.Type = "inherit"
+
+	ran := make(chan struct{}, 1)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- soajsgo.RunDaemon(ctx, config, map[string]soajsgo.JobFunc{"job": func(context.Context) error {
+			ran <- struct{}{}
+			return nil
+		}}, soajsgo.WithShutdownTimeout(time.Second))
+	}()
+	select {
+	case <-ran:
+	case err := <-done:
+		t.Fatalf("daemon stopped before its job ran: %v", err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("daemon job did not run")
+	}
+	cancel()
+	require.NoError(t, <-done)
+	assert.Equal(t, 1, c.CallCount("/daemonGroupConf"))
+	assert.Equal(t, "cleaner", c.Calls()[1].Query.Get("daemon"))
+
+	// unknown group
+	d := soajsgo.NewDaemon(&soajsgo.Registry{Name: "cleaner", Environment: "dev"}, "other", nil)
+	err = d.Run(context.Background())
+	assert.EqualError(t, err, "could not fetch daemon group config: call failed with status 200: [200] no daemon group config fixture for group other")
+}
@@ -0,0 +1,128 @@
+package soajstest
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+
+	soajsgo "github.com/soajs/soajs.golang"
+)
+
+// HeaderBuilder builds the injected object the SOAJS gateway attaches to the requests it forwards to a service.
+type HeaderBuilder struct {
+	data soajsgo.ContextData
+}
+
+// NewHeader returns an empty header builder.
+func NewHeader() *HeaderBuilder {
+	return &HeaderBuilder{}
+}
+
+// Tenant sets the tenant id and code.
+func (b *HeaderBuilder) Tenant(id, code string) *HeaderBuilder {
+	b.data.Tenant.ID = id
+	b.data.Tenant.Code = code
+	return b
+}
+
+// Key sets the internal and external keys of the tenant.
+func (b *HeaderBuilder) Key(iKey, eKey string) *HeaderBuilder {
+	b.data.Tenant.Key.IKey = iKey
+	b.data.Tenant.Key.EKey = eKey
+	return b
+}
+
+// Application sets the product, package and application id the call is made with.
+func (b *HeaderBuilder) Application(product, pack, appID string) *HeaderBuilder {
+	b.data.Tenant.Application.Product = product
+	b.data.Tenant.Application.Package = pack
+	b.data.Tenant.Application.AppID = appID
+	return b
+}
+
+// ACL sets the access control list of the application.
+func (b *HeaderBuilder) ACL(acl map[string]interface{}) *HeaderBuilder {
+	b.data.Tenant.Application.ACL = acl
+	return b
+}
+
+// PackageACL sets the access control list of the package.
+func (b *HeaderBuilder) PackageACL(acl map[string]interface{}) *HeaderBuilder {
+	b.data.Tenant.Application.PackageACL = acl
+	return b
+}
+
+// Urac sets the logged in user.
+func (b *HeaderBuilder) Urac(id, username string, groups ...string) *HeaderBuilder {
+	b.data.Urac.ID = id
+	b.data.Urac.Username = username
+	b.data.Urac.Groups = groups
+	return b
+}
+
+// ServicesConfig sets the services configuration of the key.
+func (b *HeaderBuilder) ServicesConfig(config map[string]interface{}) *HeaderBuilder {
+	b.data.ServicesConfig = config
+	return b
+}
+
+// Device sets the device of the caller.
+func (b *HeaderBuilder) Device(device string) *HeaderBuilder {
+	b.data.Device = device
+	return b
+}
+
+// Geo sets the geo information of the caller.
+func (b *HeaderBuilder) Geo(geo map[string]string) *HeaderBuilder {
+	b.data.Geo = geo
+	return b
+}
+
+// Gateway sets the host and port of the gateway, used by Connect when a service is not in the mesh.
+func (b *HeaderBuilder) Gateway(host string, port int) *HeaderBuilder {
+	b.data.Awareness.Host = host
+	b.data.Awareness.Port = port
+	return b
+}
+
+// InterConnect adds a service to the InterConnect mesh. Latest is the latest version of the service.
+func (b *HeaderBuilder) InterConnect(name, version, latest, host string, port int) *HeaderBuilder {
+	b.data.Awareness.InterConnect = append(b.data.Awareness.InterConnect, struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+		Host    string `json:"host"`
+		Port    int    `json:"port"`
+		Latest  string `json:"latest"`
+	}{Name: name, Version: version, Host: host, Port: port, Latest: latest})
+	return b
+}
+
+// ContextData returns the context data the middleware injects for the header, without registry.
+func (b *HeaderBuilder) ContextData() soajsgo.ContextData {
+	return b.data
+}
+
+// Build returns the value of the soajsinjectobj header. It panics when the object cannot be encoded, e.g. when
+// an ACL holds a value JSON does not support.
+func (b *HeaderBuilder) Build() string {
+	obj, err := b.data.InjectObj()
+	if err != nil {
+		panic(err)
+	}
+	return obj
+}
+
+// Apply sets the soajsinjectobj header of the request.
+func (b *HeaderBuilder) Apply(r *http.Request) {
+	r.Header.Set(soajsgo.HeaderInjectObj, b.Build())
+}
+
+// NewRequest returns an incoming server request, as httptest.NewRequest does, carrying the injected object built
+// by b. A nil builder returns a request without injected object.
+func NewRequest(method, target string, body io.Reader, b *HeaderBuilder) *http.Request {
+	r := httptest.NewRequest(method, target, body)
+	if b != nil {
+		b.Apply(r)
+	}
+	return r
+}
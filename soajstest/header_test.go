@@ -0,0 +1,44 @@
+package soajstest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	soajsgo "github.com/soajs/soajs.golang"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHeaderBuilder(t *testing.T) {
+	acl := map[string]interface{}{"svc": map[string]interface{}{"apisPermission": "restricted", "apis": map[string]interface{}{"/users": map[string]interface{}{"access": true}}}}
+	header := NewHeader().
+		Tenant("t1", "TNT").
+		Key("ikey", "ekey").
+		Application("PROD", "PROD_PACK", "app").
+		ACL(acl).
+		Urac("u1", "john", "admin").
+		ServicesConfig(map[string]interface{}{"svc": map[string]interface{}{"limit": 1.0}}).
+		Device("iPhone").
+		Geo(map[string]string{"ip": "10.0.0.1"}).
+		Gateway("gateway", 4000).
+		InterConnect("other", "1", "1", "other", 4001)
+
+	reg := &soajsgo.Registry{Name: "test"}
+	var got soajsgo.ContextData
+	handler := reg.MiddlewareWith(soajsgo.Strict(), soajsgo.WithACL("svc", "1"))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, _ = soajsgo.FromContext(r.Context())
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, NewRequest(http.MethodGet, "/users", nil, header))
+	require.Equal(t, http.StatusOK, rec.Code)
+	expected := header.ContextData()
+	expected.Reg = reg
+	assert.Equal(t, expected, got)
+	assert.Equal(t, "other:4001", got.Connect("other", "1").Host)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, NewRequest(http.MethodGet, "/users", nil, nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+}
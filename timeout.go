@@ -0,0 +1,261 @@
+package soajsgo
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrRequestTimeout is returned when renewing the timeout of a request that already timed out.
+	ErrRequestTimeout = errors.New("request timed out")
+	// ErrTimeoutRenewals is returned when the timeout of a request was renewed RequestTimeoutRenewal times already.
+	ErrTimeoutRenewals = errors.New("request timeout renewals exhausted")
+)
+
+type (
+	// timeoutContext is the context of a request with a deadline that can be renewed.
+	timeoutContext struct {
+		context.Context
+		timeout time.Duration
+		done    chan struct{}
+		stop    func() bool
+
+		mu        sync.Mutex
+		timer     *time.Timer
+		deadline  time.Time
+		renewals  int
+		canceled  bool
+		err       error
+		onTimeout func()
+	}
+
+	// timeoutWriter holds the response of a request until the handler writes it, so the timeout response can be
+	// written instead when the handler is too slow. A hijacked connection, e.g. a websocket, is left to the handler.
+	timeoutWriter struct {
+		w http.ResponseWriter
+		h http.Header
+
+		mu          sync.Mutex
+		wroteHeader bool
+		timedOut    bool
+		hijacked    bool
+	}
+)
+
+// WithRequestTimeout sets a deadline of timeout on the context of every request. The handler can renew it up to
+// renewals times with ContextData.RenewTimeout. A request exceeding its deadline is answered with the SOAJS
+// timeout error, unless the handler already started its response, and later writes of the handler fail with
+// http.ErrHandlerTimeout. Run applies the RequestTimeout and RequestTimeoutRenewal of the config.
+func WithRequestTimeout(timeout time.Duration, renewals int) MiddlewareOption {
+	return func(o *middlewareOptions) {
+		o.timeout = timeout
+		o.timeoutRenewals = renewals
+	}
+}
+
+// RenewTimeout extends the deadline of the request by the request timeout, e.g. for a long running handler that is
+// still making progress. It returns ErrTimeoutRenewals once the renewals are exhausted and ErrRequestTimeout when
+// the request already timed out. It does nothing when no request timeout is set.
+func (c ContextData) RenewTimeout() error {
+	if c.timeout == nil {
+		return nil
+	}
+	return c.timeout.renew()
+}
+
+// requestTimeout returns the request timeout of the config, in seconds.
+func (c *Config) requestTimeout() time.Duration {
+	return time.Duration(c.RequestTimeout) * time.Second
+}
+
+// withTimeout returns a context of parent timing out after timeout, calling onTimeout first. stop releases it,
+// waiting for onTimeout when the timeout is being handled, so nothing is written once the handler returned.
+func withTimeout(parent context.Context, timeout time.Duration, renewals int, onTimeout func()) (*timeoutContext, func()) {
+	ctx := &timeoutContext{
+		Context:   parent,
+		timeout:   timeout,
+		done:      make(chan struct{}),
+		deadline:  time.Now().Add(timeout),
+		renewals:  renewals,
+		onTimeout: onTimeout,
+	}
+	if d, ok := parent.Deadline(); ok && d.Before(ctx.deadline) {
+		ctx.deadline = d
+	}
+	ctx.mu.Lock()
+	ctx.timer = time.AfterFunc(timeout, func() { ctx.cancel(context.DeadlineExceeded) })
+	ctx.stop = context.AfterFunc(parent, func() { ctx.cancel(parent.Err()) })
+	ctx.mu.Unlock()
+	return ctx, func() {
+		ctx.stop()
+		ctx.cancel(context.Canceled)
+		<-ctx.done
+	}
+}
+
+// Deadline implements context.Context.
+func (c *timeoutContext) Deadline() (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.deadline, true
+}
+
+// Done implements context.Context.
+func (c *timeoutContext) Done() <-chan struct{} {
+	return c.done
+}
+
+// Err implements context.Context.
+func (c *timeoutContext) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.err
+}
+
+func (c *timeoutContext) renew() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.canceled {
+		return ErrRequestTimeout
+	}
+	if c.renewals <= 0 {
+		return ErrTimeoutRenewals
+	}
+	if !c.timer.Stop() {
+		return ErrRequestTimeout
+	}
+	c.renewals--
+	c.deadline = time.Now().Add(c.timeout)
+	if d, ok := c.Context.Deadline(); ok && d.Before(c.deadline) {
+		c.deadline = d
+	}
+	c.timer.Reset(c.timeout)
+	return nil
+}
+
+func (c *timeoutContext) cancel(err error) {
+	c.mu.Lock()
+	if c.canceled {
+		c.mu.Unlock()
+		return
+	}
+	c.canceled = true
+	c.timer.Stop()
+	c.mu.Unlock()
+	// the timeout response is written before the handler sees the context done
+	if err == context.DeadlineExceeded && c.onTimeout != nil {
+		c.onTimeout()
+	}
+	c.mu.Lock()
+	c.err = err
+	close(c.done)
+	c.mu.Unlock()
+}
+
+func newTimeoutWriter(w http.ResponseWriter) *timeoutWriter {
+	return &timeoutWriter{w: w, h: make(http.Header)}
+}
+
+// Header implements http.ResponseWriter.
+func (tw *timeoutWriter) Header() http.Header {
+	return tw.h
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (tw *timeoutWriter) WriteHeader(status int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut || tw.wroteHeader {
+		return
+	}
+	tw.writeHeader(status)
+}
+
+// Write implements http.ResponseWriter.
+func (tw *timeoutWriter) Write(b []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	if !tw.wroteHeader {
+		tw.writeHeader(http.StatusOK)
+	}
+	return tw.w.Write(b)
+}
+
+// Flush implements http.Flusher.
+func (tw *timeoutWriter) Flush() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return
+	}
+	if !tw.wroteHeader {
+		tw.writeHeader(http.StatusOK)
+	}
+	if f, ok := tw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack implements http.Hijacker. The timeout response is not written once the connection is hijacked, the
+// request context still times out.
+func (tw *timeoutWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	if tw.timedOut {
+		return nil, nil, http.ErrHandlerTimeout
+	}
+	h, ok := tw.w.(http.Hijacker)
+	if !ok {
+		return nil, nil, http.ErrNotSupported
+	}
+	conn, rw, err := h.Hijack()
+	if err == nil {
+		tw.hijacked = true
+	}
+	return conn, rw, err
+}
+
+// Push implements http.Pusher.
+func (tw *timeoutWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := tw.w.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
+
+// Unwrap returns the underlying response writer, for http.ResponseController.
+func (tw *timeoutWriter) Unwrap() http.ResponseWriter {
+	return tw.w
+}
+
+func (tw *timeoutWriter) writeHeader(status int) {
+	dst := tw.w.Header()
+	for k, v := range tw.h {
+		dst[k] = v
+	}
+	tw.wroteHeader = true
+	tw.w.WriteHeader(status)
+}
+
+// timeout stops the writes of the handler and writes the timeout response if the handler did not start its own.
+func (tw *timeoutWriter) timeout() {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+	tw.timedOut = true
+	if tw.wroteHeader || tw.hijacked {
+		return
+	}
+	tw.wroteHeader = true
+	WriteError(tw.w, CodeTimeout, ErrRequestTimeout.Error())
+	if f, ok := tw.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
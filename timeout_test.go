@@ -0,0 +1,191 @@
+package soajsgo
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	const timeout = 50 * time.Millisecond
+	tt := []struct {
+		name           string
+		opts           []MiddlewareOption
+		handler        func(t *testing.T, w http.ResponseWriter, r *http.Request)
+		expectedStatus int
+		expectedCode   int
+	}{
+		{
+			name: "fast handler",
+			opts: []MiddlewareOption{WithRequestTimeout(timeout, 0)},
+			handler: func(t *testing.T, w http.ResponseWriter, r *http.Request) {
+				deadline, ok := r.Context().Deadline()
+				assert.True(t, ok)
+				assert.WithinDuration(t, time.Now().Add(timeout), deadline, timeout)
+				w.Header().Set("X-Custom", "1")
+				w.WriteHeader(http.StatusCreated)
+			},
+			expectedStatus: http.StatusCreated,
+		},
+		{
+			name: "slow handler times out",
+			opts: []MiddlewareOption{WithRequestTimeout(timeout, 0)},
+			handler: func(t *testing.T, w http.ResponseWriter, r *http.Request) {
+				<-r.Context().Done()
+				assert.Equal(t, context.DeadlineExceeded, r.Context().Err())
+				c, _ := FromContext(r.Context())
+				assert.Equal(t, ErrRequestTimeout, c.RenewTimeout())
+				_, err := w.Write([]byte("late"))
+				assert.Equal(t, http.ErrHandlerTimeout, err)
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedCode:   CodeTimeout,
+		},
+		{
+			name: "renewed timeout",
+			opts: []MiddlewareOption{WithRequestTimeout(timeout, 2)},
+			handler: func(t *testing.T, w http.ResponseWriter, r *http.Request) {
+				c, ok := FromContext(r.Context())
+				require.True(t, ok)
+				for i := 0; i < 2; i++ {
+					time.Sleep(timeout / 2)
+					require.NoError(t, c.RenewTimeout())
+				}
+				assert.Equal(t, ErrTimeoutRenewals, c.RenewTimeout())
+				time.Sleep(timeout / 2)
+				assert.NoError(t, r.Context().Err())
+				w.WriteHeader(http.StatusOK)
+			},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name: "started response is kept",
+			opts: []MiddlewareOption{WithRequestTimeout(timeout, 0)},
+			handler: func(t *testing.T, w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusAccepted)
+				<-r.Context().Done()
+				_, err := w.Write([]byte("late"))
+				assert.Equal(t, http.ErrHandlerTimeout, err)
+			},
+			expectedStatus: http.StatusAccepted,
+		},
+		{
+			name: "without timeout",
+			handler: func(t *testing.T, w http.ResponseWriter, r *http.Request) {
+				_, ok := r.Context().Deadline()
+				assert.False(t, ok)
+				c, _ := FromContext(r.Context())
+				assert.NoError(t, c.RenewTimeout())
+			},
+			expectedStatus: http.StatusOK,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := &Registry{}
+			WithLogOutput(io.Discard)(reg)
+			handler := reg.MiddlewareWith(tc.opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				tc.handler(t, w, r)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.Header.Set(HeaderInjectObj, `{"tenant":{"code":"TNT"}}`)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			assert.Equal(t, tc.expectedStatus, rec.Code)
+			if tc.expectedCode == 0 {
+				return
+			}
+			var res Response
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+			assert.Equal(t, []int{tc.expectedCode}, res.Errors.Codes)
+		})
+	}
+}
+
+func TestRequestTimeoutParent(t *testing.T) {
+	parent, cancel := context.WithCancel(context.Background())
+	var timedOut bool
+	ctx, stop := withTimeout(parent, time.Hour, 1, func() { timedOut = true })
+	defer stop()
+	cancel()
+	<-ctx.Done()
+	assert.Equal(t, context.Canceled, ctx.Err())
+	assert.False(t, timedOut)
+	assert.Equal(t, ErrRequestTimeout, ctx.renew())
+}
+
+func TestRequestTimeoutStopWaitsForTimeout(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	var mu sync.Mutex
+	var timedOut bool
+	_, stop := withTimeout(context.Background(), time.Millisecond, 0, func() {
+		close(started)
+		<-release
+		mu.Lock()
+		timedOut = true
+		mu.Unlock()
+	})
+	<-started
+
+	// the handler returns while the timeout is being handled
+	stopped := make(chan struct{})
+	go func() {
+		stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		t.Fatal("stop returned before the timeout was handled")
+	case <-time.After(20 * time.Millisecond):
+	}
+	close(release)
+	<-stopped
+	mu.Lock()
+	defer mu.Unlock()
+	assert.True(t, timedOut)
+}
+
+// hijackRecorder is a response recorder whose connection can be hijacked.
+type hijackRecorder struct {
+	*httptest.ResponseRecorder
+	hijacked bool
+}
+
+func (r *hijackRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	r.hijacked = true
+	return nil, nil, nil
+}
+
+func TestTimeoutWriter_Hijack(t *testing.T) {
+	rec := &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	tw := newTimeoutWriter(rec)
+	_, _, err := http.NewResponseController(tw).Hijack()
+	require.NoError(t, err)
+	assert.True(t, rec.hijacked)
+	tw.timeout()
+	assert.False(t, rec.Flushed, "no timeout response on a hijacked connection")
+	assert.Empty(t, rec.Body.String())
+
+	tw = newTimeoutWriter(httptest.NewRecorder())
+	_, _, err = tw.Hijack()
+	assert.Equal(t, http.ErrNotSupported, err)
+	assert.Equal(t, http.ErrNotSupported, tw.Push("/style.css", nil))
+
+	rec = &hijackRecorder{ResponseRecorder: httptest.NewRecorder()}
+	tw = newTimeoutWriter(rec)
+	tw.timeout()
+	_, _, err = tw.Hijack()
+	assert.Equal(t, http.ErrHandlerTimeout, err)
+	assert.False(t, rec.hijacked)
+}
@@ -0,0 +1,154 @@
+package soajsgo
+
+import (
+	"context"
+	"strings"
+)
+
+// HeaderTraceParent is the W3C trace context header propagated by the middleware and Connect.
+const HeaderTraceParent = "traceparent"
+
+// Names of the spans. The middleware span is the server span of a request, the registry spans are client calls to
+// the controller.
+const (
+	SpanMiddleware         = "soajs.middleware"
+	SpanRegistryFetch      = "soajs.registry.fetch"
+	SpanRegistryRegister   = "soajs.registry.register"
+	SpanRegistryUnregister = "soajs.registry.unregister"
+	SpanDaemonGroupConfig  = "soajs.daemon.config"
+)
+
+// Attributes set on the spans.
+const (
+	AttrServiceName        = "soajs.service.name"
+	AttrEnv                = "soajs.env"
+	AttrTenantID           = "soajs.tenant.id"
+	AttrTenantCode         = "soajs.tenant.code"
+	AttrApplicationProduct = "soajs.application.product"
+	AttrApplicationPackage = "soajs.application.package"
+	AttrApplicationID      = "soajs.application.id"
+	AttrHTTPMethod         = "http.request.method"
+	AttrURLPath            = "url.path"
+	AttrHTTPStatus         = "http.response.status_code"
+)
+
+type (
+	// Tracer starts the spans of the middleware and of the registry calls. soajsotel provides an OpenTelemetry
+	// implementation.
+	Tracer interface {
+		// Start starts a span, child of the span of ctx or, when ctx has none, of the remote parent returned by
+		// TraceParentFromContext.
+		Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+	}
+
+	// Span is a span started by a Tracer.
+	Span interface {
+		SetAttributes(attrs ...Attribute)
+		RecordError(err error)
+		End()
+		// TraceParent returns the W3C traceparent header value identifying the span.
+		TraceParent() string
+	}
+
+	// Attribute is a key value pair describing a span.
+	Attribute struct {
+		Key   string
+		Value string
+	}
+
+	// traceParentKey is the context key of the remote traceparent.
+	traceParentKey struct{}
+
+	// nopSpan is the span started without tracer. It propagates the remote parent unchanged.
+	nopSpan struct {
+		traceParent string
+	}
+)
+
+// WithTracer records spans for the middleware and the registry calls of the registry.
+func WithTracer(t Tracer) RegistryOption {
+	return func(reg *Registry) {
+		reg.tracer = t
+	}
+}
+
+// ContextWithTraceParent returns a context carrying the remote traceparent, e.g. the header of an incoming request.
+// Invalid values are ignored.
+func ContextWithTraceParent(ctx context.Context, traceParent string) context.Context {
+	if !validTraceParent(traceParent) {
+		return ctx
+	}
+	return context.WithValue(ctx, traceParentKey{}, traceParent)
+}
+
+// TraceParentFromContext returns the remote traceparent of the context, if any.
+func TraceParentFromContext(ctx context.Context) string {
+	traceParent, _ := ctx.Value(traceParentKey{}).(string)
+	return traceParent
+}
+
+// validTraceParent checks the version-traceid-parentid-flags format of the W3C trace context.
+func validTraceParent(v string) bool {
+	parts := strings.Split(v, "-")
+	if len(parts) < 4 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return false
+	}
+	for i, size := range []int{2, 32, 16, 2} {
+		if len(parts[i]) != size || !isLowerHex(parts[i]) {
+			return false
+		}
+	}
+	return strings.Trim(parts[1], "0") != "" && strings.Trim(parts[2], "0") != ""
+}
+
+func isLowerHex(s string) bool {
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func (reg *Registry) startSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	if reg == nil || reg.tracer == nil {
+		return ctx, nopSpan{traceParent: TraceParentFromContext(ctx)}
+	}
+	return reg.tracer.Start(ctx, name, append(reg.spanAttributes(), attrs...)...)
+}
+
+// spanAttributes returns the attributes identifying the service on every span. The service name is the one the
+// registry was created for, not the one returned by the controller.
+func (reg *Registry) spanAttributes() []Attribute {
+	reg.mu.RLock()
+	defer reg.mu.RUnlock()
+	name := reg.serviceName
+	if name == "" {
+		name = reg.Name
+	}
+	return []Attribute{{AttrServiceName, name}, {AttrEnv, reg.Environment}}
+}
+
+// spanAttributes returns the tenant and application attributes of the request.
+func (c ContextData) spanAttributes() []Attribute {
+	return []Attribute{
+		{AttrTenantID, c.Tenant.ID},
+		{AttrTenantCode, c.Tenant.Code},
+		{AttrApplicationProduct, c.Tenant.Application.Product},
+		{AttrApplicationPackage, c.Tenant.Application.Package},
+		{AttrApplicationID, c.Tenant.Application.AppID},
+	}
+}
+
+// endSpan records err, if any, and ends the span.
+func endSpan(span Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+	}
+	span.End()
+}
+
+func (nopSpan) SetAttributes(...Attribute) {}
+func (nopSpan) RecordError(error)          {}
+func (nopSpan) End()                       {}
+func (s nopSpan) TraceParent() string      { return s.traceParent }
@@ -0,0 +1,210 @@
+package soajsgo
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+type (
+	fakeTracer struct {
+		mu    sync.Mutex
+		spans []*fakeSpan
+	}
+	fakeSpan struct {
+		name   string
+		parent string
+		attrs  map[string]string
+		err    error
+		ended  bool
+	}
+)
+
+func (t *fakeTracer) Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	s := &fakeSpan{name: name, parent: TraceParentFromContext(ctx), attrs: map[string]string{}}
+	s.SetAttributes(attrs...)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.spans = append(t.spans, s)
+	return ctx, s
+}
+
+func (t *fakeTracer) span(name string) *fakeSpan {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, s := range t.spans {
+		if s.name == name {
+			return s
+		}
+	}
+	return nil
+}
+
+func (s *fakeSpan) SetAttributes(attrs ...Attribute) {
+	for _, a := range attrs {
+		s.attrs[a.Key] = a.Value
+	}
+}
+func (s *fakeSpan) RecordError(err error) { s.err = err }
+func (s *fakeSpan) End()                  { s.ended = true }
+func (s *fakeSpan) TraceParent() string {
+	return "00-4bf92f3577b34da6a3ce929d0e0e4736-" + strings.Repeat("a", 16) + "-01"
+}
+
+func TestValidTraceParent(t *testing.T) {
+	tt := []struct {
+		name     string
+		value    string
+		expected bool
+	}{
+		{name: "valid", value: testTraceParent, expected: true},
+		{name: "empty", value: ""},
+		{name: "upper case", value: strings.ToUpper(testTraceParent)},
+		{name: "zero trace id", value: "00-00000000000000000000000000000000-00f067aa0ba902b7-01"},
+		{name: "zero parent id", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"},
+		{name: "forbidden version", value: "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"},
+		{name: "version 00 with extra field", value: testTraceParent + "-00"},
+		{name: "future version with extra field", value: "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-00", expected: true},
+		{name: "short parent id", value: "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa-01"},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, validTraceParent(tc.value))
+		})
+	}
+}
+
+func TestMiddlewareTracing(t *testing.T) {
+	header := `{"tenant":{"id":"tid","code":"TNT"},"application":{"product":"PROD","package":"PROD_PCK","appId":"app"},` +
+		`"awareness":{"host":"gateway","port":4000,"interConnect":[{"name":"mesh","version":"1","latest":"1","host":"mesh","port":4001}]}}`
+	tt := []struct {
+		name                string
+		tracer              *fakeTracer
+		traceParent         string
+		header              string
+		opts                []MiddlewareOption
+		expectedTraceParent string
+		expectedStatus      string
+		expectedErr         bool
+	}{
+		{
+			name:                "without tracer propagates incoming traceparent",
+			traceParent:         testTraceParent,
+			header:              header,
+			expectedTraceParent: testTraceParent,
+		},
+		{
+			name:        "without tracer ignores invalid traceparent",
+			traceParent: "invalid",
+			header:      header,
+		},
+		{
+			name:                "tracer span is propagated",
+			tracer:              &fakeTracer{},
+			traceParent:         testTraceParent,
+			header:              header,
+			expectedTraceParent: (&fakeSpan{}).TraceParent(),
+		},
+		{
+			name:           "strict rejection is recorded",
+			tracer:         &fakeTracer{},
+			opts:           []MiddlewareOption{Strict()},
+			expectedStatus: "401",
+			expectedErr:    true,
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			reg := &Registry{Name: "svc", Environment: "dev"}
+			if tc.tracer != nil {
+				WithTracer(tc.tracer)(reg)
+			}
+			var connects []Connect
+			handler := reg.MiddlewareWith(tc.opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				c, ok := FromContext(r.Context())
+				require.True(t, ok)
+				assert.Equal(t, tc.expectedTraceParent, c.TraceParent)
+				connects = append(connects, c.Connect("mesh", "1"), c.Connect("other", "1"))
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/tenant", nil)
+			req.Header.Set(HeaderTraceParent, tc.traceParent)
+			req.Header.Set(HeaderInjectObj, tc.header)
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			for _, c := range connects {
+				assert.Equal(t, tc.expectedTraceParent, c.Headers.TraceParent)
+			}
+			if tc.tracer == nil {
+				return
+			}
+			span := tc.tracer.span(SpanMiddleware)
+			require.NotNil(t, span)
+			assert.True(t, span.ended)
+			assert.Equal(t, tc.traceParent, span.parent)
+			assert.Equal(t, "svc", span.attrs[AttrServiceName])
+			assert.Equal(t, "dev", span.attrs[AttrEnv])
+			assert.Equal(t, http.MethodGet, span.attrs[AttrHTTPMethod])
+			assert.Equal(t, "/tenant", span.attrs[AttrURLPath])
+			assert.Equal(t, tc.expectedStatus, span.attrs[AttrHTTPStatus])
+			assert.Equal(t, tc.expectedErr, span.err != nil)
+			if !tc.expectedErr {
+				assert.Equal(t, "TNT", span.attrs[AttrTenantCode])
+				assert.Equal(t, "tid", span.attrs[AttrTenantID])
+				assert.Equal(t, "PROD", span.attrs[AttrApplicationProduct])
+				assert.Equal(t, "PROD_PCK", span.attrs[AttrApplicationPackage])
+				assert.Equal(t, "app", span.attrs[AttrApplicationID])
+			}
+		})
+	}
+}
+
+func TestRegistryTracing(t *testing.T) {
+	var mu sync.Mutex
+	traceParents := map[string]string{}
+	controller := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		traceParents[r.URL.Path] = r.Header.Get(HeaderTraceParent)
+		mu.Unlock()
+		if r.URL.Path == "/unregister" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write([]byte(`{"result":true,"data":{"name":"controller","environment":"dev"}}`))
+	}))
+	defer controller.Close()
+	t.Setenv(EnvRegistryAPIAddress, strings.TrimPrefix(controller.URL, "http://"))
+	t.Setenv(EnvDeployManual, "true")
+	addr, err := registryAddress()
+	require.NoError(t, err)
+
+	tracer := &fakeTracer{}
+	reg, err := New(context.Background(), "svc", "dev", "service", false, WithTracer(tracer))
+	require.NoError(t, err)
+	require.NoError(t, reg.manualDeploy(context.Background(), Config{ServiceName: "svc"}, addr))
+	require.Error(t, reg.manualUndeploy(context.Background(), Config{ServiceName: "svc"}, addr))
+
+	for _, tc := range []struct {
+		span        string
+		path        string
+		expectedErr bool
+	}{
+		{span: SpanRegistryFetch, path: "/getRegistry"},
+		{span: SpanRegistryRegister, path: "/register"},
+		{span: SpanRegistryUnregister, path: "/unregister", expectedErr: true},
+	} {
+		span := tracer.span(tc.span)
+		require.NotNil(t, span, tc.span)
+		assert.True(t, span.ended)
+		assert.Equal(t, "svc", span.attrs[AttrServiceName], "the configured name wins over the controller one")
+		assert.Equal(t, tc.expectedErr, span.err != nil)
+		assert.Equal(t, span.TraceParent(), traceParents[tc.path])
+	}
+}
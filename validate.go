@@ -0,0 +1,322 @@
+package soajsgo
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// validationError is the failure of a value against a JSON schema, at the path of the value.
+type validationError struct {
+	path   string
+	reason string
+}
+
+// patterns caches the compiled patterns of the schemas.
+var patterns sync.Map
+
+func (e *validationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.path, e.reason)
+}
+
+// validateValue validates v against the subset of JSON schema used by IMFV: type, enum, minLength, maxLength,
+// pattern, format (email, date, date-time, uri), minimum, maximum, exclusiveMinimum, exclusiveMaximum, minItems,
+// maxItems, uniqueItems, items, properties, required and additionalProperties.
+func validateValue(path string, v interface{}, schema map[string]interface{}) *validationError {
+	if len(schema) == 0 {
+		return nil
+	}
+	fail := func(format string, args ...interface{}) *validationError {
+		return &validationError{path: path, reason: fmt.Sprintf(format, args...)}
+	}
+	v = normalize(v)
+	if types := schemaTypes(schema); len(types) > 0 && !types[jsonType(v)] && !(types["number"] && jsonType(v) == "integer") {
+		return fail("must be of type %s", strings.Join(sortedTypes(types), " or "))
+	}
+	if enum, ok := schema["enum"].([]interface{}); ok {
+		found := false
+		for _, e := range enum {
+			if reflect.DeepEqual(normalize(e), v) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fail("must be one of %s", mustJSON(enum))
+		}
+	}
+	switch value := v.(type) {
+	case string:
+		return validateString(value, schema, fail)
+	case float64:
+		return validateNumber(value, schema, fail)
+	case []interface{}:
+		return validateArray(path, value, schema, fail)
+	case map[string]interface{}:
+		return validateObject(path, value, schema, fail)
+	}
+	return nil
+}
+
+func validateString(v string, schema map[string]interface{}, fail func(string, ...interface{}) *validationError) *validationError {
+	length := float64(utf8.RuneCountInString(v))
+	if n, ok := schemaNumber(schema, "minLength"); ok && length < n {
+		return fail("must be at least %v characters long", n)
+	}
+	if n, ok := schemaNumber(schema, "maxLength"); ok && length > n {
+		return fail("must be at most %v characters long", n)
+	}
+	if pattern, ok := schema["pattern"].(string); ok {
+		re, err := compilePattern(pattern)
+		if err != nil {
+			return fail("has an invalid pattern: %v", err)
+		}
+		if !re.MatchString(v) {
+			return fail("must match pattern %s", pattern)
+		}
+	}
+	if format, ok := schema["format"].(string); ok && !validFormat(format, v) {
+		return fail("must be a valid %s", format)
+	}
+	return nil
+}
+
+// validateNumber validates a number, exclusiveMinimum and exclusiveMaximum being either numbers or, as in draft 4,
+// booleans making minimum and maximum exclusive.
+func validateNumber(v float64, schema map[string]interface{}, fail func(string, ...interface{}) *validationError) *validationError {
+	minExclusive, _ := schema["exclusiveMinimum"].(bool)
+	maxExclusive, _ := schema["exclusiveMaximum"].(bool)
+	if n, ok := schemaNumber(schema, "minimum"); ok {
+		if minExclusive && v <= n {
+			return fail("must be greater than %v", n)
+		}
+		if v < n {
+			return fail("must be greater than or equal to %v", n)
+		}
+	}
+	if n, ok := schemaNumber(schema, "maximum"); ok {
+		if maxExclusive && v >= n {
+			return fail("must be less than %v", n)
+		}
+		if v > n {
+			return fail("must be less than or equal to %v", n)
+		}
+	}
+	if n, ok := schemaNumber(schema, "exclusiveMinimum"); ok && v <= n {
+		return fail("must be greater than %v", n)
+	}
+	if n, ok := schemaNumber(schema, "exclusiveMaximum"); ok && v >= n {
+		return fail("must be less than %v", n)
+	}
+	return nil
+}
+
+func validateArray(path string, v []interface{}, schema map[string]interface{}, fail func(string, ...interface{}) *validationError) *validationError {
+	if n, ok := schemaNumber(schema, "minItems"); ok && float64(len(v)) < n {
+		return fail("must have at least %v items", n)
+	}
+	if n, ok := schemaNumber(schema, "maxItems"); ok && float64(len(v)) > n {
+		return fail("must have at most %v items", n)
+	}
+	if unique, _ := schema["uniqueItems"].(bool); unique {
+		for i := range v {
+			for j := i + 1; j < len(v); j++ {
+				if reflect.DeepEqual(v[i], v[j]) {
+					return fail("must have unique items")
+				}
+			}
+		}
+	}
+	items, _ := schema["items"].(map[string]interface{})
+	for i, item := range v {
+		if err := validateValue(fmt.Sprintf("%s[%d]", path, i), item, items); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateObject(path string, v map[string]interface{}, schema map[string]interface{}, fail func(string, ...interface{}) *validationError) *validationError {
+	if required, ok := schema["required"].([]interface{}); ok {
+		for _, name := range required {
+			if key, ok := name.(string); ok {
+				if _, found := v[key]; !found {
+					return &validationError{path: path + "." + key, reason: "is required"}
+				}
+			}
+		}
+	}
+	properties, _ := schema["properties"].(map[string]interface{})
+	keys := make([]string, 0, len(v))
+	for key := range v {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if property, ok := properties[key].(map[string]interface{}); ok {
+			if err := validateValue(path+"."+key, v[key], property); err != nil {
+				return err
+			}
+			continue
+		}
+		switch additional := schema["additionalProperties"].(type) {
+		case bool:
+			if !additional {
+				return fail("must not have property %s", key)
+			}
+		case map[string]interface{}:
+			if err := validateValue(path+"."+key, v[key], additional); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkSchema reports the errors of a schema that would make every validation fail, e.g. an invalid pattern.
+func checkSchema(schema map[string]interface{}) error {
+	if pattern, ok := schema["pattern"].(string); ok {
+		if _, err := compilePattern(pattern); err != nil {
+			return fmt.Errorf("invalid pattern %s: %v", pattern, err)
+		}
+	}
+	var nested []interface{}
+	nested = append(nested, schema["items"], schema["additionalProperties"])
+	if properties, ok := schema["properties"].(map[string]interface{}); ok {
+		for _, property := range properties {
+			nested = append(nested, property)
+		}
+	}
+	for _, n := range nested {
+		if s, ok := n.(map[string]interface{}); ok {
+			if err := checkSchema(s); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	if re, ok := patterns.Load(pattern); ok {
+		return re.(*regexp.Regexp), nil
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	patterns.Store(pattern, re)
+	return re, nil
+}
+
+func validFormat(format, v string) bool {
+	switch format {
+	case "email":
+		addr, err := mail.ParseAddress(v)
+		return err == nil && addr.Address == v
+	case "date":
+		_, err := time.Parse("2006-01-02", v)
+		return err == nil
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, v)
+		return err == nil
+	case "uri":
+		u, err := url.Parse(v)
+		return err == nil && u.Scheme != ""
+	default:
+		return true
+	}
+}
+
+// schemaTypes returns the types allowed by the schema, from a type string or a list of types.
+func schemaTypes(schema map[string]interface{}) map[string]bool {
+	types := make(map[string]bool)
+	switch t := schema["type"].(type) {
+	case string:
+		types[t] = true
+	case []interface{}:
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				types[s] = true
+			}
+		}
+	}
+	return types
+}
+
+func sortedTypes(types map[string]bool) []string {
+	out := make([]string, 0, len(types))
+	for t := range types {
+		out = append(out, t)
+	}
+	sort.Strings(out)
+	return out
+}
+
+func schemaNumber(schema map[string]interface{}, key string) (float64, bool) {
+	n, ok := normalize(schema[key]).(float64)
+	return n, ok
+}
+
+// jsonType returns the JSON schema type of a normalized value.
+func jsonType(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case float64:
+		if value == math.Trunc(value) && !math.IsInf(value, 0) {
+			return "integer"
+		}
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+// normalize converts the numbers of Go values, e.g. the defaults of a schema built in code, to float64 as JSON
+// decoding does.
+func normalize(v interface{}) interface{} {
+	switch n := v.(type) {
+	case int:
+		return float64(n)
+	case int32:
+		return float64(n)
+	case int64:
+		return float64(n)
+	case float32:
+		return float64(n)
+	case json.Number:
+		if f, err := n.Float64(); err == nil {
+			return f
+		}
+	case []string:
+		out := make([]interface{}, 0, len(n))
+		for _, s := range n {
+			out = append(out, s)
+		}
+		return out
+	}
+	return v
+}
+
+func mustJSON(v interface{}) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
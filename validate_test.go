@@ -0,0 +1,114 @@
+package soajsgo
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateValue(t *testing.T) {
+	tt := []struct {
+		name        string
+		value       interface{}
+		schema      string
+		expectedErr string
+	}{
+		{name: "no schema", value: "x", schema: `{}`},
+		{name: "type", value: "x", schema: `{"type":"string"}`},
+		{name: "wrong type", value: 1.0, schema: `{"type":"string"}`, expectedErr: "f: must be of type string"},
+		{name: "type list", value: nil, schema: `{"type":["string","null"]}`},
+		{name: "wrong type list", value: true, schema: `{"type":["string","null"]}`, expectedErr: "f: must be of type null or string"},
+		{name: "integer is a number", value: 2, schema: `{"type":"number"}`},
+		{name: "number is not an integer", value: 2.5, schema: `{"type":"integer"}`, expectedErr: "f: must be of type integer"},
+		{name: "enum", value: "b", schema: `{"enum":["a","b"]}`},
+		{name: "not in enum", value: "c", schema: `{"enum":["a","b"]}`, expectedErr: `f: must be one of ["a","b"]`},
+		{name: "min length", value: "é", schema: `{"minLength":2}`, expectedErr: "f: must be at least 2 characters long"},
+		{name: "max length", value: "abc", schema: `{"maxLength":2}`, expectedErr: "f: must be at most 2 characters long"},
+		{name: "pattern", value: "abc", schema: `{"pattern":"^[0-9]+$"}`, expectedErr: "f: must match pattern ^[0-9]+$"},
+		{name: "email", value: "john@example.com", schema: `{"format":"email"}`},
+		{name: "invalid email", value: "John <john@example.com>", schema: `{"format":"email"}`, expectedErr: "f: must be a valid email"},
+		{name: "date", value: "2024-02-30", schema: `{"format":"date"}`, expectedErr: "f: must be a valid date"},
+		{name: "date-time", value: "2024-02-01T10:00:00Z", schema: `{"format":"date-time"}`},
+		{name: "uri", value: "example.com", schema: `{"format":"uri"}`, expectedErr: "f: must be a valid uri"},
+		{name: "unknown format", value: "x", schema: `{"format":"color"}`},
+		{name: "minimum", value: 0, schema: `{"minimum":1}`, expectedErr: "f: must be greater than or equal to 1"},
+		{name: "maximum", value: 11, schema: `{"maximum":10}`, expectedErr: "f: must be less than or equal to 10"},
+		{name: "exclusive minimum", value: 1, schema: `{"exclusiveMinimum":1}`, expectedErr: "f: must be greater than 1"},
+		{name: "exclusive maximum", value: 10, schema: `{"exclusiveMaximum":10}`, expectedErr: "f: must be less than 10"},
+		{name: "draft 4 exclusive minimum", value: 1, schema: `{"minimum":1,"exclusiveMinimum":true}`, expectedErr: "f: must be greater than 1"},
+		{name: "draft 4 exclusive maximum", value: 10, schema: `{"maximum":10,"exclusiveMaximum":true}`, expectedErr: "f: must be less than 10"},
+		{name: "draft 4 inclusive minimum", value: 1, schema: `{"minimum":1,"exclusiveMinimum":false}`},
+		{name: "draft 4 exclusive bounds", value: 5, schema: `{"minimum":1,"maximum":10,"exclusiveMinimum":true,"exclusiveMaximum":true}`},
+		{name: "items", value: []interface{}{"a", 1.0}, schema: `{"items":{"type":"string"}}`, expectedErr: "f[1]: must be of type string"},
+		{name: "min items", value: []string{}, schema: `{"minItems":1}`, expectedErr: "f: must have at least 1 items"},
+		{name: "max items", value: []string{"a", "b"}, schema: `{"maxItems":1}`, expectedErr: "f: must have at most 1 items"},
+		{name: "unique items", value: []string{"a", "a"}, schema: `{"uniqueItems":true}`, expectedErr: "f: must have unique items"},
+		{
+			name:        "required property",
+			value:       map[string]interface{}{"a": "x"},
+			schema:      `{"type":"object","required":["a","b"]}`,
+			expectedErr: "f.b: is required",
+		},
+		{
+			name:        "nested property",
+			value:       map[string]interface{}{"a": map[string]interface{}{"b": "x"}},
+			schema:      `{"properties":{"a":{"properties":{"b":{"type":"integer"}}}}}`,
+			expectedErr: "f.a.b: must be of type integer",
+		},
+		{
+			name:        "no additional properties",
+			value:       map[string]interface{}{"a": "x", "b": "y"},
+			schema:      `{"properties":{"a":{}},"additionalProperties":false}`,
+			expectedErr: "f: must not have property b",
+		},
+		{
+			name:        "additional properties schema",
+			value:       map[string]interface{}{"a": "x", "b": "y"},
+			schema:      `{"properties":{"a":{}},"additionalProperties":{"type":"integer"}}`,
+			expectedErr: "f.b: must be of type integer",
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			var schema map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(tc.schema), &schema))
+			err := validateValue("f", tc.value, schema)
+			if tc.expectedErr == "" {
+				assert.Nil(t, err)
+				return
+			}
+			require.NotNil(t, err)
+			assert.Equal(t, tc.expectedErr, err.Error())
+		})
+	}
+}
+
+func TestCoerce(t *testing.T) {
+	tt := []struct {
+		name     string
+		values   []string
+		schema   map[string]interface{}
+		expected interface{}
+	}{
+		{name: "untyped", values: []string{"1"}, expected: "1"},
+		{name: "integer", values: []string{"1"}, schema: map[string]interface{}{"type": "integer"}, expected: float64(1)},
+		{name: "invalid integer", values: []string{"1.5"}, schema: map[string]interface{}{"type": "integer"}, expected: "1.5"},
+		{name: "number", values: []string{"1.5"}, schema: map[string]interface{}{"type": "number"}, expected: 1.5},
+		{name: "boolean", values: []string{"false"}, schema: map[string]interface{}{"type": "boolean"}, expected: false},
+		{name: "object", values: []string{`{"a":1}`}, schema: map[string]interface{}{"type": "object"}, expected: map[string]interface{}{"a": float64(1)}},
+		{name: "string or integer", values: []string{"1"}, schema: map[string]interface{}{"type": []interface{}{"string", "integer"}}, expected: "1"},
+		{
+			name:     "array items",
+			values:   []string{"1", "2"},
+			schema:   map[string]interface{}{"type": "array", "items": map[string]interface{}{"type": "integer"}},
+			expected: []interface{}{float64(1), float64(2)},
+		},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, coerce(tc.values, tc.schema))
+		})
+	}
+}